@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
+)
+
+// mailer picks how the emails are sent with MAILER, there is no default. "log" and "file" are for local
+// development only, the password reset links (as good as the password) end up in the log or on the disk
+func mailer() mail.Mailer {
+	from := os.Getenv("MAIL_FROM")
+
+	switch kind := os.Getenv("MAILER"); kind {
+	case "":
+		log.Fatal(`MAILER isn't set, it's "smtp" or, for local development, "log" or "file"`)
+		return nil
+	case "log":
+		log.Print("emails are written to the log together with the password reset links, it's for local development only")
+		return mail.LogMailer{}
+	case "file":
+		log.Printf("emails are written to %s together with the password reset links, it's for local development only", os.Getenv("MAIL_DIR"))
+		return mail.FileMailer{Dir: os.Getenv("MAIL_DIR"), From: from}
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			log.Fatalf("invalid SMTP_PORT: %q", os.Getenv("SMTP_PORT"))
+		}
+		return mail.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	default:
+		log.Fatalf("unknown mailer: %s", kind)
+		return nil
+	}
+}
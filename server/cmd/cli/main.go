@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/memory"
 	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
+	"github.com/SergeyCherepiuk/docs/pkg/database/sqlite"
 	"github.com/SergeyCherepiuk/docs/pkg/http"
 	"github.com/joho/godotenv"
 )
@@ -14,10 +18,57 @@ func init() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatal(err)
 	}
-	neo4j.MustInitialize()
 }
 
 func main() {
-	e := http.Router{}.Build()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "search" {
+		searchCommand(os.Args[2:])
+		return
+	}
+
+	repos := withSearch(repositories())
+	sweepSessions(repos.Sessions)
+
+	e := http.Router{
+		Repositories:     repos,
+		SessionPolicy:    sessionPolicy(),
+		Mailer:           mailer(),
+		PasswordResetURL: os.Getenv("PASSWORD_RESET_URL"),
+		AllowedOrigins:   allowedOrigins(),
+	}.Build()
 	e.Start(fmt.Sprintf(":%s", os.Getenv("SERVER_PORT")))
 }
+
+func repositories() database.Repositories {
+	switch backend := os.Getenv("DATABASE_BACKEND"); backend {
+	case "neo4j", "":
+		neo4j.MustInitialize()
+		return neo4j.Repositories()
+	case "memory":
+		return memory.Repositories()
+	case "sqlite":
+		db, err := sqlite.Open(os.Getenv("SQLITE_PATH"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return sqlite.Repositories(db)
+	default:
+		log.Fatalf("unknown database backend: %s", backend)
+		return database.Repositories{}
+	}
+}
+
+// NOTE: Comma separated, the router's default is used if it's empty
+func allowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
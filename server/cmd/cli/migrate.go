@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
+)
+
+const migrateUsage = `Usage: cli migrate [flags] up|down|status
+
+Applies, reverts or lists the Neo4j schema migrations.
+
+Flags:
+`
+
+func migrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the migrations without applying or reverting them")
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	neo4j.MustConnect()
+	ctx := context.Background()
+
+	switch command := flags.Arg(0); command {
+	case "up":
+		migrations, err := neo4j.MigrationService.Up(ctx, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printMigrations("apply", "Applied", migrations, *dryRun)
+	case "down":
+		migrations, err := neo4j.MigrationService.Down(ctx, *steps, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printMigrations("revert", "Reverted", migrations, *dryRun)
+	case "status":
+		statuses, err := neo4j.MigrationService.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			status := "pending"
+			if s.Applied {
+				status = "applied at " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %s: %s\n", s.Version, s.Name, status)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+func printMigrations(verb, pastVerb string, migrations []neo4j.Migration, dryRun bool) {
+	if len(migrations) == 0 {
+		fmt.Printf("Nothing to %s\n", verb)
+		return
+	}
+
+	for _, m := range migrations {
+		if dryRun {
+			fmt.Printf("Would %s %04d %s\n", verb, m.Version, m.Name)
+		} else {
+			fmt.Printf("%s %04d %s\n", pastVerb, m.Version, m.Name)
+		}
+	}
+}
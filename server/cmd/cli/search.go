@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/search"
+)
+
+const (
+	databaseSearch = "database"
+	indexSearch    = "index"
+
+	indexSaveInterval = time.Minute
+)
+
+// withSearch replaces the search of the backend with the embedded index, if it's chosen.
+// Index is loaded from the file it was saved to, or rebuilt if there is none yet
+// or any file was updated after it was saved
+func withSearch(repos database.Repositories) database.Repositories {
+	switch engine := os.Getenv("SEARCH_ENGINE"); engine {
+	case databaseSearch, "":
+		return repos
+	case indexSearch:
+		path := os.Getenv("SEARCH_INDEX_PATH")
+		index, loaded, err := search.OpenInvertedIndex(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if loaded {
+			outdated, err := search.Outdated(context.Background(), index.SavedAt(), repos.Files)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if outdated {
+				log.Printf("the search index is older than the files, rebuilding")
+				loaded = false
+			}
+		}
+
+		if !loaded {
+			count, err := search.Rebuild(context.Background(), index, repos.Files)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := index.Save(path); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("built the search index of %d files", count)
+		}
+
+		go index.AutoSave(context.Background(), path, indexSaveInterval, func(err error) {
+			log.Printf("failed to save the search index: %v", err)
+		})
+
+		repos.Files = search.IndexedFiles{FileRepository: repos.Files, Index: index}
+		repos.Search = search.Service{Index: index, Files: repos.Files, Access: repos.Access}
+		return repos
+	default:
+		log.Fatalf("unknown search engine: %s", engine)
+		return database.Repositories{}
+	}
+}
+
+const searchUsage = `Usage: cli search rebuild
+
+Rebuilds the embedded search index from scratch and saves it to SEARCH_INDEX_PATH.
+The server saves its own index as it runs, so it has to be stopped while rebuilding.
+`
+
+func searchCommand(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), searchUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || flags.Arg(0) != "rebuild" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	repos := repositories()
+	index := search.NewInvertedIndex()
+
+	count, err := search.Rebuild(context.Background(), index, repos.Files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := index.Save(os.Getenv("SEARCH_INDEX_PATH")); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Indexed %d files\n", count)
+}
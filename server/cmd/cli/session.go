@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+// sessionPolicy reads the session timeouts, the defaults of models.DefaultSessionPolicy
+// are used for the ones that aren't set
+func sessionPolicy() models.SessionPolicy {
+	policy := models.DefaultSessionPolicy
+	policy.IdleTimeout = durationEnv("SESSION_IDLE_TIMEOUT", policy.IdleTimeout)
+	policy.AbsoluteTimeout = durationEnv("SESSION_ABSOLUTE_TIMEOUT", policy.AbsoluteTimeout)
+	policy.RefreshInterval = durationEnv("SESSION_REFRESH_INTERVAL", policy.RefreshInterval)
+	return policy
+}
+
+// sweepSessions deletes the expired sessions in the background
+func sweepSessions(sessions database.SessionRepository) {
+	interval := durationEnv("SESSION_SWEEP_INTERVAL", time.Hour)
+	go database.SweepSessions(context.Background(), sessions, interval, func(err error) {
+		log.Printf("failed to delete the expired sessions: %v", err)
+	})
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("invalid %s: %q", key, value)
+	}
+	return duration
+}
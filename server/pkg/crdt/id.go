@@ -0,0 +1,21 @@
+package crdt
+
+// Id identifies a single character of the sequence. Clock is a Lamport
+// timestamp and Site is the replica that created the character.
+type Id struct {
+	Clock int64  `json:"clock"`
+	Site  string `json:"site"`
+}
+
+func (id Id) IsZero() bool {
+	return id.Clock == 0 && id.Site == ""
+}
+
+// Less defines a total order of identifiers, which
+// is used to order concurrent inserts deterministically
+func (id Id) Less(other Id) bool {
+	if id.Clock != other.Clock {
+		return id.Clock < other.Clock
+	}
+	return id.Site < other.Site
+}
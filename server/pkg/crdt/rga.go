@@ -0,0 +1,280 @@
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Op is a single change of the sequence. Insert places Value right after
+// the character identified by Origin (zero Origin is the beginning).
+// Delete marks the character identified by Id as removed.
+type Op struct {
+	Type   string `json:"type"`
+	Id     Id     `json:"id"`
+	Origin Id     `json:"origin"`
+	Value  string `json:"value,omitempty"`
+}
+
+const (
+	InsertOp = "insert"
+	DeleteOp = "delete"
+)
+
+// Delta is a batch of operations exchanged between replicas
+type Delta []Op
+
+// MaxPending is the number of operations the document keeps
+// while waiting for their dependencies, the rest are dropped
+const MaxPending = 10000
+
+var ErrTooManyPending = errors.New("too many operations wait for their dependencies")
+
+type Element struct {
+	Id      Id     `json:"id"`
+	Origin  Id     `json:"origin"`
+	Value   string `json:"value"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Document is a replicated growable array (RGA) of characters.
+// It's not safe for concurrent use.
+type Document struct {
+	elements []Element
+	index    map[Id]int
+	clock    int64
+
+	// NOTE: Operations whose dependencies haven't arrived yet,
+	// by the id of the element they wait for
+	pending      map[Id][]Op
+	pendingCount int
+}
+
+func NewDocument() *Document {
+	return &Document{index: make(map[Id]int), pending: make(map[Id][]Op)}
+}
+
+// FromState restores the document from the elements returned by State
+func FromState(state []Element) (*Document, error) {
+	d := NewDocument()
+	for _, element := range state {
+		if err := validateId(element.Id); err != nil {
+			return nil, err
+		}
+		if _, ok := d.index[element.Id]; ok {
+			return nil, fmt.Errorf("duplicate element id")
+		}
+
+		d.index[element.Id] = len(d.elements)
+		d.elements = append(d.elements, element)
+		d.clock = max(d.clock, element.Id.Clock)
+	}
+	return d, nil
+}
+
+func (d *Document) State() []Element {
+	state := make([]Element, len(d.elements))
+	copy(state, d.elements)
+	return state
+}
+
+func (d *Document) Text() string {
+	var builder strings.Builder
+	for _, element := range d.elements {
+		if !element.Deleted {
+			builder.WriteString(element.Value)
+		}
+	}
+	return builder.String()
+}
+
+// Merge applies the delta received from another replica. Applying the same
+// operations in any order, any number of times, gives the same document.
+// Once MaxPending operations wait for their dependencies, the ones that can't be
+// applied are dropped and ErrTooManyPending is returned after the rest of the delta is merged
+func (d *Document) Merge(delta Delta) error {
+	for _, op := range delta {
+		if err := validateOp(op); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for _, op := range delta {
+		if !d.apply(op) {
+			if d.pendingCount >= MaxPending {
+				err = ErrTooManyPending
+				continue
+			}
+			dependency := dependencyOf(op)
+			d.pending[dependency] = append(d.pending[dependency], op)
+			d.pendingCount++
+			continue
+		}
+
+		// NOTE: Operations waiting for the inserted element can be applied now,
+		// and the ones waiting for them in turn
+		for ready := []Op{op}; len(ready) > 0; {
+			applied := ready[len(ready)-1]
+			ready = ready[:len(ready)-1]
+			if applied.Type != InsertOp {
+				continue
+			}
+
+			waiting := d.pending[applied.Id]
+			delete(d.pending, applied.Id)
+			d.pendingCount -= len(waiting)
+			for _, op := range waiting {
+				d.apply(op)
+			}
+			ready = append(ready, waiting...)
+		}
+	}
+
+	return err
+}
+
+// Insert creates the delta that inserts text at the given
+// position of the visible text and applies it to the document
+func (d *Document) Insert(site string, position int, text string) (Delta, error) {
+	origin, err := d.originAt(position)
+	if err != nil {
+		return nil, err
+	}
+
+	var delta Delta
+	for _, r := range text {
+		d.clock++
+		op := Op{Type: InsertOp, Id: Id{Clock: d.clock, Site: site}, Origin: origin, Value: string(r)}
+		d.apply(op)
+		delta = append(delta, op)
+		origin = op.Id
+	}
+	return delta, nil
+}
+
+// Delete creates the delta that deletes length characters starting
+// at the given position of the visible text and applies it to the document
+func (d *Document) Delete(position, length int) (Delta, error) {
+	var delta Delta
+	visible := 0
+	for _, element := range d.elements {
+		if element.Deleted {
+			continue
+		}
+		if visible >= position && visible < position+length {
+			delta = append(delta, Op{Type: DeleteOp, Id: element.Id})
+		}
+		visible++
+	}
+
+	if position < 0 || length < 0 || position+length > visible {
+		return nil, fmt.Errorf("position is out of range")
+	}
+
+	for _, op := range delta {
+		d.apply(op)
+	}
+	return delta, nil
+}
+
+func (d *Document) originAt(position int) (Id, error) {
+	if position == 0 {
+		return Id{}, nil
+	}
+
+	visible := 0
+	for _, element := range d.elements {
+		if element.Deleted {
+			continue
+		}
+		visible++
+		if visible == position {
+			return element.Id, nil
+		}
+	}
+	return Id{}, fmt.Errorf("position is out of range")
+}
+
+// NOTE: Returns false if the operation depends on an element that isn't known yet
+func (d *Document) apply(op Op) bool {
+	switch op.Type {
+	case InsertOp:
+		return d.integrate(op)
+	case DeleteOp:
+		i, ok := d.index[op.Id]
+		if !ok {
+			return false
+		}
+		d.elements[i].Deleted = true
+		return true
+	}
+	return true
+}
+
+// NOTE: Insert waits for its origin, delete waits for the element it deletes
+func dependencyOf(op Op) Id {
+	if op.Type == InsertOp {
+		return op.Origin
+	}
+	return op.Id
+}
+
+func (d *Document) integrate(op Op) bool {
+	if _, ok := d.index[op.Id]; ok {
+		return true
+	}
+
+	position := 0
+	if !op.Origin.IsZero() {
+		i, ok := d.index[op.Origin]
+		if !ok {
+			return false
+		}
+		position = i + 1
+	}
+
+	// NOTE: Skipping concurrent inserts at the same origin that have greater ids,
+	// together with everything inserted after them (their ids are greater too)
+	for position < len(d.elements) && op.Id.Less(d.elements[position].Id) {
+		position++
+	}
+
+	d.elements = append(d.elements, Element{})
+	copy(d.elements[position+1:], d.elements[position:])
+	d.elements[position] = Element{Id: op.Id, Origin: op.Origin, Value: op.Value}
+
+	for i := position; i < len(d.elements); i++ {
+		d.index[d.elements[i].Id] = i
+	}
+	d.clock = max(d.clock, op.Id.Clock)
+	return true
+}
+
+func validateId(id Id) error {
+	if id.Clock <= 0 || id.Site == "" {
+		return fmt.Errorf("invalid element id")
+	}
+	return nil
+}
+
+func validateOp(op Op) error {
+	if err := validateId(op.Id); err != nil {
+		return err
+	}
+
+	switch op.Type {
+	case InsertOp:
+		if utf8.RuneCountInString(op.Value) != 1 {
+			return fmt.Errorf("insert must contain exactly one character")
+		}
+		if !op.Origin.IsZero() && !op.Origin.Less(op.Id) {
+			return fmt.Errorf("insert must be newer than its origin")
+		}
+	case DeleteOp:
+	default:
+		return fmt.Errorf("unknown operation type: %s", op.Type)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package crdt_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/SergeyCherepiuk/docs/pkg/crdt"
+)
+
+func TestInsertAndDelete(t *testing.T) {
+	d := crdt.NewDocument()
+	d.Insert("a", 0, "hed")
+	d.Insert("a", 2, "llo worl")
+	d.Delete(0, 1)
+	d.Insert("a", 0, "H")
+
+	expected := "Hello world"
+	if actual := d.Text(); actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+}
+
+func TestConcurrentInsertsAtSamePosition(t *testing.T) {
+	a, b := crdt.NewDocument(), crdt.NewDocument()
+	base, _ := a.Insert("a", 0, "-")
+	b.Merge(base)
+
+	da, _ := a.Insert("a", 1, "A")
+	db, _ := b.Insert("b", 1, "B")
+	a.Merge(db)
+	b.Merge(da)
+
+	if a.Text() != b.Text() {
+		t.Errorf(`expected same text, actual: "%s" and "%s"`, a.Text(), b.Text())
+	}
+}
+
+func TestMergeBuffersMissingDependencies(t *testing.T) {
+	a := crdt.NewDocument()
+	first, _ := a.Insert("a", 0, "ab")
+	second, _ := a.Delete(0, 1)
+
+	b := crdt.NewDocument()
+	b.Merge(second)
+	b.Merge(first[1:])
+	b.Merge(first[:1])
+
+	if actual := b.Text(); actual != "b" {
+		t.Errorf(`expected: "%s", actual: "%s"`, "b", actual)
+	}
+}
+
+// NOTE: Operations whose origin never arrives must not be buffered without a limit
+func TestMergeLimitsPendingOps(t *testing.T) {
+	d := crdt.NewDocument()
+	missing := crdt.Id{Clock: 1, Site: "a"}
+
+	var delta crdt.Delta
+	for i := 0; i <= crdt.MaxPending; i++ {
+		delta = append(delta, crdt.Op{Type: crdt.InsertOp, Id: crdt.Id{Clock: int64(i + 2), Site: "a"}, Origin: missing, Value: "x"})
+	}
+	if err := d.Merge(delta); !errors.Is(err, crdt.ErrTooManyPending) {
+		t.Fatalf("expected: %v, actual: %v", crdt.ErrTooManyPending, err)
+	}
+
+	if err := d.Merge(crdt.Delta{{Type: crdt.InsertOp, Id: missing, Value: "-"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual := len([]rune(d.Text())); actual != crdt.MaxPending+1 {
+		t.Errorf("expected: %d, actual: %d", crdt.MaxPending+1, actual)
+	}
+}
+
+func TestMergeRejectsInvalidOps(t *testing.T) {
+	d := crdt.NewDocument()
+	delta := crdt.Delta{{Type: crdt.InsertOp, Id: crdt.Id{Clock: 1, Site: "a"}, Value: "ab"}}
+
+	if err := d.Merge(delta); err == nil {
+		t.Errorf("expected an error, actual: nil")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	d := crdt.NewDocument()
+	d.Insert("a", 0, "hello")
+	d.Delete(1, 2)
+
+	restored, err := crdt.FromState(d.State())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	next, _ := restored.Insert("b", 3, "!")
+	d.Merge(next)
+	if restored.Text() != d.Text() {
+		t.Errorf(`expected: "%s", actual: "%s"`, d.Text(), restored.Text())
+	}
+}
+
+// NOTE: Replicas edit concurrently, syncing only from time to time, and
+// finally every replica receives all deltas in a random order (duplicates
+// included). Every replica must end up with the same text.
+func TestReplicasConverge(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+
+		replicas := make([]*crdt.Document, 2+r.Intn(3))
+		for i := range replicas {
+			replicas[i] = crdt.NewDocument()
+		}
+
+		var deltas []crdt.Delta
+		for step := 0; step < 30; step++ {
+			i := r.Intn(len(replicas))
+			replica := replicas[i]
+			length := len([]rune(replica.Text()))
+
+			var delta crdt.Delta
+			if length > 0 && r.Intn(3) == 0 {
+				position := r.Intn(length)
+				delta, _ = replica.Delete(position, 1+r.Intn(length-position))
+			} else {
+				delta, _ = replica.Insert(fmt.Sprint(i), r.Intn(length+1), randomText(r))
+			}
+			deltas = append(deltas, delta)
+
+			if r.Intn(4) == 0 {
+				other := replicas[r.Intn(len(replicas))]
+				for _, j := range r.Perm(len(deltas)) {
+					other.Merge(deltas[j])
+				}
+			}
+		}
+
+		for _, replica := range replicas {
+			for _, j := range r.Perm(len(deltas)) {
+				replica.Merge(deltas[j])
+			}
+		}
+
+		for _, replica := range replicas[1:] {
+			if replica.Text() != replicas[0].Text() {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func randomText(r *rand.Rand) string {
+	runes := []rune("xyzłó ")
+	text := make([]rune, 1+r.Intn(3))
+	for i := range text {
+		text[i] = runes[r.Intn(len(runes))]
+	}
+	return string(text)
+}
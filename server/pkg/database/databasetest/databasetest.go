@@ -0,0 +1,1022 @@
+// Package databasetest is a conformance test suite every storage backend has to pass.
+// Names and ids are unique per run, so the suite can run against a non-empty database.
+package databasetest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+func Run(t *testing.T, repos database.Repositories) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, repos) })
+	t.Run("Emails", func(t *testing.T) { testEmails(t, repos) })
+	t.Run("PasswordResets", func(t *testing.T) { testPasswordResets(t, repos) })
+	t.Run("TwoFactor", func(t *testing.T) { testTwoFactor(t, repos) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, repos) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, repos) })
+	t.Run("Files", func(t *testing.T) { testFiles(t, repos) })
+	t.Run("Revisions", func(t *testing.T) { testRevisions(t, repos) })
+	t.Run("Folders", func(t *testing.T) { testFolders(t, repos) })
+	t.Run("ConcurrentMoves", func(t *testing.T) { testConcurrentMoves(t, repos) })
+	t.Run("Access", func(t *testing.T) { testAccess(t, repos) })
+	t.Run("SharedFiles", func(t *testing.T) { testSharedFiles(t, repos) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, repos) })
+	t.Run("FileTimestamps", func(t *testing.T) { testFileTimestamps(t, repos) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, repos) })
+	t.Run("CascadeDelete", func(t *testing.T) { testCascadeDelete(t, repos) })
+	t.Run("Transactions", func(t *testing.T) { testTransactions(t, repos) })
+}
+
+func newUser(t *testing.T, repos database.Repositories) models.User {
+	t.Helper()
+	user := models.User{Username: "user-" + uuid.NewString(), Password: "password"}
+	if err := repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create a user: %v", err)
+	}
+	return user
+}
+
+func newFile(t *testing.T, repos database.Repositories, owner models.User, name string, parent *models.Folder) models.File {
+	t.Helper()
+	ctx := context.Background()
+	file := models.File{Id: uuid.NewString(), Name: name}
+	if err := repos.Files.Create(ctx, file, owner); err != nil {
+		t.Fatalf("failed to create a file: %v", err)
+	}
+	if parent != nil {
+		if err := repos.Folders.MoveFile(ctx, file, parent); err != nil {
+			t.Fatalf("failed to move the file: %v", err)
+		}
+	}
+	return file
+}
+
+func newFolder(t *testing.T, repos database.Repositories, owner models.User, name string, parent *models.Folder) *models.Folder {
+	t.Helper()
+	folder := models.Folder{Id: uuid.NewString(), Name: name}
+	if err := repos.Folders.Create(context.Background(), folder, parent, owner); err != nil {
+		t.Fatalf("failed to create a folder: %v", err)
+	}
+	return &folder
+}
+
+func testUsers(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	if err := repos.Users.Create(ctx, user); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected duplicate username to be rejected")
+	}
+
+	got, err := repos.Users.GetByUsername(ctx, user.Username)
+	if err != nil || got.Username != user.Username || got.Password != user.Password || got.TOTPEnabled || len(got.RecoveryCodes) != 0 {
+		t.Errorf("expected %+v, got %+v (%v)", user, got, err)
+	}
+
+	if _, err := repos.Users.GetByUsername(ctx, "user-"+uuid.NewString()); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected unknown user not to be found")
+	}
+
+	other := newUser(t, repos)
+	if err := repos.Users.UpdateUsername(ctx, user, other.Username); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected taken username to be rejected")
+	}
+
+	renamed := models.User{Username: "user-" + uuid.NewString(), Password: user.Password}
+	if err := repos.Users.UpdateUsername(ctx, user, renamed.Username); err != nil {
+		t.Fatalf("failed to update the username: %v", err)
+	}
+	if _, err := repos.Users.GetByUsername(ctx, user.Username); err == nil {
+		t.Errorf("expected old username not to be found")
+	}
+
+	if err := repos.Users.UpdatePassword(ctx, renamed, "new password"); err != nil {
+		t.Fatalf("failed to update the password: %v", err)
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, renamed.Username); got.Password != "new password" {
+		t.Errorf("expected password to be updated, got %q", got.Password)
+	}
+}
+
+func testEmails(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	email := uuid.NewString() + "@example.com"
+
+	user := models.User{Username: "user-" + uuid.NewString(), Password: "password", Email: email}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("failed to create a user: %v", err)
+	}
+	if got, err := repos.Users.GetByEmail(ctx, email); err != nil || got.Username != user.Username || got.Email != email {
+		t.Errorf("expected %s to be found by email, got %+v (%v)", user.Username, got, err)
+	}
+
+	taken := models.User{Username: "user-" + uuid.NewString(), Password: "password", Email: email}
+	if err := repos.Users.Create(ctx, taken); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected taken email to be rejected, got %v", err)
+	}
+
+	// NOTE: Any number of users can have no email
+	first, second := newUser(t, repos), newUser(t, repos)
+	if _, err := repos.Users.GetByEmail(ctx, ""); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected empty email not to be found, got %v", err)
+	}
+
+	if err := repos.Users.UpdateEmail(ctx, first, email); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected taken email to be rejected, got %v", err)
+	}
+	newEmail := uuid.NewString() + "@example.com"
+	if err := repos.Users.UpdateEmail(ctx, second, newEmail); err != nil {
+		t.Fatalf("failed to update the email: %v", err)
+	}
+	if got, err := repos.Users.GetByEmail(ctx, newEmail); err != nil || got.Username != second.Username {
+		t.Errorf("expected %s to be found by the new email, got %+v (%v)", second.Username, got, err)
+	}
+	if err := repos.Users.UpdateEmail(ctx, second, ""); err != nil {
+		t.Fatalf("failed to remove the email: %v", err)
+	}
+	if _, err := repos.Users.GetByEmail(ctx, newEmail); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected removed email not to be found, got %v", err)
+	}
+}
+
+func testPasswordResets(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	if err := repos.Resets.Create(ctx, models.PasswordReset{Username: "user-" + uuid.NewString(), Hash: uuid.NewString()}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected reset of unknown user to be rejected, got %v", err)
+	}
+
+	old, oldSecret, _ := models.NewPasswordReset(user.Username, time.Hour)
+	if err := repos.Resets.Create(ctx, old); err != nil {
+		t.Fatalf("failed to create a password reset: %v", err)
+	}
+	reset, secret, _ := models.NewPasswordReset(user.Username, time.Hour)
+	if err := repos.Resets.Create(ctx, reset); err != nil {
+		t.Fatalf("failed to create a password reset: %v", err)
+	}
+
+	if _, err := repos.Resets.Use(ctx, models.HashToken(oldSecret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected replaced reset to be rejected, got %v", err)
+	}
+	if got, err := repos.Resets.Use(ctx, models.HashToken(secret)); err != nil || got.Username != user.Username {
+		t.Errorf("expected reset of %s, got %+v (%v)", user.Username, got, err)
+	}
+	if _, err := repos.Resets.Use(ctx, models.HashToken(secret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected used reset to be rejected, got %v", err)
+	}
+
+	expired, expiredSecret, _ := models.NewPasswordReset(user.Username, -time.Minute)
+	if err := repos.Resets.Create(ctx, expired); err != nil {
+		t.Fatalf("failed to create a password reset: %v", err)
+	}
+	if _, err := repos.Resets.Use(ctx, models.HashToken(expiredSecret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected expired reset to be rejected, got %v", err)
+	}
+}
+
+func testTwoFactor(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	if err := repos.Users.UpdateTwoFactor(ctx, user, "SECRET", false, nil); err != nil {
+		t.Fatalf("failed to enrol: %v", err)
+	}
+	if got, err := repos.Users.GetByUsername(ctx, user.Username); err != nil || got.TOTPSecret != "SECRET" || got.TOTPEnabled {
+		t.Errorf("expected pending enrolment, got %+v (%v)", got, err)
+	}
+
+	codes := []string{"first", "second", "third"}
+	if err := repos.Users.UpdateTwoFactor(ctx, user, "SECRET", true, codes); err != nil {
+		t.Fatalf("failed to enable: %v", err)
+	}
+	if got, err := repos.Users.GetByUsername(ctx, user.Username); err != nil || !got.TOTPEnabled || !equal(got.RecoveryCodes, codes) {
+		t.Errorf("expected enabled two-factor with %v, got %+v (%v)", codes, got, err)
+	}
+
+	if err := repos.Users.UseRecoveryCode(ctx, user, "second"); err != nil {
+		t.Fatalf("failed to use the recovery code: %v", err)
+	}
+	if err := repos.Users.UseRecoveryCode(ctx, user, "second"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected used recovery code to be rejected, got %v", err)
+	}
+	if err := repos.Users.UseRecoveryCode(ctx, user, "sec"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected partial recovery code to be rejected, got %v", err)
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, user.Username); !equal(got.RecoveryCodes, []string{"first", "third"}) {
+		t.Errorf("expected the other recovery codes to be kept, got %v", got.RecoveryCodes)
+	}
+
+	if err := repos.Users.UseTOTPStep(ctx, user, 100); err != nil {
+		t.Fatalf("failed to use the step: %v", err)
+	}
+	if err := repos.Users.UseTOTPStep(ctx, user, 100); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected the same step to be rejected, got %v", err)
+	}
+	if err := repos.Users.UseTOTPStep(ctx, user, 99); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected an earlier step to be rejected, got %v", err)
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, user.Username); got.TOTPLastStep != 100 {
+		t.Errorf("expected the last step to be 100, got %d", got.TOTPLastStep)
+	}
+
+	if err := repos.Users.UpdateTwoFactor(ctx, user, "", false, nil); err != nil {
+		t.Fatalf("failed to disable: %v", err)
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, user.Username); got.TOTPSecret != "" || got.TOTPEnabled || len(got.RecoveryCodes) != 0 {
+		t.Errorf("expected two-factor to be disabled, got %+v", got)
+	}
+	if err := repos.Users.UseRecoveryCode(ctx, user, "first"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected recovery codes to be dropped, got %v", err)
+	}
+}
+
+func testSessions(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+
+	session := models.DefaultSessionPolicy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+
+	got, checked, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id))
+	if err != nil || got.Username != user.Username || checked.Id != session.Id || !checked.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Errorf("expected session %+v of %s, got %+v of %+v (%v)", session, user.Username, checked, got, err)
+	}
+
+	refreshed := session
+	refreshed.LastSeenAt = time.Now().Add(time.Hour).In(time.UTC)
+	refreshed.ExpiresAt = session.ExpiresAt.Add(time.Hour)
+	if err := repos.Sessions.Refresh(ctx, refreshed); err != nil {
+		t.Fatalf("failed to refresh the session: %v", err)
+	}
+	if _, checked, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err != nil || !checked.ExpiresAt.Equal(refreshed.ExpiresAt) || !checked.LastSeenAt.Equal(refreshed.LastSeenAt) {
+		t.Errorf("expected session to be refreshed to %+v, got %+v (%v)", refreshed, checked, err)
+	}
+
+	expired := models.DefaultSessionPolicy.NewSession(user.Username)
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour).In(time.UTC)
+	expired.ExpiresAt = time.Now().Add(-time.Hour).In(time.UTC)
+	if err := repos.Sessions.Create(ctx, expired); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(expired.Id)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected expired session to be rejected")
+	}
+	if err := repos.Sessions.Refresh(ctx, expired); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected expired session not to be refreshed, got %v", err)
+	}
+
+	phone := models.DefaultSessionPolicy.NewSession(user.Username)
+	phone.UserAgent, phone.IP = "phone", "10.0.0.1"
+	if err := repos.Sessions.Create(ctx, phone); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+
+	sessions, err := repos.Sessions.GetAll(ctx, user)
+	if err != nil {
+		t.Fatalf("failed to get the sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 unexpired sessions, got %+v", sessions)
+	}
+	for _, s := range sessions {
+		if s.Id == phone.Id && (s.UserAgent != "phone" || s.IP != "10.0.0.1") {
+			t.Errorf("expected the device to be stored, got %+v", s)
+		}
+		if s.LastSeenAt.IsZero() {
+			t.Errorf("expected the session to be seen, got %+v", s)
+		}
+	}
+
+	other := newUser(t, repos)
+	if err := repos.Sessions.Delete(ctx, other, uuid.MustParse(phone.Id)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected session of another user to be not found, got %v", err)
+	}
+	if err := repos.Sessions.Delete(ctx, user, uuid.MustParse(phone.Id)); err != nil {
+		t.Fatalf("failed to delete the session: %v", err)
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(phone.Id)); err == nil {
+		t.Errorf("expected deleted session to be rejected")
+	}
+
+	laptop := models.DefaultSessionPolicy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, laptop); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+	if err := repos.Sessions.DeleteAllExcept(ctx, user, uuid.MustParse(session.Id)); err != nil {
+		t.Fatalf("failed to delete the other sessions: %v", err)
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(laptop.Id)); err == nil {
+		t.Errorf("expected other session to be rejected")
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err != nil {
+		t.Errorf("expected kept session to be accepted, got %v", err)
+	}
+
+	stale := models.DefaultSessionPolicy.NewSession(user.Username)
+	stale.ExpiresAt = time.Now().Add(-time.Minute).In(time.UTC)
+	if err := repos.Sessions.Create(ctx, stale); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+	if count, err := repos.Sessions.DeleteExpired(ctx); err != nil || count < 1 {
+		t.Errorf("expected the expired session to be deleted, got %d (%v)", count, err)
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err != nil {
+		t.Errorf("expected unexpired session to be kept, got %v", err)
+	}
+
+	if err := repos.Sessions.DeleteAll(ctx, user); err != nil {
+		t.Fatalf("failed to delete sessions: %v", err)
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err == nil {
+		t.Errorf("expected deleted session to be rejected")
+	}
+}
+
+func testTokens(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+	other := newUser(t, repos)
+
+	scopes := []string{models.ScopeFilesRead, models.ScopeFilesWrite}
+	token, secret, err := models.NewToken(user.Username, "ci", scopes, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Tokens.Create(ctx, token); err != nil {
+		t.Fatalf("failed to create a token: %v", err)
+	}
+
+	got, checked, err := repos.Tokens.Check(ctx, models.HashToken(secret))
+	if err != nil || got.Username != user.Username || checked.Id != token.Id || !equal(checked.Scopes, scopes) {
+		t.Errorf("expected token %+v of %s, got %+v of %+v (%v)", token, user.Username, checked, got, err)
+	}
+	if _, _, err := repos.Tokens.Check(ctx, models.HashToken(secret+"x")); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected unknown token to be rejected")
+	}
+
+	expired, expiredSecret, _ := models.NewToken(user.Username, "old", scopes, time.Now().Add(-time.Hour))
+	if err := repos.Tokens.Create(ctx, expired); err != nil {
+		t.Fatalf("failed to create a token: %v", err)
+	}
+	if _, _, err := repos.Tokens.Check(ctx, models.HashToken(expiredSecret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected expired token to be rejected")
+	}
+
+	tokens, err := repos.Tokens.GetAll(ctx, user)
+	if err != nil || len(tokens) != 2 {
+		t.Errorf("expected 2 tokens, got %+v (%v)", tokens, err)
+	}
+	if tokens, _ := repos.Tokens.GetAll(ctx, other); len(tokens) != 0 {
+		t.Errorf("expected no tokens of the other user, got %+v", tokens)
+	}
+
+	if err := repos.Tokens.Revoke(ctx, other, uuid.MustParse(token.Id)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected token not to be revoked by another user")
+	}
+	if err := repos.Tokens.Revoke(ctx, user, uuid.MustParse(token.Id)); err != nil {
+		t.Fatalf("failed to revoke the token: %v", err)
+	}
+	if _, _, err := repos.Tokens.Check(ctx, models.HashToken(secret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected revoked token to be rejected")
+	}
+
+	another, anotherSecret, _ := models.NewToken(user.Username, "ci", scopes, time.Now().Add(time.Hour))
+	if err := repos.Tokens.Create(ctx, another); err != nil {
+		t.Fatalf("failed to create a token: %v", err)
+	}
+	if err := repos.Users.Delete(ctx, user); err != nil {
+		t.Fatalf("failed to delete the user: %v", err)
+	}
+	if _, _, err := repos.Tokens.Check(ctx, models.HashToken(anotherSecret)); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected token of deleted user to be rejected")
+	}
+}
+
+func testFiles(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	file := newFile(t, repos, owner, "b", nil)
+	newFile(t, repos, owner, "a", nil)
+
+	if err := repos.Files.Create(ctx, file, owner); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected duplicate file id to be rejected")
+	}
+
+	got, err := repos.Files.GetById(ctx, uuid.MustParse(file.Id))
+	if err != nil || got.Id != file.Id || got.Name != file.Name {
+		t.Errorf("expected %+v, got %+v (%v)", file, got, err)
+	}
+	if _, err := repos.Files.GetById(ctx, uuid.New()); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected unknown file not to be found")
+	}
+
+	if got, err := repos.Files.GetOwner(ctx, file); err != nil || got.Username != owner.Username {
+		t.Errorf("expected owner %s, got %+v (%v)", owner.Username, got, err)
+	}
+
+	if files, err := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{}); err != nil || len(files.Items) != 2 {
+		t.Errorf("expected 2 files, got %+v (%v)", files, err)
+	}
+
+	if content, err := repos.Files.GetContent(ctx, file); err != nil || content.Text != "" {
+		t.Errorf("expected empty content, got %+v (%v)", content, err)
+	}
+
+	content := models.Content{Text: "text", State: "state"}
+	if err := repos.Files.UpdateContent(ctx, file, content, owner); err != nil {
+		t.Fatalf("failed to update the content: %v", err)
+	}
+	if got, err := repos.Files.GetContent(ctx, file); err != nil || got != content {
+		t.Errorf("expected %+v, got %+v (%v)", content, got, err)
+	}
+
+	if err := repos.Files.UpdateName(ctx, file, "c", owner); err != nil {
+		t.Fatalf("failed to update the name: %v", err)
+	}
+	if got, _ := repos.Files.GetById(ctx, uuid.MustParse(file.Id)); got.Name != "c" {
+		t.Errorf("expected name c, got %q", got.Name)
+	}
+	if err := repos.Files.UpdateName(ctx, models.File{Id: uuid.NewString()}, "c", owner); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected renaming unknown file to fail")
+	}
+
+	if err := repos.Files.Delete(ctx, file); err != nil {
+		t.Fatalf("failed to delete the file: %v", err)
+	}
+	if _, err := repos.Files.GetById(ctx, uuid.MustParse(file.Id)); err == nil {
+		t.Errorf("expected deleted file not to be found")
+	}
+
+	if err := repos.Files.DeleteAllForOwner(ctx, owner); err != nil {
+		t.Fatalf("failed to delete files: %v", err)
+	}
+	if files, _ := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{}); len(files.Items) != 0 {
+		t.Errorf("expected no files, got %+v", files)
+	}
+}
+
+func testRevisions(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	file := newFile(t, repos, owner, "file", nil)
+
+	if _, err := repos.Revisions.GetLatest(ctx, file); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected no latest revision, got %v", err)
+	}
+
+	first := models.NewRevision(owner.Username, "first")
+	second := models.NewRevision(owner.Username, "second")
+	second.CreatedAt = first.CreatedAt.Add(time.Second)
+	for _, revision := range []models.Revision{first, second} {
+		if err := repos.Revisions.Create(ctx, file, revision); err != nil {
+			t.Fatalf("failed to create a revision: %v", err)
+		}
+	}
+
+	revisions, err := repos.Revisions.GetAll(ctx, file)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %+v (%v)", revisions, err)
+	}
+	if revisions[0].Id != second.Id || revisions[0].Content != "" {
+		t.Errorf("expected newest revision without content first, got %+v", revisions[0])
+	}
+
+	latest, err := repos.Revisions.GetLatest(ctx, file)
+	if err != nil || latest.Id != second.Id || latest.Content != "second" {
+		t.Errorf("expected newest revision with content, got %+v (%v)", latest, err)
+	}
+
+	got, err := repos.Revisions.GetById(ctx, file, uuid.MustParse(first.Id))
+	if err != nil || got.Content != "first" {
+		t.Errorf("expected revision with content, got %+v (%v)", got, err)
+	}
+	if _, err := repos.Revisions.GetById(ctx, file, uuid.New()); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected unknown revision not to be found")
+	}
+}
+
+func testFolders(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+
+	parent := newFolder(t, repos, owner, "parent", nil)
+	child := newFolder(t, repos, owner, "child", parent)
+	file := newFile(t, repos, owner, "file", child)
+	newFile(t, repos, owner, "root file", nil)
+
+	if err := repos.Folders.Create(ctx, *parent, nil, owner); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected duplicate folder id to be rejected")
+	}
+
+	if got, err := repos.Folders.GetOwner(ctx, *child); err != nil || got.Username != owner.Username {
+		t.Errorf("expected owner %s, got %+v (%v)", owner.Username, got, err)
+	}
+
+	folders, files, err := repos.Folders.GetRootChildren(ctx, owner)
+	if err != nil || len(folders) != 1 || len(files) != 1 || folders[0] != *parent {
+		t.Errorf("unexpected root children: %+v %+v (%v)", folders, files, err)
+	}
+
+	folders, files, err = repos.Folders.GetChildren(ctx, *child)
+	if err != nil || len(folders) != 0 || len(files) != 1 || files[0].Id != file.Id {
+		t.Errorf("unexpected children: %+v %+v (%v)", folders, files, err)
+	}
+
+	if err := repos.Folders.Move(ctx, *parent, child); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected folder not to be moved into its subfolder")
+	}
+	if err := repos.Folders.Move(ctx, *parent, parent); !errors.Is(err, database.ErrConflict) {
+		t.Errorf("expected folder not to be moved into itself")
+	}
+
+	tree, err := repos.Folders.GetTree(ctx, owner)
+	if err != nil {
+		t.Fatalf("failed to get the tree: %v", err)
+	}
+	if len(tree.Folders) != 1 || len(tree.Files) != 1 ||
+		len(tree.Folders[0].Folders) != 1 || len(tree.Folders[0].Folders[0].Files) != 1 {
+		t.Errorf("unexpected tree: %+v", tree)
+	}
+
+	if err := repos.Folders.Move(ctx, *child, nil); err != nil {
+		t.Fatalf("failed to move the folder: %v", err)
+	}
+	if folders, _, _ := repos.Folders.GetRootChildren(ctx, owner); len(folders) != 2 {
+		t.Errorf("expected 2 root folders, got %+v", folders)
+	}
+
+	if err := repos.Folders.UpdateName(ctx, *child, "renamed"); err != nil {
+		t.Fatalf("failed to rename the folder: %v", err)
+	}
+	if got, _ := repos.Folders.GetById(ctx, uuid.MustParse(child.Id)); got.Name != "renamed" {
+		t.Errorf("expected name renamed, got %q", got.Name)
+	}
+
+	if err := repos.Folders.Delete(ctx, *child); err != nil {
+		t.Fatalf("failed to delete the folder: %v", err)
+	}
+	if _, err := repos.Folders.GetById(ctx, uuid.MustParse(child.Id)); err == nil {
+		t.Errorf("expected deleted folder not to be found")
+	}
+	if _, err := repos.Files.GetById(ctx, uuid.MustParse(file.Id)); err == nil {
+		t.Errorf("expected file of the deleted folder not to be found")
+	}
+}
+
+func testAccess(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	receiver := newUser(t, repos)
+
+	folder := newFolder(t, repos, owner, "folder", nil)
+	file := newFile(t, repos, owner, "file", folder)
+
+	if level, err := repos.Access.GetLevel(ctx, file, owner); err != nil || level != models.OwnerAccess {
+		t.Errorf("expected owner access, got %q (%v)", level, err)
+	}
+	if _, err := repos.Access.GetLevel(ctx, file, receiver); !errors.Is(err, database.ErrForbidden) {
+		t.Errorf("expected no access")
+	}
+	if _, err := repos.Access.GetLevel(ctx, models.File{Id: uuid.NewString()}, receiver); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected unknown file not to be found")
+	}
+
+	access := models.Access{Granter: owner.Username, Receiver: receiver.Username, Level: models.RAcess}
+	if err := repos.Access.Grant(ctx, file, models.Access{Level: "X"}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("expected unknown level to be rejected")
+	}
+	if err := repos.Access.GrantForFolder(ctx, *folder, access); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+
+	if level, err := repos.Access.GetLevel(ctx, file, receiver); err != nil || level != models.RAcess {
+		t.Errorf("expected inherited read access, got %q (%v)", level, err)
+	}
+	if _, err := repos.Access.Get(ctx, file, receiver); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected no direct access to the file")
+	}
+
+	direct := access
+	direct.Level = models.RWAccess
+	if err := repos.Access.Grant(ctx, file, direct); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+	if level, err := repos.Access.GetLevel(ctx, file, receiver); err != nil || level != models.RWAccess {
+		t.Errorf("expected read-write access, got %q (%v)", level, err)
+	}
+
+	accesses, err := repos.Access.GetAccesses(ctx, file, database.PageRequest{})
+	if err != nil || len(accesses.Items) != 2 {
+		t.Fatalf("expected 2 accesses, got %+v (%v)", accesses, err)
+	}
+	for _, a := range accesses.Items {
+		if a.Inherited != (a.Source == folder.Id) {
+			t.Errorf("unexpected access: %+v", a)
+		}
+	}
+
+	if err := repos.Access.UpdateLevelForFolder(ctx, *folder, access, models.RWAccess); err != nil {
+		t.Fatalf("failed to update the level: %v", err)
+	}
+	if got, err := repos.Access.GetForFolder(ctx, *folder, receiver); err != nil || got.Level != models.RWAccess {
+		t.Errorf("expected read-write access, got %+v (%v)", got, err)
+	}
+	if err := repos.Access.UpdateLevel(ctx, models.File{Id: uuid.NewString()}, access, models.RWAccess); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected updating unknown access to fail")
+	}
+
+	if err := repos.Access.Revoke(ctx, file, direct); err != nil {
+		t.Fatalf("failed to revoke the access: %v", err)
+	}
+	if err := repos.Access.RevokeForFolder(ctx, *folder, access); err != nil {
+		t.Fatalf("failed to revoke the access: %v", err)
+	}
+	if _, err := repos.Access.GetLevel(ctx, file, receiver); err == nil {
+		t.Errorf("expected no access after revoking")
+	}
+}
+
+func testSharedFiles(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	receiver := newUser(t, repos)
+
+	folder := newFolder(t, repos, owner, "folder", nil)
+	inherited := newFile(t, repos, owner, "a", folder)
+	direct := newFile(t, repos, owner, "b", nil)
+	newFile(t, repos, owner, "c", nil)
+	newFile(t, repos, receiver, "own", nil)
+
+	read := models.Access{Granter: owner.Username, Receiver: receiver.Username, Level: models.RAcess}
+	readWrite := read
+	readWrite.Level = models.RWAccess
+
+	if err := repos.Access.GrantForFolder(ctx, *folder, read); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+	if err := repos.Access.Grant(ctx, inherited, readWrite); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := repos.Access.Grant(ctx, direct, read); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+
+	files, err := repos.Files.GetAllSharedWith(ctx, receiver, "", database.PageRequest{SortBy: database.SortByName})
+	if err != nil || len(files.Items) != 2 {
+		t.Fatalf("expected 2 shared files, got %+v (%v)", files, err)
+	}
+	if f := files.Items[0]; f.Id != inherited.Id || f.Level != models.RWAccess || f.Owner != owner.Username {
+		t.Errorf("unexpected shared file: %+v", f)
+	}
+
+	files, err = repos.Files.GetAllSharedWith(ctx, receiver, "", database.PageRequest{SortBy: database.SortByGrantedAt})
+	if err != nil || len(files.Items) != 2 || files.Items[0].Id != direct.Id {
+		t.Errorf("expected the latest grant first, got %+v (%v)", files, err)
+	}
+
+	files, err = repos.Files.GetAllSharedWith(ctx, receiver, models.RAcess, database.PageRequest{SortBy: database.SortByName})
+	if err != nil || len(files.Items) != 1 || files.Items[0].Id != direct.Id {
+		t.Errorf("expected only read-only files, got %+v (%v)", files, err)
+	}
+
+	if _, err := repos.Files.GetAllSharedWith(ctx, receiver, "", database.PageRequest{SortBy: "size"}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("expected unknown sort key to be rejected")
+	}
+}
+
+func testPagination(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+
+	// NOTE: Equal names make sure the ties are broken by the id
+	names := []string{"d", "b", "a", "b", "c"}
+	for _, name := range names {
+		newFile(t, repos, owner, name, nil)
+	}
+
+	var (
+		got   []string
+		after string
+		pages int
+	)
+	for {
+		page, err := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{Limit: 2, After: after})
+		if err != nil {
+			t.Fatalf("failed to get the page: %v", err)
+		}
+		if len(page.Items) > 2 {
+			t.Fatalf("expected at most 2 files, got %+v", page.Items)
+		}
+		for _, f := range page.Items {
+			got = append(got, f.Name)
+		}
+
+		pages++
+		if page.Next == "" {
+			break
+		}
+		after = page.Next
+	}
+	if want := []string{"a", "b", "b", "c", "d"}; pages != 3 || !equal(got, want) {
+		t.Errorf("expected %v in 3 pages, got %v in %d", want, got, pages)
+	}
+
+	if _, err := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{After: "not a cursor"}); !errors.Is(err, database.ErrValidation) {
+		t.Errorf("expected invalid cursor to be rejected")
+	}
+
+	file := newFile(t, repos, owner, "shared", nil)
+	receivers := []string{}
+	for i := 0; i < 3; i++ {
+		receiver := newUser(t, repos)
+		receivers = append(receivers, receiver.Username)
+		access := models.Access{Granter: owner.Username, Receiver: receiver.Username, Level: models.RAcess}
+		if err := repos.Access.Grant(ctx, file, access); err != nil {
+			t.Fatalf("failed to grant an access: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	first, err := repos.Access.GetAccesses(ctx, file, database.PageRequest{Limit: 2, SortBy: database.SortByGrantedAt})
+	if err != nil || len(first.Items) != 2 || first.Next == "" {
+		t.Fatalf("expected the first page of 2 accesses, got %+v (%v)", first, err)
+	}
+	second, err := repos.Access.GetAccesses(ctx, file, database.PageRequest{Limit: 2, SortBy: database.SortByGrantedAt, After: first.Next})
+	if err != nil || len(second.Items) != 1 || second.Next != "" {
+		t.Fatalf("expected the last page of 1 access, got %+v (%v)", second, err)
+	}
+	got = []string{first.Items[0].Receiver, first.Items[1].Receiver, second.Items[0].Receiver}
+	if want := []string{receivers[2], receivers[1], receivers[0]}; !equal(got, want) {
+		t.Errorf("expected the latest grants first %v, got %v", want, got)
+	}
+
+	_, err = repos.Access.GetAccesses(ctx, file, database.PageRequest{SortBy: database.SortByName, After: first.Next})
+	if !errors.Is(err, database.ErrValidation) {
+		t.Errorf("expected cursor of another sort to be rejected")
+	}
+}
+
+func testFileTimestamps(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	editor := newUser(t, repos)
+
+	start := time.Now().Add(-time.Second)
+	first := newFile(t, repos, owner, "first", nil)
+	time.Sleep(10 * time.Millisecond)
+	second := newFile(t, repos, owner, "second", nil)
+
+	created, err := repos.Files.GetById(ctx, uuid.MustParse(first.Id))
+	if err != nil {
+		t.Fatalf("failed to get the file: %v", err)
+	}
+	if created.CreatedAt.Before(start) || !created.UpdatedAt.Equal(created.CreatedAt) || created.LastEditedBy != owner.Username {
+		t.Errorf("expected new file to be created and edited by %s, got %+v", owner.Username, created)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := repos.Files.UpdateContent(ctx, first, models.Content{Text: "text"}, editor); err != nil {
+		t.Fatalf("failed to update the content: %v", err)
+	}
+	updated, _ := repos.Files.GetById(ctx, uuid.MustParse(first.Id))
+	if !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.UpdatedAt) || updated.LastEditedBy != editor.Username {
+		t.Errorf("expected the content update by %s to be tracked, got %+v", editor.Username, updated)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := repos.Files.UpdateName(ctx, first, "renamed", owner); err != nil {
+		t.Fatalf("failed to update the name: %v", err)
+	}
+	renamed, _ := repos.Files.GetById(ctx, uuid.MustParse(first.Id))
+	if !renamed.UpdatedAt.After(updated.UpdatedAt) || renamed.LastEditedBy != owner.Username {
+		t.Errorf("expected the rename by %s to be tracked, got %+v", owner.Username, renamed)
+	}
+
+	names := func(filter database.FileFilter, sortBy string) []string {
+		page, err := repos.Files.GetAllForOwner(ctx, owner, filter, database.PageRequest{SortBy: sortBy})
+		if err != nil {
+			t.Fatalf("failed to get the files: %v", err)
+		}
+		got := []string{}
+		for _, f := range page.Items {
+			got = append(got, f.Name)
+		}
+		return got
+	}
+
+	if got, want := names(database.FileFilter{}, database.SortByCreatedAt), []string{"second", "renamed"}; !equal(got, want) {
+		t.Errorf("expected %v sorted by creation time, got %v", want, got)
+	}
+	if got, want := names(database.FileFilter{}, database.SortByUpdatedAt), []string{"renamed", "second"}; !equal(got, want) {
+		t.Errorf("expected %v sorted by update time, got %v", want, got)
+	}
+
+	if got, want := names(database.FileFilter{CreatedAfter: created.CreatedAt}, ""), []string{"second"}; !equal(got, want) {
+		t.Errorf("expected %v created after the first file, got %v", want, got)
+	}
+	if got, want := names(database.FileFilter{UpdatedBefore: renamed.UpdatedAt}, ""), []string{"second"}; !equal(got, want) {
+		t.Errorf("expected %v updated before the rename, got %v", want, got)
+	}
+
+	if err := repos.Files.UpdateName(ctx, second, "edited", editor); err != nil {
+		t.Fatalf("failed to update the name: %v", err)
+	}
+	if got, want := names(database.FileFilter{LastEditedBy: editor.Username}, ""), []string{"edited"}; !equal(got, want) {
+		t.Errorf("expected %v last edited by %s, got %v", want, editor.Username, got)
+	}
+
+	page, err := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{Limit: 1, SortBy: database.SortByUpdatedAt})
+	if err != nil || page.Next == "" {
+		t.Fatalf("expected the first page to have a next one, got %+v (%v)", page, err)
+	}
+	next, err := repos.Files.GetAllForOwner(ctx, owner, database.FileFilter{}, database.PageRequest{Limit: 1, SortBy: database.SortByUpdatedAt, After: page.Next})
+	if err != nil || len(next.Items) != 1 || next.Items[0].Name != "renamed" {
+		t.Errorf("expected the second page to hold the renamed file, got %+v (%v)", next, err)
+	}
+}
+
+func testSearch(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	user := newUser(t, repos)
+	other := newUser(t, repos)
+
+	// NOTE: Unique word keeps the files of the other tests out of the results
+	word := "zx" + uuid.NewString()[:8]
+
+	owned := newFile(t, repos, user, "Budget "+word, nil)
+	content := models.Content{Text: "Quarterly numbers. The " + word + " is <approved>."}
+	if err := repos.Files.UpdateContent(ctx, owned, content, user); err != nil {
+		t.Fatalf("failed to update the content: %v", err)
+	}
+
+	folder := newFolder(t, repos, other, "shared", nil)
+	shared := newFile(t, repos, other, "Notes on "+word, folder)
+	access := models.Access{Granter: other.Username, Receiver: user.Username, Level: models.RAcess}
+	if err := repos.Access.GrantForFolder(ctx, *folder, access); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+
+	newFile(t, repos, other, "Private "+word, nil)
+
+	results, err := repos.Search.Search(ctx, user, strings.ToUpper(word), 0)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+
+	// NOTE: Scores depend on the search engine, only their order is checked
+	byId := make(map[string]models.SearchResult)
+	for i, r := range results {
+		byId[r.File.Id] = r
+		if i > 0 && r.Score > results[i-1].Score {
+			t.Errorf("expected results to be sorted by the score, got %+v", results)
+		}
+	}
+	if len(results) != 2 || byId[owned.Id].Owner != user.Username || byId[shared.Id].Owner != other.Username {
+		t.Fatalf("expected owned and shared files, got %+v", results)
+	}
+
+	if want, got := []string{"Budget <mark>" + word + "</mark>", "Quarterly numbers. The <mark>" + word + "</mark> is &lt;approved&gt;."}, byId[owned.Id].Snippets; !equal(got, want) {
+		t.Errorf("expected snippets %q, got %q", want, got)
+	}
+
+	if results, err := repos.Search.Search(ctx, user, word, 1); err != nil || len(results) != 1 {
+		t.Errorf("expected a single result, got %+v (%v)", results, err)
+	}
+	if results, err := repos.Search.Search(ctx, other, "zz"+word, 0); err != nil || len(results) != 0 {
+		t.Errorf("expected no results, got %+v (%v)", results, err)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NOTE: Either move is fine on its own, but together they would make a cycle
+// (d contains a contains b contains c contains d), so one of them must be rejected
+func testConcurrentMoves(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+
+	for i := 0; i < 10; i++ {
+		a := newFolder(t, repos, owner, "a", nil)
+		b := newFolder(t, repos, owner, "b", a)
+		c := newFolder(t, repos, owner, "c", nil)
+		d := newFolder(t, repos, owner, "d", c)
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		wg.Add(2)
+		go func() { defer wg.Done(); errs[0] = repos.Folders.Move(ctx, *a, d) }()
+		go func() { defer wg.Done(); errs[1] = repos.Folders.Move(ctx, *c, b) }()
+		wg.Wait()
+
+		if errs[0] == nil && errs[1] == nil {
+			t.Fatalf("expected one of the moves making a cycle to be rejected")
+		}
+	}
+}
+
+func testCascadeDelete(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	owner := newUser(t, repos)
+	receiver := newUser(t, repos)
+
+	folder := newFolder(t, repos, owner, "folder", nil)
+	file := newFile(t, repos, owner, "file", folder)
+	session := models.DefaultSessionPolicy.NewSession(owner.Username)
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatalf("failed to create a session: %v", err)
+	}
+	token, secret, _ := models.NewToken(owner.Username, "ci", []string{models.ScopeFilesRead}, time.Now().Add(time.Hour))
+	if err := repos.Tokens.Create(ctx, token); err != nil {
+		t.Fatalf("failed to create a token: %v", err)
+	}
+	reset, resetSecret, _ := models.NewPasswordReset(owner.Username, time.Hour)
+	if err := repos.Resets.Create(ctx, reset); err != nil {
+		t.Fatalf("failed to create a password reset: %v", err)
+	}
+
+	access := models.Access{Granter: owner.Username, Receiver: receiver.Username, Level: models.RAcess}
+	if err := repos.Access.Grant(ctx, file, access); err != nil {
+		t.Fatalf("failed to grant an access: %v", err)
+	}
+
+	if err := repos.Users.Delete(ctx, owner); err != nil {
+		t.Fatalf("failed to delete the user: %v", err)
+	}
+
+	if _, err := repos.Users.GetByUsername(ctx, owner.Username); err == nil {
+		t.Errorf("expected deleted user not to be found")
+	}
+	if _, err := repos.Files.GetById(ctx, uuid.MustParse(file.Id)); err == nil {
+		t.Errorf("expected file of the deleted user not to be found")
+	}
+	if _, err := repos.Folders.GetById(ctx, uuid.MustParse(folder.Id)); err == nil {
+		t.Errorf("expected folder of the deleted user not to be found")
+	}
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err == nil {
+		t.Errorf("expected session of the deleted user to be rejected")
+	}
+	if _, _, err := repos.Tokens.Check(ctx, models.HashToken(secret)); err == nil {
+		t.Errorf("expected token of the deleted user to be rejected")
+	}
+	if _, err := repos.Resets.Use(ctx, models.HashToken(resetSecret)); err == nil {
+		t.Errorf("expected password reset of the deleted user to be rejected")
+	}
+	if files, _ := repos.Files.GetAllSharedWith(ctx, receiver, "", database.PageRequest{}); len(files.Items) != 0 {
+		t.Errorf("expected no shared files, got %+v", files)
+	}
+}
+
+func testTransactions(t *testing.T, repos database.Repositories) {
+	ctx := context.Background()
+	committed := models.User{Username: "user-" + uuid.NewString()}
+	rolledBack := models.User{Username: "user-" + uuid.NewString()}
+
+	err := repos.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+		return repos.Users.Create(ctx, committed)
+	})
+	if err != nil {
+		t.Fatalf("failed to commit the transaction: %v", err)
+	}
+	if _, err := repos.Users.GetByUsername(ctx, committed.Username); err != nil {
+		t.Errorf("expected committed user to be found")
+	}
+
+	errAbort := errors.New("abort")
+	err = repos.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+		if err := repos.Users.Create(ctx, rolledBack); err != nil {
+			return err
+		}
+		if _, err := repos.Users.GetByUsername(ctx, rolledBack.Username); err != nil {
+			t.Errorf("expected user to be visible inside the transaction")
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("expected the function's error, got %v", err)
+	}
+	if _, err := repos.Users.GetByUsername(ctx, rolledBack.Username); err == nil {
+		t.Errorf("expected rolled back user not to be found")
+	}
+}
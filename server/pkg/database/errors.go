@@ -0,0 +1,55 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NOTE: Repositories return errors of these kinds, use errors.Is to check the kind
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation")
+	ErrInternal   = errors.New("internal")
+)
+
+// Error is an error of one of the kinds with a message that is safe to show to the user.
+// Internal errors keep the cause, which is only meant for the logs
+type Error struct {
+	Kind    error
+	Message string
+	Cause   error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+func NotFound(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+func Internal(cause error, format string, args ...any) error {
+	return &Error{Kind: ErrInternal, Message: fmt.Sprintf(format, args...), Cause: cause}
+}
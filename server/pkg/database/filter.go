@@ -0,0 +1,25 @@
+package database
+
+import (
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+// FileFilter narrows a file listing down, zero fields match any file.
+// Time bounds are exclusive
+type FileFilter struct {
+	LastEditedBy  string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+func (f FileFilter) Matches(file models.File) bool {
+	return (f.LastEditedBy == "" || file.LastEditedBy == f.LastEditedBy) &&
+		(f.CreatedAfter.IsZero() || file.CreatedAt.After(f.CreatedAfter)) &&
+		(f.CreatedBefore.IsZero() || file.CreatedAt.Before(f.CreatedBefore)) &&
+		(f.UpdatedAfter.IsZero() || file.UpdatedAt.After(f.UpdatedAfter)) &&
+		(f.UpdatedBefore.IsZero() || file.UpdatedAt.Before(f.UpdatedBefore))
+}
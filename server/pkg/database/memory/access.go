@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type accessService struct {
+	store *store
+}
+
+func (s accessService) Grant(ctx context.Context, f models.File, access models.Access) error {
+	return s.grant(ctx, target{id: f.Id}, access)
+}
+
+func (s accessService) GrantForFolder(ctx context.Context, f models.Folder, access models.Access) error {
+	return s.grant(ctx, target{id: f.Id, folder: true}, access)
+}
+
+func (s accessService) grant(ctx context.Context, t target, access models.Access) error {
+	defer s.store.lock(ctx)()
+
+	switch access.Level {
+	case models.RWAccess, models.RAcess:
+	default:
+		return database.Validation("unknown access level value: %s", access.Level)
+	}
+
+	d := &s.store.data
+	if _, ok := d.users[access.Receiver]; !ok || !d.exists(t) {
+		return nil
+	}
+
+	d.grants = append(d.grants, grant{
+		target:    t,
+		receiver:  access.Receiver,
+		granter:   access.Granter,
+		level:     access.Level,
+		grantedAt: time.Now().In(time.UTC),
+	})
+	return nil
+}
+
+// NOTE: Only the access granted to the file directly is returned
+func (s accessService) Get(ctx context.Context, f models.File, user models.User) (models.Access, error) {
+	return s.get(ctx, target{id: f.Id}, user)
+}
+
+func (s accessService) GetForFolder(ctx context.Context, f models.Folder, user models.User) (models.Access, error) {
+	return s.get(ctx, target{id: f.Id, folder: true}, user)
+}
+
+func (s accessService) get(ctx context.Context, t target, user models.User) (models.Access, error) {
+	defer s.store.lock(ctx)()
+
+	for _, g := range s.store.data.grants {
+		if g.target == t && g.receiver == user.Username {
+			return g.access(false), nil
+		}
+	}
+
+	return models.Access{}, database.NotFound("access wasn't found")
+}
+
+// NOTE: Both direct and inherited accesses are returned
+func (s accessService) GetAccesses(ctx context.Context, f models.File, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.getAccesses(ctx, target{id: f.Id}, page)
+}
+
+func (s accessService) GetAccessesForFolder(ctx context.Context, f models.Folder, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.getAccesses(ctx, target{id: f.Id, folder: true}, page)
+}
+
+func (s accessService) getAccesses(ctx context.Context, t target, page database.PageRequest) (database.Page[models.Access], error) {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	accesses := []models.Access{}
+	if !d.exists(t) {
+		return paginate(accesses, page, database.AccessSorts)
+	}
+
+	for _, source := range d.path(t) {
+		for _, g := range d.grants {
+			if g.target == source {
+				accesses = append(accesses, g.access(source != t))
+			}
+		}
+	}
+
+	return paginate(accesses, page, database.AccessSorts)
+}
+
+// GetLevel resolves the effective access level of the user to the file,
+// taking ownership and the accesses inherited from the folders into account
+func (s accessService) GetLevel(ctx context.Context, f models.File, user models.User) (string, error) {
+	return s.getLevel(ctx, target{id: f.Id}, user)
+}
+
+func (s accessService) GetLevelForFolder(ctx context.Context, f models.Folder, user models.User) (string, error) {
+	return s.getLevel(ctx, target{id: f.Id, folder: true}, user)
+}
+
+func (s accessService) getLevel(ctx context.Context, t target, user models.User) (string, error) {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	var owner string
+	if t.folder {
+		f, ok := d.folders[t.id]
+		if !ok {
+			return "", database.NotFound("folder wasn't found")
+		}
+		owner = f.owner
+	} else {
+		f, ok := d.files[t.id]
+		if !ok {
+			return "", database.NotFound("file wasn't found")
+		}
+		owner = f.owner
+	}
+
+	if _, ok := d.users[owner]; ok && owner == user.Username {
+		return models.OwnerAccess, nil
+	}
+
+	level := ""
+	for _, source := range d.path(t) {
+		for _, g := range d.grants {
+			if g.target != source || g.receiver != user.Username {
+				continue
+			}
+			if g.level == models.RWAccess || level == "" {
+				level = g.level
+			}
+		}
+	}
+
+	if level == "" {
+		return "", database.Forbidden("access wasn't found")
+	}
+
+	return level, nil
+}
+
+func (s accessService) UpdateLevel(ctx context.Context, f models.File, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, target{id: f.Id}, access, newLevel)
+}
+
+func (s accessService) UpdateLevelForFolder(ctx context.Context, f models.Folder, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, target{id: f.Id, folder: true}, access, newLevel)
+}
+
+func (s accessService) updateLevel(ctx context.Context, t target, access models.Access, newLevel string) error {
+	defer s.store.lock(ctx)()
+
+	count := 0
+	for i, g := range s.store.data.grants {
+		if g.matches(t, access) {
+			s.store.data.grants[i].level = newLevel
+			count++
+		}
+	}
+
+	if count <= 0 {
+		return database.NotFound("access record wasn't found")
+	}
+
+	return nil
+}
+
+func (s accessService) Revoke(ctx context.Context, f models.File, access models.Access) error {
+	return s.revoke(ctx, target{id: f.Id}, access)
+}
+
+func (s accessService) RevokeForFolder(ctx context.Context, f models.Folder, access models.Access) error {
+	return s.revoke(ctx, target{id: f.Id, folder: true}, access)
+}
+
+func (s accessService) revoke(ctx context.Context, t target, access models.Access) error {
+	defer s.store.lock(ctx)()
+
+	s.store.data.deleteGrants(func(g grant) bool { return g.matches(t, access) })
+	return nil
+}
+
+func (g grant) matches(t target, access models.Access) bool {
+	return g.target == t && g.receiver == access.Receiver && g.granter == access.Granter
+}
+
+func (g grant) access(inherited bool) models.Access {
+	return models.Access{
+		Granter:   g.granter,
+		Receiver:  g.receiver,
+		Level:     g.level,
+		Inherited: inherited,
+		Source:    g.id,
+		GrantedAt: g.grantedAt,
+	}
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type fileService struct {
+	store *store
+}
+
+func (s fileService) Create(ctx context.Context, f models.File, owner models.User) error {
+	defer s.store.lock(ctx)()
+
+	if _, ok := s.store.data.files[f.Id]; ok {
+		return database.Conflict("file with this id already exists")
+	}
+	if _, ok := s.store.data.users[owner.Username]; !ok {
+		return nil
+	}
+
+	now := time.Now().In(time.UTC)
+	f.CreatedAt, f.UpdatedAt, f.LastEditedBy = now, now, owner.Username
+	s.store.data.files[f.Id] = file{File: f, owner: owner.Username}
+	return nil
+}
+
+func (s fileService) GetById(ctx context.Context, id uuid.UUID) (models.File, error) {
+	defer s.store.lock(ctx)()
+
+	f, ok := s.store.data.files[id.String()]
+	if !ok {
+		return models.File{}, database.NotFound("file wasn't found")
+	}
+
+	return f.File, nil
+}
+
+func (s fileService) GetOwner(ctx context.Context, f models.File) (models.User, error) {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.files[f.Id]
+	if !ok {
+		return models.User{}, database.NotFound("owner wasn't found")
+	}
+
+	owner, ok := s.store.data.users[stored.owner]
+	if !ok {
+		return models.User{}, database.NotFound("owner wasn't found")
+	}
+
+	return owner, nil
+}
+
+// NOTE: Every file of every user, used to rebuild the search index
+func (s fileService) GetAll(ctx context.Context, page database.PageRequest) (database.Page[models.File], error) {
+	defer s.store.lock(ctx)()
+
+	files := []models.File{}
+	for _, f := range s.store.data.files {
+		files = append(files, f.File)
+	}
+
+	return paginate(files, page, database.FileSorts)
+}
+
+func (s fileService) GetAllForOwner(ctx context.Context, owner models.User, filter database.FileFilter, page database.PageRequest) (database.Page[models.File], error) {
+	defer s.store.lock(ctx)()
+
+	files := []models.File{}
+	for _, f := range s.store.data.files {
+		if f.owner == owner.Username && filter.Matches(f.File) {
+			files = append(files, f.File)
+		}
+	}
+
+	return paginate(files, page, database.FileSorts)
+}
+
+// GetAllSharedWith returns files other users shared with the user. Level filters the files
+// by the access level (empty level matches any), they are sorted either by database.SortByName or database.SortByGrantedAt.
+func (s fileService) GetAllSharedWith(ctx context.Context, user models.User, level string, page database.PageRequest) (database.Page[models.SharedFile], error) {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	files := []models.SharedFile{}
+	for id, f := range d.files {
+		if _, ok := d.users[f.owner]; !ok || f.owner == user.Username {
+			continue
+		}
+
+		// NOTE: Only the most permissive grant is kept (the latest one among equals)
+		var best *grant
+		for _, t := range d.path(target{id: id}) {
+			for i, g := range d.grants {
+				if g.target != t || g.receiver != user.Username {
+					continue
+				}
+				if best == nil || morePermissive(g, *best) {
+					best = &d.grants[i]
+				}
+			}
+		}
+
+		if best == nil || (level != "" && best.level != level) {
+			continue
+		}
+
+		files = append(files, models.SharedFile{
+			Id:        f.Id,
+			Name:      f.Name,
+			Owner:     f.owner,
+			Level:     best.level,
+			GrantedBy: best.granter,
+			GrantedAt: best.grantedAt,
+		})
+	}
+
+	return paginate(files, page, database.SharedFileSorts)
+}
+
+func morePermissive(a, b grant) bool {
+	if a.level != b.level {
+		return a.level == models.RWAccess
+	}
+	return a.grantedAt.After(b.grantedAt)
+}
+
+func (s fileService) GetContent(ctx context.Context, f models.File) (models.Content, error) {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.files[f.Id]
+	if !ok {
+		return models.Content{}, database.NotFound("file wasn't found")
+	}
+
+	return stored.content, nil
+}
+
+func (s fileService) UpdateName(ctx context.Context, f models.File, name string, editor models.User) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.files[f.Id]
+	if !ok {
+		return database.NotFound("file wasn't found")
+	}
+
+	stored.Name = name
+	stored.UpdatedAt, stored.LastEditedBy = time.Now().In(time.UTC), editor.Username
+	s.store.data.files[f.Id] = stored
+	return nil
+}
+
+func (s fileService) UpdateContent(ctx context.Context, f models.File, content models.Content, editor models.User) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.files[f.Id]
+	if !ok {
+		return database.NotFound("file wasn't found")
+	}
+
+	stored.content = content
+	stored.UpdatedAt, stored.LastEditedBy = time.Now().In(time.UTC), editor.Username
+	s.store.data.files[f.Id] = stored
+	return nil
+}
+
+func (s fileService) Delete(ctx context.Context, f models.File) error {
+	defer s.store.lock(ctx)()
+
+	s.store.data.deleteFile(f.Id)
+	return nil
+}
+
+func (s fileService) DeleteAllForOwner(ctx context.Context, owner models.User) error {
+	defer s.store.lock(ctx)()
+
+	for id, f := range s.store.data.files {
+		if f.owner == owner.Username {
+			s.store.data.deleteFile(id)
+		}
+	}
+
+	return nil
+}
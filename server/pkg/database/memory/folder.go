@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type folderService struct {
+	store *store
+}
+
+// NOTE: Folder is created in the root, if parent is nil
+func (s folderService) Create(ctx context.Context, f models.Folder, parent *models.Folder, owner models.User) error {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	if _, ok := d.folders[f.Id]; ok {
+		return database.Conflict("folder with this id already exists")
+	}
+	if _, ok := d.users[owner.Username]; !ok {
+		return nil
+	}
+
+	stored := folder{Folder: f, owner: owner.Username}
+	if parent != nil {
+		if _, ok := d.folders[parent.Id]; !ok {
+			return nil
+		}
+		stored.parent = parent.Id
+	}
+
+	d.folders[f.Id] = stored
+	return nil
+}
+
+func (s folderService) GetById(ctx context.Context, id uuid.UUID) (models.Folder, error) {
+	defer s.store.lock(ctx)()
+
+	f, ok := s.store.data.folders[id.String()]
+	if !ok {
+		return models.Folder{}, database.NotFound("folder wasn't found")
+	}
+
+	return f.Folder, nil
+}
+
+func (s folderService) GetOwner(ctx context.Context, f models.Folder) (models.User, error) {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.folders[f.Id]
+	if !ok {
+		return models.User{}, database.NotFound("owner wasn't found")
+	}
+
+	owner, ok := s.store.data.users[stored.owner]
+	if !ok {
+		return models.User{}, database.NotFound("owner wasn't found")
+	}
+
+	return owner, nil
+}
+
+func (s folderService) GetChildren(ctx context.Context, f models.Folder) ([]models.Folder, []models.File, error) {
+	defer s.store.lock(ctx)()
+
+	folders, files := s.children(func(parent, _ string) bool { return parent == f.Id })
+	return folders, files, nil
+}
+
+// NOTE: Root children are owner's folders and files that aren't contained in any folder
+func (s folderService) GetRootChildren(ctx context.Context, owner models.User) ([]models.Folder, []models.File, error) {
+	defer s.store.lock(ctx)()
+
+	folders, files := s.children(func(parent, childOwner string) bool {
+		return parent == "" && childOwner == owner.Username
+	})
+	return folders, files, nil
+}
+
+func (s folderService) children(match func(parent, owner string) bool) ([]models.Folder, []models.File) {
+	folders := []models.Folder{}
+	for _, f := range s.store.data.folders {
+		if match(f.parent, f.owner) {
+			folders = append(folders, f.Folder)
+		}
+	}
+
+	files := []models.File{}
+	for _, f := range s.store.data.files {
+		if match(f.parent, f.owner) {
+			files = append(files, f.File)
+		}
+	}
+
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Name < folders[j].Name })
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return folders, files
+}
+
+func (s folderService) GetTree(ctx context.Context, owner models.User) (models.FolderTree, error) {
+	defer s.store.lock(ctx)()
+
+	childFolders := make(map[string][]models.Folder)
+	for _, f := range s.store.data.folders {
+		if f.owner == owner.Username {
+			childFolders[f.parent] = append(childFolders[f.parent], f.Folder)
+		}
+	}
+
+	childFiles := make(map[string][]models.File)
+	for _, f := range s.store.data.files {
+		if f.owner == owner.Username {
+			childFiles[f.parent] = append(childFiles[f.parent], f.File)
+		}
+	}
+
+	var build func(folder *models.Folder) models.FolderTree
+	build = func(folder *models.Folder) models.FolderTree {
+		id := ""
+		if folder != nil {
+			id = folder.Id
+		}
+
+		tree := models.FolderTree{Folder: folder, Folders: []models.FolderTree{}, Files: childFiles[id]}
+		if tree.Files == nil {
+			tree.Files = []models.File{}
+		}
+		sort.Slice(tree.Files, func(i, j int) bool { return tree.Files[i].Name < tree.Files[j].Name })
+
+		children := childFolders[id]
+		sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+		for _, child := range children {
+			child := child
+			tree.Folders = append(tree.Folders, build(&child))
+		}
+		return tree
+	}
+
+	return build(nil), nil
+}
+
+func (s folderService) UpdateName(ctx context.Context, f models.Folder, name string) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.folders[f.Id]
+	if !ok {
+		return database.NotFound("folder wasn't found")
+	}
+
+	stored.Name = name
+	s.store.data.folders[f.Id] = stored
+	return nil
+}
+
+// NOTE: Folder is moved to the root, if parent is nil.
+// Folder can't be moved into itself or into any of its subfolders
+func (s folderService) Move(ctx context.Context, f models.Folder, parent *models.Folder) error {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	stored, ok := d.folders[f.Id]
+	if !ok {
+		return database.Conflict("folder can't be moved into itself or its subfolder")
+	}
+
+	stored.parent = ""
+	if parent != nil {
+		if _, ok := d.folders[parent.Id]; !ok {
+			return database.Conflict("folder can't be moved into itself or its subfolder")
+		}
+		for _, t := range d.path(target{id: parent.Id, folder: true}) {
+			if t.id == f.Id {
+				return database.Conflict("folder can't be moved into itself or its subfolder")
+			}
+		}
+		stored.parent = parent.Id
+	}
+
+	d.folders[f.Id] = stored
+	return nil
+}
+
+// NOTE: File is moved to the root, if parent is nil
+func (s folderService) MoveFile(ctx context.Context, f models.File, parent *models.Folder) error {
+	defer s.store.lock(ctx)()
+
+	d := s.store.data
+
+	stored, ok := d.files[f.Id]
+	if !ok {
+		return database.NotFound("file wasn't found")
+	}
+
+	stored.parent = ""
+	if parent != nil {
+		if _, ok := d.folders[parent.Id]; !ok {
+			return database.NotFound("file wasn't found")
+		}
+		stored.parent = parent.Id
+	}
+
+	d.files[f.Id] = stored
+	return nil
+}
+
+// NOTE: Everything the folder contains is deleted as well
+func (s folderService) Delete(ctx context.Context, f models.Folder) error {
+	defer s.store.lock(ctx)()
+
+	s.store.data.deleteFolder(f.Id)
+	return nil
+}
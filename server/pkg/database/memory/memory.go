@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type file struct {
+	models.File
+	owner     string
+	parent    string
+	content   models.Content
+	revisions []models.Revision
+}
+
+type folder struct {
+	models.Folder
+	owner  string
+	parent string
+}
+
+// NOTE: Target is either a file or a folder, the same id can be used by both
+type target struct {
+	id     string
+	folder bool
+}
+
+type grant struct {
+	target
+	receiver  string
+	granter   string
+	level     string
+	grantedAt time.Time
+}
+
+type data struct {
+	users    map[string]models.User
+	files    map[string]file
+	folders  map[string]folder
+	grants   []grant
+	sessions map[string]models.Session
+	tokens   map[string]models.Token
+	resets   map[string]models.PasswordReset
+}
+
+func (d data) clone() data {
+	clone := data{
+		users:    make(map[string]models.User, len(d.users)),
+		files:    make(map[string]file, len(d.files)),
+		folders:  make(map[string]folder, len(d.folders)),
+		grants:   append([]grant{}, d.grants...),
+		sessions: make(map[string]models.Session, len(d.sessions)),
+		tokens:   make(map[string]models.Token, len(d.tokens)),
+		resets:   make(map[string]models.PasswordReset, len(d.resets)),
+	}
+	for k, v := range d.users {
+		clone.users[k] = v
+	}
+	for k, v := range d.files {
+		v.revisions = append([]models.Revision{}, v.revisions...)
+		clone.files[k] = v
+	}
+	for k, v := range d.folders {
+		clone.folders[k] = v
+	}
+	for k, v := range d.sessions {
+		clone.sessions[k] = v
+	}
+	for k, v := range d.tokens {
+		clone.tokens[k] = v
+	}
+	for k, v := range d.resets {
+		clone.resets[k] = v
+	}
+	return clone
+}
+
+func (d data) exists(t target) bool {
+	if t.folder {
+		_, ok := d.folders[t.id]
+		return ok
+	}
+	_, ok := d.files[t.id]
+	return ok
+}
+
+// path returns the target followed by all the folders containing it, innermost first
+func (d data) path(t target) []target {
+	path := []target{t}
+
+	var parent string
+	if t.folder {
+		parent = d.folders[t.id].parent
+	} else {
+		parent = d.files[t.id].parent
+	}
+
+	for parent != "" {
+		path = append(path, target{id: parent, folder: true})
+		parent = d.folders[parent].parent
+	}
+	return path
+}
+
+// canAccess tells whether the user owns the target or was granted an access
+// to it or to one of the folders containing it
+func (d data) canAccess(t target, username string) bool {
+	owner := d.files[t.id].owner
+	if t.folder {
+		owner = d.folders[t.id].owner
+	}
+	if owner == username {
+		return true
+	}
+
+	for _, p := range d.path(t) {
+		for _, g := range d.grants {
+			if g.target == p && g.receiver == username {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *data) deleteFile(id string) {
+	delete(d.files, id)
+	d.deleteGrants(func(g grant) bool { return g.target == target{id: id} })
+}
+
+// NOTE: Everything the folder contains is deleted as well
+func (d *data) deleteFolder(id string) {
+	for childId, child := range d.folders {
+		if child.parent == id {
+			d.deleteFolder(childId)
+		}
+	}
+	for childId, child := range d.files {
+		if child.parent == id {
+			d.deleteFile(childId)
+		}
+	}
+
+	delete(d.folders, id)
+	d.deleteGrants(func(g grant) bool { return g.target == target{id: id, folder: true} })
+}
+
+func (d *data) deleteGrants(match func(g grant) bool) {
+	grants := d.grants[:0]
+	for _, g := range d.grants {
+		if !match(g) {
+			grants = append(grants, g)
+		}
+	}
+	d.grants = grants
+}
+
+type store struct {
+	mu   sync.Mutex
+	data data
+}
+
+type transactionKey struct{}
+
+// NOTE: Store stays locked for the whole transaction,
+// so calls made with the transaction's context mustn't lock it again
+func (s *store) lock(ctx context.Context) func() {
+	if ctx.Value(transactionKey{}) == s {
+		return func() {}
+	}
+
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type transactor struct {
+	store *store
+}
+
+func (t transactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(transactionKey{}) == t.store {
+		return fn(ctx)
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	snapshot := t.store.data.clone()
+	if err := fn(context.WithValue(ctx, transactionKey{}, t.store)); err != nil {
+		t.store.data = snapshot
+		return err
+	}
+	return nil
+}
+
+// Repositories returns repositories backed by a new empty in-memory store,
+// nothing is persisted once the process exits
+func Repositories() database.Repositories {
+	s := &store{
+		data: data{
+			users:    make(map[string]models.User),
+			files:    make(map[string]file),
+			folders:  make(map[string]folder),
+			grants:   []grant{},
+			sessions: make(map[string]models.Session),
+			tokens:   make(map[string]models.Token),
+			resets:   make(map[string]models.PasswordReset),
+		},
+	}
+
+	return database.Repositories{
+		Users:      userService{store: s},
+		Files:      fileService{store: s},
+		Folders:    folderService{store: s},
+		Revisions:  revisionService{store: s},
+		Access:     accessService{store: s},
+		Sessions:   sessionService{store: s},
+		Tokens:     tokenService{store: s},
+		Resets:     resetService{store: s},
+		Search:     searchService{store: s},
+		Transactor: transactor{store: s},
+	}
+}
@@ -0,0 +1,12 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/databasetest"
+	"github.com/SergeyCherepiuk/docs/pkg/database/memory"
+)
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, memory.Repositories())
+}
@@ -0,0 +1,30 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+)
+
+// NOTE: Store keeps no indexes, so the whole listing is sorted to cut a page out of it
+func paginate[T any](items []T, page database.PageRequest, sorts map[string]database.Sort[T]) (database.Page[T], error) {
+	sortBy, order, cursor, err := database.ResolvePage(page, sorts)
+	if err != nil {
+		return database.Page[T]{}, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return order.Less(items[i], items[j]) })
+
+	limit := page.PageLimit()
+	selected := []T{}
+	for _, item := range items {
+		if len(selected) > limit {
+			break
+		}
+		if order.After(item, cursor) {
+			selected = append(selected, item)
+		}
+	}
+
+	return database.NewPage(selected, limit, sortBy, order), nil
+}
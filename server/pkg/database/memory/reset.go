@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type resetService struct {
+	store *store
+}
+
+func (s resetService) Create(ctx context.Context, reset models.PasswordReset) error {
+	defer s.store.lock(ctx)()
+
+	if _, ok := s.store.data.users[reset.Username]; !ok {
+		return database.NotFound("user wasn't found")
+	}
+
+	for hash, r := range s.store.data.resets {
+		if r.Username == reset.Username {
+			delete(s.store.data.resets, hash)
+		}
+	}
+
+	s.store.data.resets[reset.Hash] = reset
+	return nil
+}
+
+func (s resetService) Use(ctx context.Context, hash string) (models.User, error) {
+	defer s.store.lock(ctx)()
+
+	reset, ok := s.store.data.resets[hash]
+	if !ok {
+		return models.User{}, database.NotFound("password reset wasn't found")
+	}
+	delete(s.store.data.resets, hash)
+
+	user, ok := s.store.data.users[reset.Username]
+	if !ok || !reset.ExpiresAt.After(time.Now()) {
+		return models.User{}, database.NotFound("password reset wasn't found")
+	}
+
+	return user, nil
+}
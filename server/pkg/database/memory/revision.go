@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type revisionService struct {
+	store *store
+}
+
+func (s revisionService) Create(ctx context.Context, f models.File, revision models.Revision) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.files[f.Id]
+	if !ok {
+		return nil
+	}
+
+	stored.revisions = append(stored.revisions, revision)
+	s.store.data.files[f.Id] = stored
+	return nil
+}
+
+func (s revisionService) GetById(ctx context.Context, f models.File, id uuid.UUID) (models.Revision, error) {
+	defer s.store.lock(ctx)()
+
+	for _, revision := range s.store.data.files[f.Id].revisions {
+		if revision.Id == id.String() {
+			return revision, nil
+		}
+	}
+
+	return models.Revision{}, database.NotFound("revision wasn't found")
+}
+
+// NOTE: Latest revision is returned with content
+func (s revisionService) GetLatest(ctx context.Context, f models.File) (models.Revision, error) {
+	defer s.store.lock(ctx)()
+
+	var (
+		latest models.Revision
+		found  bool
+	)
+	for _, revision := range s.store.data.files[f.Id].revisions {
+		if !found || !revision.CreatedAt.Before(latest.CreatedAt) {
+			latest, found = revision, true
+		}
+	}
+
+	if !found {
+		return models.Revision{}, database.NotFound("revision wasn't found")
+	}
+	return latest, nil
+}
+
+// NOTE: Revisions are returned without content, newest first
+func (s revisionService) GetAll(ctx context.Context, f models.File) ([]models.Revision, error) {
+	defer s.store.lock(ctx)()
+
+	stored := s.store.data.files[f.Id].revisions
+
+	revisions := []models.Revision{}
+	for i := len(stored) - 1; i >= 0; i-- {
+		revision := stored[i]
+		revision.Content = ""
+		revisions = append(revisions, revision)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.After(revisions[j].CreatedAt)
+	})
+	return revisions, nil
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type searchService struct {
+	store *store
+}
+
+func (s searchService) Search(ctx context.Context, user models.User, query string, limit int) ([]models.SearchResult, error) {
+	defer s.store.lock(ctx)()
+
+	terms := database.SearchTerms(query)
+	if len(terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	results := []models.SearchResult{}
+	for id, f := range s.store.data.files {
+		if !s.store.data.canAccess(target{id: id}, user.Username) {
+			continue
+		}
+
+		score := database.Score(f.Name, f.content.Text, terms)
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, models.SearchResult{
+			File:     f.File,
+			Owner:    f.owner,
+			Score:    score,
+			Snippets: database.Snippets(f.Name, f.content.Text, terms),
+		})
+	}
+
+	return database.SortSearchResults(results, database.SearchLimit(limit)), nil
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type sessionService struct {
+	store *store
+}
+
+func (s sessionService) Create(ctx context.Context, session models.Session) error {
+	defer s.store.lock(ctx)()
+
+	if _, ok := s.store.data.users[session.Username]; !ok {
+		return nil
+	}
+
+	s.store.data.sessions[session.Id] = session
+	return nil
+}
+
+func (s sessionService) Check(ctx context.Context, id uuid.UUID) (models.User, models.Session, error) {
+	defer s.store.lock(ctx)()
+
+	session, ok := s.store.data.sessions[id.String()]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return models.User{}, models.Session{}, database.NotFound("user wasn't found")
+	}
+
+	user, ok := s.store.data.users[session.Username]
+	if !ok {
+		return models.User{}, models.Session{}, database.NotFound("user wasn't found")
+	}
+
+	return user, session, nil
+}
+
+func (s sessionService) Refresh(ctx context.Context, session models.Session) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.sessions[session.Id]
+	if !ok || !stored.ExpiresAt.After(time.Now()) {
+		return database.NotFound("session wasn't found")
+	}
+
+	stored.LastSeenAt = session.LastSeenAt
+	stored.ExpiresAt = session.ExpiresAt
+	s.store.data.sessions[session.Id] = stored
+	return nil
+}
+
+func (s sessionService) GetAll(ctx context.Context, user models.User) ([]models.Session, error) {
+	defer s.store.lock(ctx)()
+
+	sessions := []models.Session{}
+	for _, session := range s.store.data.sessions {
+		if session.Username == user.Username && session.ExpiresAt.After(time.Now()) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (s sessionService) Delete(ctx context.Context, user models.User, id uuid.UUID) error {
+	defer s.store.lock(ctx)()
+
+	session, ok := s.store.data.sessions[id.String()]
+	if !ok || session.Username != user.Username {
+		return database.NotFound("session wasn't found")
+	}
+
+	delete(s.store.data.sessions, id.String())
+	return nil
+}
+
+func (s sessionService) DeleteAllExcept(ctx context.Context, user models.User, id uuid.UUID) error {
+	defer s.store.lock(ctx)()
+
+	for sessionId, session := range s.store.data.sessions {
+		if session.Username == user.Username && sessionId != id.String() {
+			delete(s.store.data.sessions, sessionId)
+		}
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteAll(ctx context.Context, user models.User) error {
+	defer s.store.lock(ctx)()
+
+	for id, session := range s.store.data.sessions {
+		if session.Username == user.Username {
+			delete(s.store.data.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteExpired(ctx context.Context) (int, error) {
+	defer s.store.lock(ctx)()
+
+	count := 0
+	for id, session := range s.store.data.sessions {
+		if !session.ExpiresAt.After(time.Now()) {
+			delete(s.store.data.sessions, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type tokenService struct {
+	store *store
+}
+
+func (s tokenService) Create(ctx context.Context, token models.Token) error {
+	defer s.store.lock(ctx)()
+
+	if _, ok := s.store.data.users[token.Username]; !ok {
+		return database.NotFound("user wasn't found")
+	}
+	if _, ok := s.store.data.tokens[token.Id]; ok {
+		return database.Conflict("token with this id already exists")
+	}
+
+	token.Scopes = append([]string{}, token.Scopes...)
+	s.store.data.tokens[token.Id] = token
+	return nil
+}
+
+func (s tokenService) Check(ctx context.Context, hash string) (models.User, models.Token, error) {
+	defer s.store.lock(ctx)()
+
+	for _, token := range s.store.data.tokens {
+		if token.Hash != hash || !token.ExpiresAt.After(time.Now()) {
+			continue
+		}
+
+		user, ok := s.store.data.users[token.Username]
+		if !ok {
+			break
+		}
+		return user, token, nil
+	}
+
+	return models.User{}, models.Token{}, database.NotFound("token wasn't found")
+}
+
+func (s tokenService) GetAll(ctx context.Context, user models.User) ([]models.Token, error) {
+	defer s.store.lock(ctx)()
+
+	tokens := []models.Token{}
+	for _, token := range s.store.data.tokens {
+		if token.Username == user.Username {
+			tokens = append(tokens, token)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+func (s tokenService) Revoke(ctx context.Context, user models.User, id uuid.UUID) error {
+	defer s.store.lock(ctx)()
+
+	token, ok := s.store.data.tokens[id.String()]
+	if !ok || token.Username != user.Username {
+		return database.NotFound("token wasn't found")
+	}
+
+	delete(s.store.data.tokens, id.String())
+	return nil
+}
@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type userService struct {
+	store *store
+}
+
+func (s userService) Create(ctx context.Context, user models.User) error {
+	defer s.store.lock(ctx)()
+
+	if _, ok := s.store.data.users[user.Username]; ok {
+		return database.Conflict("username already taken")
+	}
+	if s.store.data.emailTaken(user.Email, user.Username) {
+		return database.Conflict("email already taken")
+	}
+
+	s.store.data.users[user.Username] = user
+	return nil
+}
+
+func (s userService) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	defer s.store.lock(ctx)()
+
+	user, ok := s.store.data.users[username]
+	if !ok {
+		return models.User{}, database.NotFound("user wasn't found")
+	}
+
+	return user, nil
+}
+
+func (s userService) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	defer s.store.lock(ctx)()
+
+	for _, user := range s.store.data.users {
+		if email != "" && user.Email == email {
+			return user, nil
+		}
+	}
+
+	return models.User{}, database.NotFound("user wasn't found")
+}
+
+// NOTE: Grants keep the old username of the granter, as they do in Neo4j
+func (s userService) UpdateUsername(ctx context.Context, user models.User, newUsername string) error {
+	defer s.store.lock(ctx)()
+
+	d := &s.store.data
+
+	stored, ok := d.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+	if _, ok := d.users[newUsername]; ok && newUsername != user.Username {
+		return database.Conflict("username already taken")
+	}
+
+	delete(d.users, user.Username)
+	stored.Username = newUsername
+	d.users[newUsername] = stored
+
+	for id, f := range d.files {
+		if f.owner == user.Username {
+			f.owner = newUsername
+			d.files[id] = f
+		}
+	}
+	for id, f := range d.folders {
+		if f.owner == user.Username {
+			f.owner = newUsername
+			d.folders[id] = f
+		}
+	}
+	for i, g := range d.grants {
+		if g.receiver == user.Username {
+			d.grants[i].receiver = newUsername
+		}
+	}
+	for id, session := range d.sessions {
+		if session.Username == user.Username {
+			session.Username = newUsername
+			d.sessions[id] = session
+		}
+	}
+	for id, token := range d.tokens {
+		if token.Username == user.Username {
+			token.Username = newUsername
+			d.tokens[id] = token
+		}
+	}
+	for hash, reset := range d.resets {
+		if reset.Username == user.Username {
+			reset.Username = newUsername
+			d.resets[hash] = reset
+		}
+	}
+
+	return nil
+}
+
+func (s userService) UpdatePassword(ctx context.Context, user models.User, newPassword string) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+
+	stored.Password = newPassword
+	s.store.data.users[user.Username] = stored
+	return nil
+}
+
+func (s userService) UpdateEmail(ctx context.Context, user models.User, newEmail string) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+	if s.store.data.emailTaken(newEmail, user.Username) {
+		return database.Conflict("email already taken")
+	}
+
+	stored.Email = newEmail
+	s.store.data.users[user.Username] = stored
+	return nil
+}
+
+func (s userService) UpdateTwoFactor(ctx context.Context, user models.User, secret string, enabled bool, recoveryCodes []string) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+
+	stored.TOTPSecret = secret
+	stored.TOTPEnabled = enabled
+	stored.RecoveryCodes = append([]string{}, recoveryCodes...)
+	s.store.data.users[user.Username] = stored
+	return nil
+}
+
+func (s userService) UseTOTPStep(ctx context.Context, user models.User, step int64) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+	if stored.TOTPLastStep >= step {
+		return database.NotFound("code was already used")
+	}
+
+	stored.TOTPLastStep = step
+	s.store.data.users[user.Username] = stored
+	return nil
+}
+
+func (s userService) UseRecoveryCode(ctx context.Context, user models.User, hash string) error {
+	defer s.store.lock(ctx)()
+
+	stored, ok := s.store.data.users[user.Username]
+	if !ok {
+		return database.NotFound("user wasn't found")
+	}
+
+	for i, code := range stored.RecoveryCodes {
+		if code == hash {
+			stored.RecoveryCodes = append(append([]string{}, stored.RecoveryCodes[:i]...), stored.RecoveryCodes[i+1:]...)
+			s.store.data.users[user.Username] = stored
+			return nil
+		}
+	}
+
+	return database.NotFound("recovery code wasn't found")
+}
+
+// NOTE: Owned files and folders, along with the grants, sessions, tokens and password resets of the user, are deleted as well.
+// Only the owned folders themselves are deleted, as it's done in Neo4j
+func (s userService) Delete(ctx context.Context, user models.User) error {
+	defer s.store.lock(ctx)()
+
+	d := &s.store.data
+
+	delete(d.users, user.Username)
+
+	for id, f := range d.files {
+		if f.owner == user.Username {
+			d.deleteFile(id)
+		}
+	}
+	for id, f := range d.folders {
+		if f.owner == user.Username {
+			delete(d.folders, id)
+			d.deleteGrants(func(g grant) bool { return g.target == target{id: id, folder: true} })
+		}
+	}
+	for id, f := range d.files {
+		if _, ok := d.folders[f.parent]; !ok && f.parent != "" {
+			f.parent = ""
+			d.files[id] = f
+		}
+	}
+	for id, f := range d.folders {
+		if _, ok := d.folders[f.parent]; !ok && f.parent != "" {
+			f.parent = ""
+			d.folders[id] = f
+		}
+	}
+
+	d.deleteGrants(func(g grant) bool { return g.receiver == user.Username })
+
+	for id, session := range d.sessions {
+		if session.Username == user.Username {
+			delete(d.sessions, id)
+		}
+	}
+	for id, token := range d.tokens {
+		if token.Username == user.Username {
+			delete(d.tokens, id)
+		}
+	}
+	for hash, reset := range d.resets {
+		if reset.Username == user.Username {
+			delete(d.resets, hash)
+		}
+	}
+
+	return nil
+}
+
+// NOTE: Empty email is never taken, any number of users can have none
+func (d data) emailTaken(email, username string) bool {
+	if email == "" {
+		return false
+	}
+	for _, user := range d.users {
+		if user.Email == email && user.Username != username {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,34 @@
 package models
 
+import "time"
+
 const (
-	RAcess   = "R"
-	RWAccess = "RW"
+	RAcess      = "R"
+	RWAccess    = "RW"
+	OwnerAccess = "O"
 )
 
+// NOTE: Levels are ordered, owner can do everything "read&write" allows,
+// which in turn includes everything "read" allows
+func IsAtLeastRAccess(level string) bool {
+	return level == RAcess || IsAtLeastRWAccess(level)
+}
+
+func IsAtLeastRWAccess(level string) bool {
+	return level == RWAccess || IsOwnerAccess(level)
+}
+
+func IsOwnerAccess(level string) bool {
+	return level == OwnerAccess
+}
+
+// NOTE: Access is inherited when it was granted to one of the folders
+// containing the file (or the folder), Source is the id of what it was granted to
 type Access struct {
-	Granter  string `json:"granter" prop:"granter"`
-	Receiver string `json:"receiver" prop:"receiver"`
-	Level    string `json:"level" prop:"level"`
+	Granter   string    `json:"granter" prop:"granter"`
+	Receiver  string    `json:"receiver" prop:"receiver"`
+	Level     string    `json:"level" prop:"level"`
+	Inherited bool      `json:"inherited" prop:"inherited"`
+	Source    string    `json:"source" prop:"source"`
+	GrantedAt time.Time `json:"grantedAt" prop:"granted_at"`
 }
@@ -0,0 +1,8 @@
+package models
+
+// NOTE: State is an engine-specific representation of the content
+// (e.g. CRDT elements), that is needed to restore live editing
+type Content struct {
+	Text  string `json:"text" prop:"text"`
+	State string `json:"-" prop:"state"`
+}
@@ -1,6 +1,24 @@
 package models
 
+import "time"
+
+// NOTE: File is updated when it's renamed or its content is saved,
+// LastEditedBy is the user who did it (the owner for a new file)
 type File struct {
-	Id   string `json:"id" prop:"id"`
-	Name string `json:"name" prop:"name"`
+	Id           string    `json:"id" prop:"id"`
+	Name         string    `json:"name" prop:"name"`
+	CreatedAt    time.Time `json:"createdAt" prop:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" prop:"updated_at"`
+	LastEditedBy string    `json:"lastEditedBy" prop:"last_edited_by"`
+}
+
+// NOTE: Level is the most permissive of the accesses
+// granted to the file directly or through its folders
+type SharedFile struct {
+	Id        string    `json:"id" prop:"id"`
+	Name      string    `json:"name" prop:"name"`
+	Owner     string    `json:"owner" prop:"owner"`
+	Level     string    `json:"level" prop:"level"`
+	GrantedBy string    `json:"grantedBy" prop:"granted_by"`
+	GrantedAt time.Time `json:"grantedAt" prop:"granted_at"`
 }
@@ -0,0 +1,13 @@
+package models
+
+type Folder struct {
+	Id   string `json:"id" prop:"id"`
+	Name string `json:"name" prop:"name"`
+}
+
+// NOTE: Folder is nil for the root of the tree
+type FolderTree struct {
+	Folder  *Folder      `json:"folder,omitempty"`
+	Folders []FolderTree `json:"folders"`
+	Files   []File       `json:"files"`
+}
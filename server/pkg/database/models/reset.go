@@ -0,0 +1,33 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// PasswordReset lets the user set a new password without knowing the old one. Only the hash
+// of the secret is stored, the secret itself is mailed to the user. A reset can be used once
+type PasswordReset struct {
+	Username  string    `json:"username" prop:"username"`
+	Hash      string    `json:"-" prop:"hash"`
+	CreatedAt time.Time `json:"createdAt" prop:"created_at"`
+	ExpiresAt time.Time `json:"expiresAt" prop:"expires_at"`
+}
+
+func NewPasswordReset(username string, ttl time.Duration) (PasswordReset, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return PasswordReset{}, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(bytes)
+
+	now := time.Now().In(time.UTC)
+	reset := PasswordReset{
+		Username:  username,
+		Hash:      HashToken(secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	return reset, secret, nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Revision struct {
+	Id        string    `json:"id" prop:"id"`
+	Author    string    `json:"author" prop:"author"`
+	CreatedAt time.Time `json:"createdAt" prop:"created_at"`
+	Content   string    `json:"content,omitempty" prop:"content"`
+}
+
+func NewRevision(author, content string) Revision {
+	return Revision{
+		Id:        uuid.NewString(),
+		Author:    author,
+		CreatedAt: time.Now().In(time.UTC),
+		Content:   content,
+	}
+}
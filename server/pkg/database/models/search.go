@@ -0,0 +1,10 @@
+package models
+
+// NOTE: Snippets are HTML-escaped fragments of the name and the content,
+// matched terms are wrapped in <mark> tags
+type SearchResult struct {
+	File     File     `json:"file"`
+	Owner    string   `json:"owner"`
+	Score    float64  `json:"score"`
+	Snippets []string `json:"snippets"`
+}
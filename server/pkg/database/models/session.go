@@ -6,18 +6,62 @@ import (
 	"github.com/google/uuid"
 )
 
+// NOTE: User agent and IP are the ones the session was created from. Sessions created
+// before they were recorded have them empty. Current isn't stored, it marks the session of the request
 type Session struct {
-	Id        string    `json:"id" prop:"id"`
-	Username  string    `json:"username" prop:"username"`
-	CreatedAt time.Time `json:"createdAt" prop:"created_at"`
-	ExpiresAt time.Time `json:"expiresAt" prop:"expires_at"`
+	Id         string    `json:"id" prop:"id"`
+	Username   string    `json:"username" prop:"username"`
+	UserAgent  string    `json:"userAgent" prop:"user_agent,optional"`
+	IP         string    `json:"ip" prop:"ip,optional"`
+	CreatedAt  time.Time `json:"createdAt" prop:"created_at"`
+	LastSeenAt time.Time `json:"lastSeenAt" prop:"last_seen_at,optional"`
+	ExpiresAt  time.Time `json:"expiresAt" prop:"expires_at"`
+	Current    bool      `json:"current" prop:"-"`
 }
 
-func NewWeekSession(username string) Session {
+// SessionPolicy decides how long the sessions live. A session expires after
+// IdleTimeout without use, and after AbsoluteTimeout since it was created no matter
+// how active it is. Sessions are extended on use, at most once per RefreshInterval
+type SessionPolicy struct {
+	IdleTimeout     time.Duration
+	AbsoluteTimeout time.Duration
+	RefreshInterval time.Duration
+}
+
+var DefaultSessionPolicy = SessionPolicy{
+	IdleTimeout:     7 * 24 * time.Hour,
+	AbsoluteTimeout: 30 * 24 * time.Hour,
+	RefreshInterval: time.Minute,
+}
+
+func (p SessionPolicy) NewSession(username string) Session {
+	now := time.Now().In(time.UTC)
 	return Session{
-		Id:        uuid.NewString(),
-		Username:  username,
-		CreatedAt: time.Now().In(time.UTC),
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour).In(time.UTC),
+		Id:         uuid.NewString(),
+		Username:   username,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  p.expiresAt(now, now),
+	}
+}
+
+// Refresh extends the session seen at the given time. The session needs to be
+// stored only if ok is true, otherwise it was refreshed recently enough
+func (p SessionPolicy) Refresh(session Session, seenAt time.Time) (refreshed Session, ok bool) {
+	if seenAt.Sub(session.LastSeenAt) < p.RefreshInterval {
+		return session, false
+	}
+
+	seenAt = seenAt.In(time.UTC)
+	session.LastSeenAt = seenAt
+	session.ExpiresAt = p.expiresAt(session.CreatedAt, seenAt)
+	return session, true
+}
+
+func (p SessionPolicy) expiresAt(createdAt, seenAt time.Time) time.Time {
+	idle := seenAt.Add(p.IdleTimeout)
+	if absolute := createdAt.Add(p.AbsoluteTimeout); absolute.Before(idle) {
+		return absolute
 	}
+	return idle
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeFilesRead    = "files:read"
+	ScopeFilesWrite   = "files:write"
+	ScopeAccessManage = "access:manage"
+)
+
+var Scopes = []string{ScopeFilesRead, ScopeFilesWrite, ScopeAccessManage}
+
+// NOTE: TokenPrefix makes the tokens easy to recognize, e.g. by secret scanners
+const TokenPrefix = "docs_"
+
+// Token is a personal API token. Only the hash of the secret is stored,
+// the secret itself is shown to the user once, when the token is created
+type Token struct {
+	Id        string    `json:"id" prop:"id"`
+	Username  string    `json:"username" prop:"username"`
+	Name      string    `json:"name" prop:"name"`
+	Scopes    []string  `json:"scopes" prop:"scopes"`
+	Hash      string    `json:"-" prop:"hash"`
+	CreatedAt time.Time `json:"createdAt" prop:"created_at"`
+	ExpiresAt time.Time `json:"expiresAt" prop:"expires_at"`
+}
+
+func NewToken(username, name string, scopes []string, expiresAt time.Time) (Token, string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return Token{}, "", err
+	}
+	secret := TokenPrefix + base64.RawURLEncoding.EncodeToString(bytes)
+
+	token := Token{
+		Id:        uuid.NewString(),
+		Username:  username,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      HashToken(secret),
+		CreatedAt: time.Now().In(time.UTC),
+		ExpiresAt: expiresAt.In(time.UTC),
+	}
+	return token, secret, nil
+}
+
+// NOTE: Secrets are random, so a fast hash is enough, unlike the passwords
+func HashToken(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func (t Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func IsScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,34 @@
 package models
 
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// NOTE: Email is optional, it's only used to reset the forgotten password.
+// Two-factor authentication is on once TOTPEnabled is set, TOTPSecret alone is
+// an enrolment that wasn't confirmed yet. Only the hashes of the recovery codes are stored.
+// TOTPLastStep is the time step of the last accepted code, so that a code can't be used twice
 type User struct {
-	Username string `json:"username" prop:"username"`
-	Password string `json:"password" prop:"password"`
+	Username      string   `json:"username" prop:"username"`
+	Password      string   `json:"password" prop:"password"`
+	Email         string   `json:"email,omitempty" prop:"email,optional"`
+	TOTPSecret    string   `json:"-" prop:"totp_secret,optional"`
+	TOTPEnabled   bool     `json:"totpEnabled" prop:"totp_enabled,optional"`
+	TOTPLastStep  int64    `json:"-" prop:"totp_last_step,optional"`
+	RecoveryCodes []string `json:"-" prop:"recovery_codes,optional"`
+}
+
+var ErrInvalidEmail = errors.New("invalid email")
+
+// NormalizeEmail validates the bare address (e.g. "jane@example.com", without the name) and lowercases it,
+// so that the same address is always stored and looked up the same way
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
 }
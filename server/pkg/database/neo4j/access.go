@@ -3,139 +3,265 @@ package neo4j
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
 	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
 )
 
-type accessService struct {
+// NOTE: Access can be granted either to a file or to a folder,
+// the same queries are used for both with a different label
+type accessCyphers struct {
+	resource string
+
 	grantReadCypher      string
 	grantReadWriteCypher string
 
-	getCypher          string
-	getAccessorsCypher string
+	getCypher      string
+	getLevelCypher string
+
+	getAccessors listing[models.Access]
 
 	updateLevelCypher string
 
 	revokeCypher string
 }
 
-func NewAccessService() *accessService {
-	return &accessService{
-		grantReadCypher:      `MATCH (u:User {username: $receiver}), (f:File {id: $id}) CREATE (u)-[:CAN_ACCESS {level: "R", grantedBy: $granter}]->(f)`,
-		grantReadWriteCypher: `MATCH (u:User {username: $receiver}), (f:File {id: $id}) CREATE (u)-[:CAN_ACCESS {level: "RW", grantedBy: $granter}]->(f)`,
+func newAccessCyphers(label string) accessCyphers {
+	return accessCyphers{
+		resource: strings.ToLower(label),
+
+		grantReadCypher:      fmt.Sprintf(`MATCH (u:User {username: $receiver}), (f:%s {id: $id}) CREATE (u)-[:CAN_ACCESS {level: "R", grantedBy: $granter, grantedAt: datetime()}]->(f)`, label),
+		grantReadWriteCypher: fmt.Sprintf(`MATCH (u:User {username: $receiver}), (f:%s {id: $id}) CREATE (u)-[:CAN_ACCESS {level: "RW", grantedBy: $granter, grantedAt: datetime()}]->(f)`, label),
+
+		getCypher: fmt.Sprintf(`MATCH (u:User {username: $username})-[a:CAN_ACCESS]->(f:%s {id: $id}) RETURN {granter: a.grantedBy, receiver: u.username, level: a.level, inherited: false, source: f.id, granted_at: coalesce(a.grantedAt, datetime({epochSeconds: 0}))} as a`, label),
+		// NOTE: Owner has the highest level, otherwise the most permissive of direct and inherited grants wins
+		getLevelCypher: fmt.Sprintf(`MATCH (f:%s {id: $id})
+			OPTIONAL MATCH (o:User)-[:OWNS]->(f)
+			OPTIONAL MATCH (:User {username: $username})-[a:CAN_ACCESS]->(:Folder|File)-[:CONTAINS*0..]->(f)
+			WITH o, collect(a.level) as levels
+			RETURN CASE
+				WHEN o.username = $username THEN "O"
+				WHEN "RW" IN levels THEN "RW"
+				WHEN "R" IN levels THEN "R"
+				ELSE ""
+			END as l`, label),
 
-		getCypher:          `MATCH (u:User {username: $username})-[a:CAN_ACCESS]->(f:File {id: $id}) RETURN {granter: a.grantedBy, receiver: u.username, level: a.level} as a`,
-		getAccessorsCypher: `MATCH (u:User)-[a:CAN_ACCESS]->(f:File {id: $id}) RETURN {granter: a.grantedBy, receiver: u.username, level: a.level} as a`,
+		// NOTE: Grants on the folders containing the target are inherited
+		getAccessors: newListing(
+			fmt.Sprintf(`MATCH (u:User)-[a:CAN_ACCESS]->(t)-[:CONTAINS*0..]->(f:%s {id: $id})
+				WITH {granter: a.grantedBy, receiver: u.username, level: a.level, inherited: t <> f, source: t.id, granted_at: coalesce(a.grantedAt, datetime({epochSeconds: 0}))} as a
+				WITH a, a.source + "/" + a.granter + "/" + a.receiver as id
+				WHERE %%s
+				RETURN a ORDER BY %%s LIMIT $limit`, label),
+			map[string]database.Keyset{
+				database.SortByName:      {Key: "a.receiver", After: "$after_key", Id: "id"},
+				database.SortByGrantedAt: {Key: "a.granted_at", After: "$after_time", Id: "id", Desc: true},
+			},
+			database.AccessSorts, "a", "failed to get accessors",
+		),
 
-		updateLevelCypher: `MATCH (u:User {username: $receiver})-[a:CAN_ACCESS {grantedBy: $granter}]->(f:File {id: $id}) SET a.level = $new_level RETURN COUNT(a) as c`,
+		updateLevelCypher: fmt.Sprintf(`MATCH (u:User {username: $receiver})-[a:CAN_ACCESS {grantedBy: $granter}]->(f:%s {id: $id}) SET a.level = $new_level RETURN COUNT(a) as c`, label),
+
+		revokeCypher: fmt.Sprintf(`MATCH (u:User {username: $receiver})-[a:CAN_ACCESS {grantedBy: $granter}]->(f:%s {id: $id}) DELETE a`, label),
+	}
+}
+
+type accessService struct {
+	file   accessCyphers
+	folder accessCyphers
+}
 
-		revokeCypher: `MATCH (u:User {username: $receiver})-[a:CAN_ACCESS {grantedBy: $granter}]->(f:File {id: $id}) DELETE a`,
+func NewAccessService() *accessService {
+	return &accessService{
+		file:   newAccessCyphers("File"),
+		folder: newAccessCyphers("Folder"),
 	}
 }
 
 var AccessService = NewAccessService()
 
-func (s accessService) Grant(ctx context.Context, runner runner, file models.File, access models.Access) error {
+func (s accessService) Grant(ctx context.Context, file models.File, access models.Access) error {
+	return s.grant(ctx, s.file, file.Id, access)
+}
+
+func (s accessService) GrantForFolder(ctx context.Context, folder models.Folder, access models.Access) error {
+	return s.grant(ctx, s.folder, folder.Id, access)
+}
+
+func (s accessService) grant(ctx context.Context, cyphers accessCyphers, id string, access models.Access) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	var cypher string
 	switch access.Level {
 	case models.RWAccess:
-		cypher = s.grantReadWriteCypher
+		cypher = cyphers.grantReadWriteCypher
 	case models.RAcess:
-		cypher = s.grantReadCypher
+		cypher = cyphers.grantReadCypher
 	default:
-		return fmt.Errorf("unknown access level value: %s", access.Level)
+		return database.Validation("unknown access level value: %s", access.Level)
 	}
 
 	params := map[string]any{
 		"receiver": access.Receiver,
-		"id":       file.Id,
+		"id":       id,
 		"granter":  access.Granter,
 	}
 
 	if _, err := runner.Run(ctx, cypher, params); err != nil {
-		return fmt.Errorf("failed to grand an access")
+		return database.Internal(err, "failed to grant an access")
 	}
 
 	return nil
 }
 
-func (s accessService) Get(ctx context.Context, runner runner, file models.File, user models.User) (models.Access, error) {
+// NOTE: Only the access granted to the file directly is returned
+func (s accessService) Get(ctx context.Context, file models.File, user models.User) (models.Access, error) {
+	return s.get(ctx, s.file, file.Id, user)
+}
+
+func (s accessService) GetForFolder(ctx context.Context, folder models.Folder, user models.User) (models.Access, error) {
+	return s.get(ctx, s.folder, folder.Id, user)
+}
+
+func (s accessService) get(ctx context.Context, cyphers accessCyphers, id string, user models.User) (models.Access, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": user.Username,
-		"id":       file.Id,
+		"id":       id,
 	}
 
-	result, err := runner.Run(ctx, s.getCypher, params)
+	result, err := runner.Run(ctx, cyphers.getCypher, params)
 	if err != nil {
-		return models.Access{}, fmt.Errorf("failed to get get the file access")
+		return models.Access{}, database.Internal(err, "failed to get the access")
 	}
 
 	access, err := internal.GetSingle[models.Access](ctx, result, "a")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return models.Access{}, fmt.Errorf("access wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return models.Access{}, database.NotFound("access wasn't found")
 		default:
-			return models.Access{}, fmt.Errorf("failed to get get the file access")
+			return models.Access{}, database.Internal(err, "failed to get the access")
 		}
 	}
 
 	return access, nil
 }
 
-func (s accessService) GetAccesses(ctx context.Context, runner runner, file models.File) ([]models.Access, error) {
+// NOTE: Both direct and inherited accesses are returned
+func (s accessService) GetAccesses(ctx context.Context, file models.File, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.getAccesses(ctx, s.file, file.Id, page)
+}
+
+func (s accessService) GetAccessesForFolder(ctx context.Context, folder models.Folder, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.getAccesses(ctx, s.folder, folder.Id, page)
+}
+
+func (s accessService) getAccesses(ctx context.Context, cyphers accessCyphers, id string, page database.PageRequest) (database.Page[models.Access], error) {
+	params := map[string]any{
+		"id": id,
+	}
+
+	return cyphers.getAccessors.get(ctx, params, page)
+}
+
+// GetLevel resolves the effective access level of the user to the file,
+// taking ownership and the accesses inherited from the folders into account
+func (s accessService) GetLevel(ctx context.Context, file models.File, user models.User) (string, error) {
+	return s.getLevel(ctx, s.file, file.Id, user)
+}
+
+func (s accessService) GetLevelForFolder(ctx context.Context, folder models.Folder, user models.User) (string, error) {
+	return s.getLevel(ctx, s.folder, folder.Id, user)
+}
+
+func (s accessService) getLevel(ctx context.Context, cyphers accessCyphers, id string, user models.User) (string, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
-		"id": file.Id,
+		"username": user.Username,
+		"id":       id,
 	}
 
-	result, err := runner.Run(ctx, s.getAccessorsCypher, params)
+	result, err := runner.Run(ctx, cyphers.getLevelCypher, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get accessors")
+		return "", database.Internal(err, "failed to get the access level")
 	}
 
-	accesses, err := internal.GetMultiple[models.Access](ctx, result, "a")
+	level, err := internal.GetSingle[string](ctx, result, "l")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return []models.Access{}, nil
+		switch {
+		case internal.IsNotFound(err):
+			return "", database.NotFound("%s wasn't found", cyphers.resource)
 		default:
-			return nil, fmt.Errorf("failed to get accessors")
+			return "", database.Internal(err, "failed to get the access level")
 		}
 	}
 
-	return accesses, nil
+	if level == "" {
+		return "", database.Forbidden("access wasn't found")
+	}
+
+	return level, nil
+}
+
+func (s accessService) UpdateLevel(ctx context.Context, file models.File, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, s.file, file.Id, access, newLevel)
+}
+
+func (s accessService) UpdateLevelForFolder(ctx context.Context, folder models.Folder, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, s.folder, folder.Id, access, newLevel)
 }
 
-func (s accessService) UpdateLevel(ctx context.Context, runner runner, file models.File, access models.Access, newLevel string) error {
+func (s accessService) updateLevel(ctx context.Context, cyphers accessCyphers, id string, access models.Access, newLevel string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"receiver":  access.Receiver,
 		"granter":   access.Granter,
-		"id":        file.Id,
+		"id":        id,
 		"new_level": newLevel,
 	}
 
-	result, err := runner.Run(ctx, s.updateLevelCypher, params)
+	result, err := runner.Run(ctx, cyphers.updateLevelCypher, params)
 	if err != nil {
-		return fmt.Errorf("failed to update access level")
+		return database.Internal(err, "failed to update access level")
 	}
 
 	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
-		return fmt.Errorf("access record wasn't found")
+		return database.NotFound("access record wasn't found")
 	}
 
 	return nil
 }
 
-func (s accessService) Revoke(ctx context.Context, runner runner, file models.File, access models.Access) error {
+func (s accessService) Revoke(ctx context.Context, file models.File, access models.Access) error {
+	return s.revoke(ctx, s.file, file.Id, access)
+}
+
+func (s accessService) RevokeForFolder(ctx context.Context, folder models.Folder, access models.Access) error {
+	return s.revoke(ctx, s.folder, folder.Id, access)
+}
+
+func (s accessService) revoke(ctx context.Context, cyphers accessCyphers, id string, access models.Access) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"receiver": access.Receiver,
 		"granter":  access.Granter,
-		"id":       file.Id,
+		"id":       id,
 	}
 
-	if _, err := runner.Run(ctx, s.revokeCypher, params); err != nil {
-		return fmt.Errorf("failed to revoke the access")
+	if _, err := runner.Run(ctx, cyphers.revokeCypher, params); err != nil {
+		return database.Internal(err, "failed to revoke the access")
 	}
 
 	return nil
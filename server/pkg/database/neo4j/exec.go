@@ -3,6 +3,7 @@ package neo4j
 import (
 	"context"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
@@ -25,3 +26,44 @@ func NewSession(ctx context.Context) Session {
 		SessionWithContext: driver.NewSession(ctx, neo4j.SessionConfig{}),
 	}
 }
+
+type transactionKey struct{}
+
+// NOTE: Queries run in the transaction carried by the context, if there is one,
+// otherwise in a new session, that must be closed once the result is consumed
+func getRunner(ctx context.Context) (runner, func()) {
+	if tx, ok := ctx.Value(transactionKey{}).(neo4j.ExplicitTransaction); ok {
+		return tx, func() {}
+	}
+
+	sess := NewSession(ctx)
+	return sess, func() { sess.Close(ctx) }
+}
+
+type transactor struct{}
+
+func (t transactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(transactionKey{}).(neo4j.ExplicitTransaction); ok {
+		return fn(ctx)
+	}
+
+	sess := NewSession(ctx)
+	defer sess.Close(ctx)
+
+	tx, err := sess.BeginTransaction(ctx)
+	if err != nil {
+		return database.Internal(err, "failed to start a database transaction")
+	}
+
+	if err := fn(context.WithValue(ctx, transactionKey{}, tx)); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return database.Internal(err, "failed to commit the database transaction")
+	}
+	return nil
+}
+
+var Transactor = transactor{}
@@ -2,8 +2,9 @@ package neo4j
 
 import (
 	"context"
-	"fmt"
+	"time"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
 	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
 	"github.com/google/uuid"
@@ -13,11 +14,16 @@ import (
 type fileService struct {
 	createCypher string
 
-	getByIdCypher        string
-	getOwnerCypher       string
-	getAllForOwnerCypher string
+	getByIdCypher    string
+	getOwnerCypher   string
+	getContentCypher string
 
-	updateNameCypher string
+	getAll           listing[models.File]
+	getAllForOwner   listing[models.File]
+	getAllSharedWith listing[models.SharedFile]
+
+	updateNameCypher    string
+	updateContentCypher string
 
 	deleteCypher            string
 	deleteAllForOwnerCypher string
@@ -25,22 +31,73 @@ type fileService struct {
 
 func NewFileService() *fileService {
 	return &fileService{
-		createCypher: `MATCH (u:User {username: $username}) CREATE (u)-[:OWNS]->(f:File {id: $id, name: $name})`,
-
-		getByIdCypher:        `MATCH (f:File {id: $id}) RETURN f`,
-		getOwnerCypher:       `MATCH (u:User)-[:OWNS]->(f:File {id: $id}) RETURN u`,
-		getAllForOwnerCypher: `MATCH (u:User {username: $username})-[:OWNS]->(f:File) RETURN f`,
+		createCypher: `MATCH (u:User {username: $username}) CREATE (u)-[:OWNS]->(f:File {id: $id, name: $name, created_at: datetime(), updated_at: datetime(), last_edited_by: $username})`,
+
+		getByIdCypher:    `MATCH (f:File {id: $id}) RETURN ` + fileProjection,
+		getOwnerCypher:   `MATCH (u:User)-[:OWNS]->(f:File {id: $id}) RETURN u`,
+		getContentCypher: `MATCH (f:File {id: $id}) RETURN {text: coalesce(f.content, ""), state: coalesce(f.content_state, "")} as c`,
+
+		getAll: newListing(
+			`MATCH (f:File) WHERE %s RETURN `+fileProjection+` ORDER BY %s LIMIT $limit`,
+			fileKeysets, database.FileSorts, "f", "failed to get all files from the database",
+		),
+		getAllForOwner: newListing(
+			ownedCypher, fileKeysets, database.FileSorts, "f", "failed to get all files for owner from the database",
+		),
+		getAllSharedWith: newListing(
+			sharedWithCypher,
+			map[string]database.Keyset{
+				database.SortByName:      {Key: "s.name", After: "$after_key", Id: "s.id"},
+				database.SortByGrantedAt: {Key: "s.granted_at", After: "$after_time", Id: "s.id", Desc: true},
+			},
+			database.SharedFileSorts, "s", "failed to get shared files from the database",
+		),
+
+		updateNameCypher:    `MATCH (f:File {id: $id}) SET f.name = $new_name, f.updated_at = datetime(), f.last_edited_by = $editor RETURN COUNT(f) as c`,
+		updateContentCypher: `MATCH (f:File {id: $id}) SET f.content = $content, f.content_state = $state, f.updated_at = datetime(), f.last_edited_by = $editor RETURN COUNT(f) as c`,
+
+		deleteCypher:            `MATCH (f:File {id: $id}) OPTIONAL MATCH (f)-[:HAS_REVISION]->(r:Revision) DETACH DELETE f, r`,
+		deleteAllForOwnerCypher: `MATCH (u:User {username: $username})-[:OWNS]->(f:File) OPTIONAL MATCH (f)-[:HAS_REVISION]->(r:Revision) DETACH DELETE f, r`,
+	}
+}
 
-		updateNameCypher: `MATCH (f:File {id: $id}) SET f.name = $new_name RETURN COUNT(f) as c`,
+// NOTE: Content and its state are only read by GetContent, the other
+// queries return just the metadata, so listings don't carry every document along
+const fileProjection = `f {.id, .name, .created_at, .updated_at, .last_edited_by} as f`
 
-		deleteCypher:            `MATCH (f:File {id: $id}) DETACH DELETE f`,
-		deleteAllForOwnerCypher: `MATCH (u:User {username: $username})-[:OWNS]->(f:File) DETACH DELETE f`,
-	}
+var fileKeysets = map[string]database.Keyset{
+	database.SortByName:      {Key: "f.name", After: "$after_key", Id: "f.id"},
+	database.SortByCreatedAt: {Key: "f.created_at", After: "$after_time", Id: "f.id", Desc: true},
+	database.SortByUpdatedAt: {Key: "f.updated_at", After: "$after_time", Id: "f.id", Desc: true},
 }
 
+// NOTE: Filter parameters left null match any file
+const ownedCypher = `MATCH (u:User {username: $username})-[:OWNS]->(f:File)
+	WHERE ($edited_by IS NULL OR f.last_edited_by = $edited_by)
+	AND ($created_after IS NULL OR f.created_at > $created_after)
+	AND ($created_before IS NULL OR f.created_at < $created_before)
+	AND ($updated_after IS NULL OR f.updated_at > $updated_after)
+	AND ($updated_before IS NULL OR f.updated_at < $updated_before)
+	AND %s
+	RETURN ` + fileProjection + ` ORDER BY %s LIMIT $limit`
+
+// NOTE: For every file only the most permissive grant is kept (the latest one among equals),
+// grants made before grant time was recorded are treated as the oldest ones
+const sharedWithCypher = `MATCH (u:User {username: $username})-[a:CAN_ACCESS]->(:Folder|File)-[:CONTAINS*0..]->(f:File)<-[:OWNS]-(o:User)
+	WHERE o <> u
+	WITH f, o, a ORDER BY CASE a.level WHEN "RW" THEN 0 ELSE 1 END, a.grantedAt DESC
+	WITH f, o, collect(a)[0] as a
+	WHERE $level = "" OR a.level = $level
+	WITH {id: f.id, name: f.name, owner: o.username, level: a.level, granted_by: a.grantedBy, granted_at: coalesce(a.grantedAt, datetime({epochSeconds: 0}))} as s
+	WHERE %s
+	RETURN s ORDER BY %s LIMIT $limit`
+
 var FileService = NewFileService()
 
-func (s fileService) Create(ctx context.Context, runner runner, file models.File, owner models.User) error {
+func (s fileService) Create(ctx context.Context, file models.File, owner models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": owner.Username,
 		"id":       file.Id,
@@ -50,121 +107,202 @@ func (s fileService) Create(ctx context.Context, runner runner, file models.File
 	_, err := runner.Run(ctx, s.createCypher, params)
 	if err != nil {
 		if neo4jErr, ok := err.(*neo4j.Neo4jError); ok && neo4jErr.Code == ConstraintValidationFailed {
-			return fmt.Errorf("file with this id already exists")
+			return database.Conflict("file with this id already exists")
 		} else {
-			return fmt.Errorf("failed to store the file in the database")
+			return database.Internal(err, "failed to store the file in the database")
 		}
 	}
 
 	return nil
 }
 
-func (s fileService) GetById(ctx context.Context, runner runner, id uuid.UUID) (models.File, error) {
+func (s fileService) GetById(ctx context.Context, id uuid.UUID) (models.File, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"id": id.String(),
 	}
 
 	result, err := runner.Run(ctx, s.getByIdCypher, params)
 	if err != nil {
-		return models.File{}, fmt.Errorf("file to get the file from the database")
+		return models.File{}, database.Internal(err, "failed to get the file from the database")
 	}
 
 	file, err := internal.GetSingle[models.File](ctx, result, "f")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return models.File{}, fmt.Errorf("file wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return models.File{}, database.NotFound("file wasn't found")
 		default:
-			return models.File{}, fmt.Errorf("file to get the file from the database")
+			return models.File{}, database.Internal(err, "failed to get the file from the database")
 		}
 	}
 
 	return file, nil
 }
 
-func (s fileService) GetOwner(ctx context.Context, runner runner, file models.File) (models.User, error) {
+func (s fileService) GetOwner(ctx context.Context, file models.File) (models.User, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"id": file.Id,
 	}
 
 	result, err := runner.Run(ctx, s.getOwnerCypher, params)
 	if err != nil {
-		return models.User{}, fmt.Errorf("failed to get the file's owner from the database")
+		return models.User{}, database.Internal(err, "failed to get the file's owner from the database")
 	}
 
 	owner, err := internal.GetSingle[models.User](ctx, result, "u")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return models.User{}, fmt.Errorf("owner wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, database.NotFound("owner wasn't found")
 		default:
-			return models.User{}, fmt.Errorf("failed to get the file's owner from the database")
+			return models.User{}, database.Internal(err, "failed to get the file's owner from the database")
 		}
 	}
 
 	return owner, nil
 }
 
-func (s fileService) GetAllForOwner(ctx context.Context, runner runner, owner models.User) ([]models.File, error) {
+// NOTE: Every file of every user, used to rebuild the search index
+func (s fileService) GetAll(ctx context.Context, page database.PageRequest) (database.Page[models.File], error) {
+	return s.getAll.get(ctx, map[string]any{}, page)
+}
+
+func (s fileService) GetAllForOwner(ctx context.Context, owner models.User, filter database.FileFilter, page database.PageRequest) (database.Page[models.File], error) {
 	params := map[string]any{
-		"username": owner.Username,
+		"username":       owner.Username,
+		"edited_by":      nil,
+		"created_after":  timeOrNil(filter.CreatedAfter),
+		"created_before": timeOrNil(filter.CreatedBefore),
+		"updated_after":  timeOrNil(filter.UpdatedAfter),
+		"updated_before": timeOrNil(filter.UpdatedBefore),
+	}
+	if filter.LastEditedBy != "" {
+		params["edited_by"] = filter.LastEditedBy
+	}
+
+	return s.getAllForOwner.get(ctx, params, page)
+}
+
+func timeOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// GetAllSharedWith returns files other users shared with the user. Level filters the files
+// by the access level (empty level matches any), they are sorted either by database.SortByName or database.SortByGrantedAt.
+func (s fileService) GetAllSharedWith(ctx context.Context, user models.User, level string, page database.PageRequest) (database.Page[models.SharedFile], error) {
+	params := map[string]any{
+		"username": user.Username,
+		"level":    level,
+	}
+
+	return s.getAllSharedWith.get(ctx, params, page)
+}
+
+func (s fileService) GetContent(ctx context.Context, file models.File) (models.Content, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": file.Id,
 	}
 
-	result, err := runner.Run(ctx, s.getAllForOwnerCypher, params)
+	result, err := runner.Run(ctx, s.getContentCypher, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all files for owner from the database")
+		return models.Content{}, database.Internal(err, "failed to get file's content from the database")
 	}
 
-	files, err := internal.GetMultiple[models.File](ctx, result, "f")
+	content, err := internal.GetSingle[models.Content](ctx, result, "c")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return []models.File{}, nil
+		switch {
+		case internal.IsNotFound(err):
+			return models.Content{}, database.NotFound("file wasn't found")
 		default:
-			return nil, fmt.Errorf("failed to get all files for owner from the database")
+			return models.Content{}, database.Internal(err, "failed to get file's content from the database")
 		}
 	}
 
-	return files, nil
+	return content, nil
 }
 
-func (s fileService) UpdateName(ctx context.Context, runner runner, file models.File, name string) error {
+func (s fileService) UpdateName(ctx context.Context, file models.File, name string, editor models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"id":       file.Id,
 		"new_name": name,
+		"editor":   editor.Username,
 	}
 
 	result, err := runner.Run(ctx, s.updateNameCypher, params)
 	if err != nil {
-		return fmt.Errorf("failed to update file's name")
+		return database.Internal(err, "failed to update file's name")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("file wasn't found")
+	}
+
+	return nil
+}
+
+func (s fileService) UpdateContent(ctx context.Context, file models.File, content models.Content, editor models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id":      file.Id,
+		"content": content.Text,
+		"state":   content.State,
+		"editor":  editor.Username,
+	}
+
+	result, err := runner.Run(ctx, s.updateContentCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to update file's content")
 	}
 
 	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
-		return fmt.Errorf("file wasn't found")
+		return database.NotFound("file wasn't found")
 	}
 
 	return nil
 }
 
-func (s fileService) Delete(ctx context.Context, runner runner, file models.File) error {
+func (s fileService) Delete(ctx context.Context, file models.File) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"id": file.Id,
 	}
 
 	if _, err := runner.Run(ctx, s.deleteCypher, params); err != nil {
-		return fmt.Errorf("failed to delete the file")
+		return database.Internal(err, "failed to delete the file")
 	}
 
 	return nil
 }
 
-func (s fileService) DeleteAllForOwner(ctx context.Context, runner runner, owner models.User) error {
+func (s fileService) DeleteAllForOwner(ctx context.Context, owner models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": owner.Username,
 	}
 
 	if _, err := runner.Run(ctx, s.deleteAllForOwnerCypher, params); err != nil {
-		return fmt.Errorf("failed to delete all files for owner")
+		return database.Internal(err, "failed to delete all files for owner")
 	}
 
 	return nil
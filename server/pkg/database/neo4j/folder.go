@@ -0,0 +1,374 @@
+package neo4j
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type folderService struct {
+	createCypher         string
+	createInFolderCypher string
+
+	getByIdCypher           string
+	getOwnerCypher          string
+	getChildFoldersCypher   string
+	getChildFilesCypher     string
+	getRootFoldersCypher    string
+	getRootFilesCypher      string
+	getFoldersForTreeCypher string
+	getFilesForTreeCypher   string
+
+	updateNameCypher     string
+	moveCypher           string
+	moveToRootCypher     string
+	moveFileCypher       string
+	moveFileToRootCypher string
+
+	deleteCypher string
+}
+
+func NewFolderService() *folderService {
+	return &folderService{
+		createCypher:         `MATCH (u:User {username: $username}) CREATE (u)-[:OWNS]->(d:Folder {id: $id, name: $name})`,
+		createInFolderCypher: `MATCH (u:User {username: $username}), (p:Folder {id: $parent_id}) CREATE (u)-[:OWNS]->(d:Folder {id: $id, name: $name}), (p)-[:CONTAINS]->(d)`,
+
+		getByIdCypher:           `MATCH (d:Folder {id: $id}) RETURN d`,
+		getOwnerCypher:          `MATCH (u:User)-[:OWNS]->(d:Folder {id: $id}) RETURN u`,
+		getChildFoldersCypher:   `MATCH (:Folder {id: $id})-[:CONTAINS]->(d:Folder) RETURN d ORDER BY d.name`,
+		getChildFilesCypher:     `MATCH (:Folder {id: $id})-[:CONTAINS]->(f:File) RETURN ` + fileProjection + ` ORDER BY f.name`,
+		getRootFoldersCypher:    `MATCH (u:User {username: $username})-[:OWNS]->(d:Folder) WHERE NOT (:Folder)-[:CONTAINS]->(d) RETURN d ORDER BY d.name`,
+		getRootFilesCypher:      `MATCH (u:User {username: $username})-[:OWNS]->(f:File) WHERE NOT (:Folder)-[:CONTAINS]->(f) RETURN ` + fileProjection + ` ORDER BY f.name`,
+		getFoldersForTreeCypher: `MATCH (u:User {username: $username})-[:OWNS]->(d:Folder) OPTIONAL MATCH (p:Folder)-[:CONTAINS]->(d) RETURN {id: d.id, name: d.name, parent_id: coalesce(p.id, "")} as e ORDER BY e.name`,
+		getFilesForTreeCypher:   `MATCH (u:User {username: $username})-[:OWNS]->(f:File) OPTIONAL MATCH (p:Folder)-[:CONTAINS]->(f) RETURN {id: f.id, name: f.name, created_at: f.created_at, updated_at: f.updated_at, last_edited_by: f.last_edited_by, parent_id: coalesce(p.id, "")} as e ORDER BY e.name`,
+
+		updateNameCypher: `MATCH (d:Folder {id: $id}) SET d.name = $new_name RETURN COUNT(d) as c`,
+		// NOTE: Folder can't be moved into itself or into any of its subfolders. The folder, the parent and its ancestors
+		// are write locked (in the order of their ids) before the check, otherwise two concurrent moves could pass it
+		// and make a cycle together. The moved folder of either of them is then an ancestor locked by the other one
+		moveCypher: `MATCH (d:Folder {id: $id}), (p:Folder {id: $parent_id})
+			OPTIONAL MATCH (a:Folder)-[:CONTAINS*]->(p)
+			WITH d, p, collect(a) + [d, p] as nodes
+			UNWIND nodes as n
+			WITH DISTINCT d, p, n ORDER BY n.id
+			SET n._lock = true REMOVE n._lock
+			WITH d, p, COUNT(n) as locked
+			WHERE d <> p AND NOT (d)-[:CONTAINS*]->(p)
+			OPTIONAL MATCH (:Folder)-[r:CONTAINS]->(d) DELETE r CREATE (p)-[:CONTAINS]->(d) RETURN COUNT(d) as c`,
+		moveToRootCypher:     `MATCH (d:Folder {id: $id}) OPTIONAL MATCH (:Folder)-[r:CONTAINS]->(d) DELETE r RETURN COUNT(d) as c`,
+		moveFileCypher:       `MATCH (f:File {id: $id}), (p:Folder {id: $parent_id}) OPTIONAL MATCH (:Folder)-[r:CONTAINS]->(f) DELETE r CREATE (p)-[:CONTAINS]->(f) RETURN COUNT(f) as c`,
+		moveFileToRootCypher: `MATCH (f:File {id: $id}) OPTIONAL MATCH (:Folder)-[r:CONTAINS]->(f) DELETE r RETURN COUNT(f) as c`,
+
+		deleteCypher: `MATCH (d:Folder {id: $id}) OPTIONAL MATCH (d)-[:CONTAINS*]->(c) OPTIONAL MATCH (c)-[:HAS_REVISION]->(r:Revision) DETACH DELETE d, c, r`,
+	}
+}
+
+var FolderService = NewFolderService()
+
+// NOTE: Folder is created in the root, if parent is nil
+func (s folderService) Create(ctx context.Context, folder models.Folder, parent *models.Folder, owner models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": owner.Username,
+		"id":       folder.Id,
+		"name":     folder.Name,
+	}
+
+	cypher := s.createCypher
+	if parent != nil {
+		cypher = s.createInFolderCypher
+		params["parent_id"] = parent.Id
+	}
+
+	_, err := runner.Run(ctx, cypher, params)
+	if err != nil {
+		if neo4jErr, ok := err.(*neo4j.Neo4jError); ok && neo4jErr.Code == ConstraintValidationFailed {
+			return database.Conflict("folder with this id already exists")
+		} else {
+			return database.Internal(err, "failed to store the folder in the database")
+		}
+	}
+
+	return nil
+}
+
+func (s folderService) GetById(ctx context.Context, id uuid.UUID) (models.Folder, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": id.String(),
+	}
+
+	result, err := runner.Run(ctx, s.getByIdCypher, params)
+	if err != nil {
+		return models.Folder{}, database.Internal(err, "failed to get the folder from the database")
+	}
+
+	folder, err := internal.GetSingle[models.Folder](ctx, result, "d")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.Folder{}, database.NotFound("folder wasn't found")
+		default:
+			return models.Folder{}, database.Internal(err, "failed to get the folder from the database")
+		}
+	}
+
+	return folder, nil
+}
+
+func (s folderService) GetOwner(ctx context.Context, folder models.Folder) (models.User, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": folder.Id,
+	}
+
+	result, err := runner.Run(ctx, s.getOwnerCypher, params)
+	if err != nil {
+		return models.User{}, database.Internal(err, "failed to get the folder's owner from the database")
+	}
+
+	owner, err := internal.GetSingle[models.User](ctx, result, "u")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, database.NotFound("owner wasn't found")
+		default:
+			return models.User{}, database.Internal(err, "failed to get the folder's owner from the database")
+		}
+	}
+
+	return owner, nil
+}
+
+func (s folderService) GetChildren(ctx context.Context, folder models.Folder) ([]models.Folder, []models.File, error) {
+	params := map[string]any{
+		"id": folder.Id,
+	}
+
+	return s.getChildren(ctx, s.getChildFoldersCypher, s.getChildFilesCypher, params)
+}
+
+// NOTE: Root children are owner's folders and files that aren't contained in any folder
+func (s folderService) GetRootChildren(ctx context.Context, owner models.User) ([]models.Folder, []models.File, error) {
+	params := map[string]any{
+		"username": owner.Username,
+	}
+
+	return s.getChildren(ctx, s.getRootFoldersCypher, s.getRootFilesCypher, params)
+}
+
+func (s folderService) getChildren(ctx context.Context, foldersCypher, filesCypher string, params map[string]any) ([]models.Folder, []models.File, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, foldersCypher, params)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	folders, err := internal.GetMultiple[models.Folder](ctx, result, "d")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			folders = []models.Folder{}
+		default:
+			return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+		}
+	}
+
+	result, err = runner.Run(ctx, filesCypher, params)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	files, err := internal.GetMultiple[models.File](ctx, result, "f")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			files = []models.File{}
+		default:
+			return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+		}
+	}
+
+	return folders, files, nil
+}
+
+type treeEntry struct {
+	Id       string `prop:"id"`
+	Name     string `prop:"name"`
+	ParentId string `prop:"parent_id"`
+}
+
+type fileTreeEntry struct {
+	models.File
+	ParentId string `prop:"parent_id"`
+}
+
+func (s folderService) GetTree(ctx context.Context, owner models.User) (models.FolderTree, error) {
+	params := map[string]any{
+		"username": owner.Username,
+	}
+
+	folders, err := getTreeEntries[treeEntry](ctx, s.getFoldersForTreeCypher, params)
+	if err != nil {
+		return models.FolderTree{}, err
+	}
+
+	files, err := getTreeEntries[fileTreeEntry](ctx, s.getFilesForTreeCypher, params)
+	if err != nil {
+		return models.FolderTree{}, err
+	}
+
+	childFolders := make(map[string][]treeEntry)
+	for _, folder := range folders {
+		childFolders[folder.ParentId] = append(childFolders[folder.ParentId], folder)
+	}
+
+	childFiles := make(map[string][]models.File)
+	for _, file := range files {
+		childFiles[file.ParentId] = append(childFiles[file.ParentId], file.File)
+	}
+
+	var build func(folder *models.Folder) models.FolderTree
+	build = func(folder *models.Folder) models.FolderTree {
+		id := ""
+		if folder != nil {
+			id = folder.Id
+		}
+
+		tree := models.FolderTree{Folder: folder, Folders: []models.FolderTree{}, Files: childFiles[id]}
+		if tree.Files == nil {
+			tree.Files = []models.File{}
+		}
+		for _, child := range childFolders[id] {
+			tree.Folders = append(tree.Folders, build(&models.Folder{Id: child.Id, Name: child.Name}))
+		}
+		return tree
+	}
+
+	return build(nil), nil
+}
+
+func getTreeEntries[T any](ctx context.Context, cypher string, params map[string]any) ([]T, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, cypher, params)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the folder tree from the database")
+	}
+
+	entries, err := internal.GetMultiple[T](ctx, result, "e")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return []T{}, nil
+		default:
+			return nil, database.Internal(err, "failed to get the folder tree from the database")
+		}
+	}
+
+	return entries, nil
+}
+
+func (s folderService) UpdateName(ctx context.Context, folder models.Folder, name string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id":       folder.Id,
+		"new_name": name,
+	}
+
+	result, err := runner.Run(ctx, s.updateNameCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to update folder's name")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("folder wasn't found")
+	}
+
+	return nil
+}
+
+// NOTE: Folder is moved to the root, if parent is nil
+func (s folderService) Move(ctx context.Context, folder models.Folder, parent *models.Folder) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": folder.Id,
+	}
+
+	cypher := s.moveToRootCypher
+	if parent != nil {
+		cypher = s.moveCypher
+		params["parent_id"] = parent.Id
+	}
+
+	result, err := runner.Run(ctx, cypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to move the folder")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.Conflict("folder can't be moved into itself or its subfolder")
+	}
+
+	return nil
+}
+
+// NOTE: File is moved to the root, if parent is nil
+func (s folderService) MoveFile(ctx context.Context, file models.File, parent *models.Folder) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": file.Id,
+	}
+
+	cypher := s.moveFileToRootCypher
+	if parent != nil {
+		cypher = s.moveFileCypher
+		params["parent_id"] = parent.Id
+	}
+
+	result, err := runner.Run(ctx, cypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to move the file")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("file wasn't found")
+	}
+
+	return nil
+}
+
+// NOTE: Everything the folder contains is deleted as well
+func (s folderService) Delete(ctx context.Context, folder models.Folder) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": folder.Id,
+	}
+
+	if _, err := runner.Run(ctx, s.deleteCypher, params); err != nil {
+		return database.Internal(err, "failed to delete the folder")
+	}
+
+	return nil
+}
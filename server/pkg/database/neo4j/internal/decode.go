@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// Collect decodes the value of the alias into a variable of type T.
+//
+// Structs are decoded from nodes, relationships and maps by the "prop" tag of their fields.
+// A property can be marked as optional with "prop:\"name,omitempty\"" ("optional" works as well),
+// the field is left with its zero value, if the property is absent or null. Pointer fields are always optional.
+// Embedded structs without the tag are decoded from the same properties as the struct itself.
+//
+// Integers are converted to any integer or float type they fit in, temporal types are converted to time.Time
+// and durations to time.Duration. Slices, maps and nested structs are decoded recursively
+func Collect[T any](record *neo4j.Record, alias string) (T, error) {
+	var variable T
+
+	if record == nil {
+		return variable, ErrNilRecord
+	}
+
+	value, found := record.Get(alias)
+	if !found {
+		return variable, fmt.Errorf("%w: alias \"%s\" not found", ErrAliasNotFound, alias)
+	}
+
+	rv := reflect.ValueOf(&variable).Elem()
+	if err := decode(rv, value, alias); err != nil {
+		return variable, err
+	}
+
+	return variable, nil
+}
+
+// NOTE: Path is the chain of aliases and properties that led to the value, used in error messages only
+func decode(rv reflect.Value, value any, path string) error {
+	if !rv.IsValid() {
+		return fmt.Errorf("%w: %s: variable is not valid", ErrInvalidValue, path)
+	} else if !rv.CanSet() {
+		return fmt.Errorf("%w: %s: variable cannot be set", ErrValueCannotBeSet, path)
+	}
+
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	pv := reflect.ValueOf(value)
+	if pv.Type().AssignableTo(rv.Type()) {
+		rv.Set(pv)
+		return nil
+	}
+
+	switch rv.Type() {
+	case timeType:
+		return decodeTime(rv, value, path)
+	case durationType:
+		return decodeDuration(rv, value, path)
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(rv.Type().Elem())
+		if err := decode(elem.Elem(), value, path); err != nil {
+			return err
+		}
+		rv.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		props, err := properties(value, path)
+		if err != nil {
+			return err
+		}
+		return decodeStruct(rv, props, path)
+
+	case reflect.Slice:
+		if pv.Kind() != reflect.Slice {
+			return mismatch(pv, rv, path)
+		}
+		slice := reflect.MakeSlice(rv.Type(), pv.Len(), pv.Len())
+		for i := 0; i < pv.Len(); i++ {
+			if err := decode(slice.Index(i), pv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+
+	case reflect.Map:
+		props, ok := value.(map[string]any)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return mismatch(pv, rv, path)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(props))
+		for key, prop := range props {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decode(elem, prop, path+"."+key); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !pv.CanInt() {
+			return mismatch(pv, rv, path)
+		}
+		if rv.OverflowInt(pv.Int()) {
+			return fmt.Errorf("%w: %s: value %d overflows %s", ErrTypeMismatch, path, pv.Int(), rv.Type())
+		}
+		rv.SetInt(pv.Int())
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !pv.CanInt() {
+			return mismatch(pv, rv, path)
+		}
+		if pv.Int() < 0 || rv.OverflowUint(uint64(pv.Int())) {
+			return fmt.Errorf("%w: %s: value %d overflows %s", ErrTypeMismatch, path, pv.Int(), rv.Type())
+		}
+		rv.SetUint(uint64(pv.Int()))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case pv.CanFloat():
+			rv.SetFloat(pv.Float())
+		case pv.CanInt():
+			rv.SetFloat(float64(pv.Int()))
+		default:
+			return mismatch(pv, rv, path)
+		}
+		return nil
+
+	case reflect.String, reflect.Bool:
+		if pv.Kind() != rv.Kind() {
+			return mismatch(pv, rv, path)
+		}
+		rv.Set(pv.Convert(rv.Type()))
+		return nil
+	}
+
+	return mismatch(pv, rv, path)
+}
+
+func decodeStruct(rv reflect.Value, props map[string]any, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("prop")
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := decodeStruct(rv.Field(i), props, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		name, optional := parseTag(tag)
+		if name == "" || name == "-" {
+			continue
+		}
+
+		prop, found := props[name]
+		if !found || prop == nil {
+			if optional || field.Type.Kind() == reflect.Pointer {
+				continue
+			}
+			return fmt.Errorf("%w: %s: property \"%s\" not found", ErrPropertyNotFound, path, name)
+		}
+
+		if err := decode(rv.Field(i), prop, path+"."+name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseTag(tag string) (name string, optional bool) {
+	name, options, _ := strings.Cut(tag, ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "optional" {
+			optional = true
+		}
+	}
+	return name, optional
+}
+
+func properties(value any, path string) (map[string]any, error) {
+	switch value := value.(type) {
+	case neo4j.Node:
+		return value.Props, nil
+	case neo4j.Relationship:
+		return value.Props, nil
+	case map[string]any:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("%w: %s: invalid alias type: %T", ErrInvalidAliasType, path, value)
+	}
+}
+
+func decodeTime(rv reflect.Value, value any, path string) error {
+	var t time.Time
+	switch value := value.(type) {
+	case neo4j.LocalDateTime:
+		t = value.Time()
+	case neo4j.Date:
+		t = value.Time()
+	case neo4j.LocalTime:
+		t = value.Time()
+	case neo4j.OffsetTime:
+		t = value.Time()
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrTypeMismatch, path, err)
+		}
+		t = parsed
+	default:
+		return mismatch(reflect.ValueOf(value), rv, path)
+	}
+
+	rv.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// NOTE: Months can't be converted to time.Duration, as their length varies
+func decodeDuration(rv reflect.Value, value any, path string) error {
+	var d time.Duration
+	switch value := value.(type) {
+	case neo4j.Duration:
+		if value.Months != 0 {
+			return fmt.Errorf("%w: %s: duration with months can't be converted to %s", ErrTypeMismatch, path, rv.Type())
+		}
+		d = time.Duration(value.Days)*24*time.Hour + time.Duration(value.Seconds)*time.Second + time.Duration(value.Nanos)
+	case int64:
+		d = time.Duration(value)
+	default:
+		return mismatch(reflect.ValueOf(value), rv, path)
+	}
+
+	rv.SetInt(int64(d))
+	return nil
+}
+
+func mismatch(pv, rv reflect.Value, path string) error {
+	return fmt.Errorf("%w: %s: cannot set value of a type %s, to a variable of a type %s", ErrTypeMismatch, path, pv.Type(), rv.Type())
+}
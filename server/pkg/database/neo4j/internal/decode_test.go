@@ -0,0 +1,351 @@
+package internal_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type (
+	audit struct {
+		By string    `prop:"by"`
+		At time.Time `prop:"at"`
+	}
+
+	document struct {
+		Id       string           `prop:"id"`
+		Size     int              `prop:"size"`
+		Ratio    float64          `prop:"ratio"`
+		Tags     []string         `prop:"tags"`
+		Parent   *string          `prop:"parent"`
+		Note     string           `prop:"note,omitempty"`
+		Edited   audit            `prop:"edited"`
+		History  []audit          `prop:"history,optional"`
+		Counters map[string]int32 `prop:"counters,omitempty"`
+		Ignored  string           `prop:"-"`
+		Untagged string
+	}
+
+	timeout struct {
+		After time.Duration `prop:"after"`
+	}
+
+	owned struct {
+		models.File
+		Owner string `prop:"owner"`
+	}
+
+	small struct {
+		Value int8 `prop:"value"`
+	}
+
+	unsigned struct {
+		Value uint `prop:"value"`
+	}
+)
+
+var (
+	createdAt = time.Date(2023, time.October, 1, 12, 30, 0, 0, time.UTC)
+	expiresAt = createdAt.Add(7 * 24 * time.Hour)
+	parent    = "8c0f4c2e-0b7e-4d7e-9a36-d6b3f2d1c0aa"
+)
+
+func record(value any) *neo4j.Record {
+	return &neo4j.Record{Keys: []string{"x"}, Values: []any{value}}
+}
+
+func collect[T any](record *neo4j.Record) (any, error) {
+	return internal.Collect[T](record, "x")
+}
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  *neo4j.Record
+		collect func(*neo4j.Record) (any, error)
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "string",
+			record:  record("owner"),
+			collect: collect[string],
+			want:    "owner",
+		},
+		{
+			name:    "count",
+			record:  record(int64(3)),
+			collect: collect[int64],
+			want:    int64(3),
+		},
+		{
+			name:    "int64 is widened to int",
+			record:  record(int64(42)),
+			collect: collect[int],
+			want:    42,
+		},
+		{
+			name:    "int64 is converted to float",
+			record:  record(int64(2)),
+			collect: collect[float64],
+			want:    2.0,
+		},
+		{
+			name:    "string into int",
+			record:  record("42"),
+			collect: collect[int],
+			wantErr: true,
+		},
+		{
+			name:    "node",
+			record:  record(neo4j.Node{Labels: []string{"User"}, Props: map[string]any{"username": "john", "password": "hash"}}),
+			collect: collect[models.User],
+			want:    models.User{Username: "john", Password: "hash"},
+		},
+		{
+			name: "node with datetime properties",
+			record: record(neo4j.Node{Labels: []string{"Session"}, Props: map[string]any{
+				"id":         "session",
+				"username":   "john",
+				"created_at": createdAt,
+				"expires_at": expiresAt,
+			}}),
+			collect: collect[models.Session],
+			want:    models.Session{Id: "session", Username: "john", CreatedAt: createdAt, ExpiresAt: expiresAt},
+		},
+		{
+			name: "local datetime and date properties",
+			record: record(map[string]any{
+				"id":         "session",
+				"username":   "john",
+				"created_at": neo4j.LocalDateTime(createdAt),
+				"expires_at": neo4j.DateOf(expiresAt),
+			}),
+			collect: collect[models.Session],
+			want: models.Session{
+				Id:        "session",
+				Username:  "john",
+				CreatedAt: createdAt,
+				ExpiresAt: time.Date(expiresAt.Year(), expiresAt.Month(), expiresAt.Day(), 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:    "datetime string property",
+			record:  record(map[string]any{"by": "john", "at": "2023-10-01T12:30:00Z"}),
+			collect: collect[audit],
+			want:    audit{By: "john", At: createdAt},
+		},
+		{
+			name: "relationship",
+			record: record(neo4j.Relationship{Type: "HAS_ACCESS", Props: map[string]any{
+				"granter":    "john",
+				"receiver":   "jane",
+				"level":      models.RWAccess,
+				"inherited":  false,
+				"source":     "file",
+				"granted_at": createdAt,
+			}}),
+			collect: collect[models.Access],
+			want:    models.Access{Granter: "john", Receiver: "jane", Level: models.RWAccess, Source: "file", GrantedAt: createdAt},
+		},
+		{
+			name: "map projection",
+			record: record(map[string]any{
+				"id":         "file",
+				"name":       "notes",
+				"owner":      "john",
+				"level":      models.RAcess,
+				"granted_by": "john",
+				"granted_at": createdAt,
+			}),
+			collect: collect[models.SharedFile],
+			want: models.SharedFile{
+				Id:        "file",
+				Name:      "notes",
+				Owner:     "john",
+				Level:     models.RAcess,
+				GrantedBy: "john",
+				GrantedAt: createdAt,
+			},
+		},
+		{
+			name: "nested structs, slices, maps and pointers",
+			record: record(map[string]any{
+				"id":       "doc",
+				"size":     int64(1024),
+				"ratio":    0.5,
+				"tags":     []any{"a", "b"},
+				"parent":   parent,
+				"edited":   map[string]any{"by": "john", "at": createdAt},
+				"history":  []any{map[string]any{"by": "jane", "at": createdAt}},
+				"counters": map[string]any{"views": int64(7)},
+			}),
+			collect: collect[document],
+			want: document{
+				Id:       "doc",
+				Size:     1024,
+				Ratio:    0.5,
+				Tags:     []string{"a", "b"},
+				Parent:   &parent,
+				Edited:   audit{By: "john", At: createdAt},
+				History:  []audit{{By: "jane", At: createdAt}},
+				Counters: map[string]int32{"views": 7},
+			},
+		},
+		{
+			name: "optional properties are absent or null",
+			record: record(map[string]any{
+				"id":     "doc",
+				"size":   int64(0),
+				"ratio":  int64(1),
+				"tags":   []any{},
+				"parent": nil,
+				"edited": map[string]any{"by": "john", "at": createdAt},
+			}),
+			collect: collect[document],
+			want: document{
+				Id:     "doc",
+				Ratio:  1,
+				Tags:   []string{},
+				Edited: audit{By: "john", At: createdAt},
+			},
+		},
+		{
+			name:    "required property is absent",
+			record:  record(map[string]any{"id": "doc"}),
+			collect: collect[document],
+			wantErr: true,
+		},
+		{
+			name:    "required property is null",
+			record:  record(map[string]any{"username": nil, "password": "hash"}),
+			collect: collect[models.User],
+			wantErr: true,
+		},
+		{
+			name: "embedded struct",
+			record: record(neo4j.Node{Props: map[string]any{
+				"id": "file", "name": "notes", "owner": "john",
+				"created_at": createdAt, "updated_at": createdAt, "last_edited_by": "john",
+			}}),
+			collect: collect[owned],
+			want:    owned{File: models.File{Id: "file", Name: "notes", CreatedAt: createdAt, UpdatedAt: createdAt, LastEditedBy: "john"}, Owner: "john"},
+		},
+		{
+			name: "pointer to struct",
+			record: record(neo4j.Node{Props: map[string]any{
+				"id": "file", "name": "notes",
+				"created_at": createdAt, "updated_at": createdAt, "last_edited_by": "john",
+			}}),
+			collect: collect[*models.File],
+			want:    &models.File{Id: "file", Name: "notes", CreatedAt: createdAt, UpdatedAt: createdAt, LastEditedBy: "john"},
+		},
+		{
+			name:    "null pointer",
+			record:  record(nil),
+			collect: collect[*models.File],
+			want:    (*models.File)(nil),
+		},
+		{
+			name:    "duration",
+			record:  record(map[string]any{"after": neo4j.DurationOf(0, 1, 30, 5)}),
+			collect: collect[timeout],
+			want:    timeout{After: 24*time.Hour + 30*time.Second + 5},
+		},
+		{
+			name:    "duration with months",
+			record:  record(map[string]any{"after": neo4j.DurationOf(1, 0, 0, 0)}),
+			collect: collect[timeout],
+			wantErr: true,
+		},
+		{
+			name:    "integer overflow",
+			record:  record(map[string]any{"value": int64(300)}),
+			collect: collect[small],
+			wantErr: true,
+		},
+		{
+			name:    "negative unsigned integer",
+			record:  record(map[string]any{"value": int64(-1)}),
+			collect: collect[unsigned],
+			wantErr: true,
+		},
+		{
+			name:    "type mismatch",
+			record:  record(map[string]any{"username": int64(1), "password": "hash"}),
+			collect: collect[models.User],
+			wantErr: true,
+		},
+		{
+			name:    "invalid alias type",
+			record:  record("john"),
+			collect: collect[models.User],
+			wantErr: true,
+		},
+		{
+			name:    "alias not found",
+			record:  &neo4j.Record{Keys: []string{"y"}, Values: []any{"john"}},
+			collect: collect[string],
+			wantErr: true,
+		},
+		{
+			name:    "nil record",
+			record:  nil,
+			collect: collect[string],
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.collect(test.record)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+// NOTE: Services report NotFound only for the queries that returned nothing,
+// decoding failures are internal errors
+func TestErrorKinds(t *testing.T) {
+	toDatabaseError := func(err error) error {
+		switch {
+		case internal.IsNotFound(err):
+			return database.NotFound("value wasn't found")
+		default:
+			return database.Internal(err, "failed to get the value")
+		}
+	}
+
+	_, err := internal.Collect[small](record(map[string]any{"value": int64(1000)}), "x")
+	if !errors.Is(err, internal.ErrTypeMismatch) || !errors.Is(toDatabaseError(err), database.ErrInternal) {
+		t.Errorf("expected decoding failure to be internal, got %v", err)
+	}
+
+	_, err = internal.Collect[document](record(map[string]any{"id": "1"}), "x")
+	if !errors.Is(err, internal.ErrPropertyNotFound) || !errors.Is(toDatabaseError(err), database.ErrInternal) {
+		t.Errorf("expected missing property to be internal, got %v", err)
+	}
+
+	_, err = internal.Collect[string](nil, "x")
+	if !errors.Is(toDatabaseError(err), database.ErrNotFound) {
+		t.Errorf("expected nil record to be not found, got %v", err)
+	}
+}
@@ -2,59 +2,60 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"reflect"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
-type (
-	ErrorNoRecords        error
-	ErrorNilRecord        error
-	ErrorInvalidValue     error
-	ErrorValueCannotBeSet error
-	ErrorAliasNotFound    error
-	ErrorTypeMismatch     error
-	ErrorInvalidAliasType error
+// NOTE: Errors are wrapped with the details, they are checked with errors.Is.
+// Only ErrNoRecords and ErrNilRecord mean that nothing was found, the others are decoding failures
+var (
+	ErrNoRecords        = errors.New("no records")
+	ErrNilRecord        = errors.New("record is nil")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrValueCannotBeSet = errors.New("value cannot be set")
+	ErrAliasNotFound    = errors.New("alias not found")
+	ErrPropertyNotFound = errors.New("property not found")
+	ErrTypeMismatch     = errors.New("type mismatch")
+	ErrInvalidAliasType = errors.New("invalid alias type")
 )
 
+// IsNotFound tells whether the query returned nothing, as opposed to failing
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNoRecords) || errors.Is(err, ErrNilRecord)
+}
+
 func GetSingle[T any](ctx context.Context, result neo4j.ResultWithContext, alias string) (T, error) {
 	var variable T
 
 	record, err := result.Single(ctx)
 	if err != nil {
-		return variable, ErrorNoRecords(fmt.Errorf("no record was found"))
+		// NOTE: Failures of the query itself are reported by the result, not by Single
+		if resultErr := result.Err(); resultErr != nil {
+			return variable, resultErr
+		}
+		return variable, fmt.Errorf("%w: %v", ErrNoRecords, err)
 	}
 	if record == nil {
-		return variable, ErrorNilRecord(fmt.Errorf("record is nil"))
+		return variable, ErrNilRecord
 	}
 
-	if reflect.TypeOf(variable).Kind() == reflect.Struct {
-		return collectStruct[T](ctx, record, alias)
-	}
-	return collectPrimitive[T](ctx, record, alias)
+	return Collect[T](record, alias)
 }
 
 func GetMultiple[T any](ctx context.Context, result neo4j.ResultWithContext, alias string) ([]T, error) {
 	records, err := result.Collect(ctx)
-	if len(records) <= 0 || err != nil {
-		return nil, ErrorNoRecords(fmt.Errorf("no records were found"))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) <= 0 {
+		return nil, ErrNoRecords
 	}
 
 	variables := make([]T, len(records))
 	for i, record := range records {
-		if record == nil {
-			return nil, ErrorNilRecord(fmt.Errorf("record is nil"))
-		}
-
-		var variable T
-		var err error
-		if reflect.ValueOf(variable).Kind() == reflect.Struct {
-			variable, err = collectStruct[T](ctx, record, alias)
-		} else {
-			variable, err = collectPrimitive[T](ctx, record, alias)
-		}
-
+		variable, err := Collect[T](record, alias)
 		if err != nil {
 			return nil, err
 		}
@@ -64,85 +65,21 @@ func GetMultiple[T any](ctx context.Context, result neo4j.ResultWithContext, ali
 	return variables, nil
 }
 
-func collectPrimitive[T any](ctx context.Context, record *neo4j.Record, alias string) (T, error) {
-	var variable T
-
-	if record == nil {
-		return variable, ErrorNilRecord(fmt.Errorf("record is nil"))
-	}
-
-	rv := reflect.ValueOf(&variable).Elem()
-	if !rv.IsValid() {
-		return variable, ErrorInvalidValue(fmt.Errorf("variable is not valid"))
-	} else if !rv.CanSet() {
-		return variable, ErrorValueCannotBeSet(fmt.Errorf("variable cannot be set"))
-	}
-
-	value, found := record.Get(alias)
-	if !found {
-		return variable, ErrorAliasNotFound(fmt.Errorf("alias \"%s\" not found", alias))
-	}
-
-	pv := reflect.ValueOf(value)
-	if rv.Kind() != pv.Kind() {
-		return variable, ErrorTypeMismatch(fmt.Errorf("cannot set value of a type %s, to a variable of a type %s", pv.Kind(), rv.Kind()))
-	}
-
-	rv.Set(pv)
-
-	return variable, nil
-}
-
-func collectStruct[T any](ctx context.Context, record *neo4j.Record, alias string) (T, error) {
-	var variable T
-
-	if record == nil {
-		return variable, ErrorNilRecord(fmt.Errorf("record is nil"))
-	}
-
-	value, found := record.Get(alias)
-	if !found {
-		return variable, ErrorAliasNotFound(fmt.Errorf("alias \"%s\" not found", alias))
-	}
-
-	rt := reflect.TypeOf(&variable).Elem()
-	rv := reflect.ValueOf(&variable).Elem()
-
-	for i := 0; i < rt.NumField(); i++ {
-		tag := rt.Field(i).Tag.Get("prop")
-		if tag == "" {
-			continue
-		}
-
-		fv := rv.Field(i)
-		if !fv.IsValid() {
-			return variable, ErrorInvalidValue(fmt.Errorf("struct field in not valid"))
-		} else if !fv.CanSet() {
-			return variable, ErrorValueCannotBeSet(fmt.Errorf("struct field cannot be set"))
-		}
-
-		var property any
-		var found bool
-		switch value := value.(type) {
-		case neo4j.Node:
-			property, found = value.Props[tag]
-		case map[string]any:
-			property, found = value[tag]
-		default:
-			return variable, ErrorInvalidAliasType(fmt.Errorf("invalid alias type: %T", value))
-		}
-
-		if !found {
-			return variable, ErrorAliasNotFound(fmt.Errorf("alias \"%s\" not found", alias))
-		}
-
-		pv := reflect.ValueOf(property)
-		if fv.Kind() != pv.Kind() {
-			return variable, ErrorTypeMismatch(fmt.Errorf("cannot set value of a type %s, to a variable of a type %s", pv.Kind(), rv.Kind()))
+// GetStream decodes the records one by one as they arrive, instead of collecting them first.
+// Unlike GetMultiple, no records isn't an error
+func GetStream[T any](ctx context.Context, result neo4j.ResultWithContext, alias string) ([]T, error) {
+	variables := []T{}
+	for result.Next(ctx) {
+		variable, err := Collect[T](result.Record(), alias)
+		if err != nil {
+			return nil, err
 		}
+		variables = append(variables, variable)
+	}
 
-		fv.Set(pv)
+	if err := result.Err(); err != nil {
+		return nil, err
 	}
 
-	return variable, nil
+	return variables, nil
 }
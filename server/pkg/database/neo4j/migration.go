@@ -0,0 +1,327 @@
+package neo4j
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+)
+
+//go:embed migrations/*.cypher
+var migrationFiles embed.FS
+
+// Migration is a pair of "<version>_<name>.up.cypher" and "<version>_<name>.down.cypher" files.
+// Statements in the files are separated by semicolons, lines starting with "//" are ignored
+type Migration struct {
+	Version  int64
+	Name     string
+	Checksum string
+	Up       []string
+	Down     []string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.cypher$`)
+
+// LoadMigrations reads migrations from the root of the file system, ordered by version.
+// Checksum covers the up statements, since they are what was applied to the database
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			checksum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(checksum[:])
+			m.Up = splitStatements(string(content))
+		} else {
+			m.Down = splitStatements(string(content))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func splitStatements(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines = append(lines, line)
+		}
+	}
+
+	statements := []string{}
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+type appliedMigration struct {
+	Version   int64     `prop:"version"`
+	Name      string    `prop:"name"`
+	Checksum  string    `prop:"checksum"`
+	AppliedAt time.Time `prop:"applied_at"`
+}
+
+type migrationService struct {
+	migrations []Migration
+
+	getAppliedCypher string
+
+	recordCypher string
+
+	deleteCypher string
+}
+
+func NewMigrationService(migrations []Migration) *migrationService {
+	return &migrationService{
+		migrations: migrations,
+
+		getAppliedCypher: `MATCH (m:Migration) RETURN {version: m.version, name: m.name, checksum: m.checksum, applied_at: m.applied_at} as m ORDER BY m.version`,
+
+		recordCypher: `CREATE (m:Migration {version: $version, name: $name, checksum: $checksum, applied_at: datetime()})`,
+
+		deleteCypher: `MATCH (m:Migration {version: $version}) DELETE m`,
+	}
+}
+
+var MigrationService = NewMigrationService(mustLoadEmbeddedMigrations())
+
+func mustLoadEmbeddedMigrations() []Migration {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
+
+// Status lists all the known migrations, whether they are applied or not
+func (s migrationService) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := s.getApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(s.migrations))
+	for i, m := range s.migrations {
+		statuses[i] = MigrationStatus{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = a.AppliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+// Up applies all the pending migrations in order and returns them. Nothing is changed in a dry run.
+// NOTE: Neo4j doesn't allow schema and data changes in one transaction, so statements run one by one,
+// and the migration is recorded once all of them succeed
+func (s migrationService) Up(ctx context.Context, dryRun bool) ([]Migration, error) {
+	applied, err := s.getApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest int64
+	for _, a := range applied {
+		if a.Version > latest {
+			latest = a.Version
+		}
+	}
+
+	pending := []Migration{}
+	for _, m := range s.migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if m.Version < latest {
+			return nil, fmt.Errorf("migration %d (%s) is older than the latest applied one", m.Version, m.Name)
+		}
+		pending = append(pending, m)
+	}
+
+	if dryRun {
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		if err := s.run(ctx, m, m.Up); err != nil {
+			return nil, err
+		}
+
+		params := map[string]any{
+			"version":  m.Version,
+			"name":     m.Name,
+			"checksum": m.Checksum,
+		}
+		if err := s.exec(ctx, s.recordCypher, params); err != nil {
+			return nil, fmt.Errorf("failed to record migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	return pending, nil
+}
+
+// Down reverts the given number of the latest applied migrations and returns them. Nothing is changed in a dry run.
+func (s migrationService) Down(ctx context.Context, steps int, dryRun bool) ([]Migration, error) {
+	applied, err := s.getApplied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	for i := len(s.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := s.migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if len(m.Down) == 0 {
+			return nil, fmt.Errorf("migration %d (%s) can't be reverted, it has no down statements", m.Version, m.Name)
+		}
+		reverted = append(reverted, m)
+	}
+
+	if dryRun {
+		return reverted, nil
+	}
+
+	for _, m := range reverted {
+		if err := s.run(ctx, m, m.Down); err != nil {
+			return nil, err
+		}
+
+		params := map[string]any{
+			"version": m.Version,
+		}
+		if err := s.exec(ctx, s.deleteCypher, params); err != nil {
+			return nil, fmt.Errorf("failed to delete the record of migration %d (%s): %w", m.Version, m.Name, err)
+		}
+	}
+
+	return reverted, nil
+}
+
+// NOTE: Applied migrations must be known and unchanged, otherwise the database
+// may differ from what the migrations describe
+func (s migrationService) getApplied(ctx context.Context) (map[int64]appliedMigration, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, s.getAppliedCypher, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	records, err := internal.GetMultiple[appliedMigration](ctx, result, "m")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			records = []appliedMigration{}
+		default:
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+		}
+	}
+
+	known := make(map[int64]Migration)
+	for _, m := range s.migrations {
+		known[m.Version] = m
+	}
+
+	applied := make(map[int64]appliedMigration)
+	for _, a := range records {
+		m, ok := known[a.Version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d (%s) is applied, but its files are missing", a.Version, a.Name)
+		}
+		if m.Checksum != a.Checksum {
+			return nil, fmt.Errorf("migration %d (%s) was changed after it had been applied", a.Version, a.Name)
+		}
+		applied[a.Version] = a
+	}
+
+	return applied, nil
+}
+
+func (s migrationService) run(ctx context.Context, m Migration, statements []string) error {
+	for _, statement := range statements {
+		if err := s.exec(ctx, statement, nil); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s migrationService) exec(ctx context.Context, cypher string, params map[string]any) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, cypher, params)
+	if err != nil {
+		return err
+	}
+
+	_, err = result.Consume(ctx)
+	return err
+}
@@ -0,0 +1,88 @@
+package neo4j_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.cypher":   {Data: []byte("// comment\nCREATE INDEX a;\n\nCREATE INDEX b;\n")},
+		"0002_second.down.cypher": {Data: []byte("DROP INDEX b;\nDROP INDEX a;")},
+		"0001_first.up.cypher":    {Data: []byte("CREATE CONSTRAINT c;")},
+	}
+
+	migrations, err := neo4j.LoadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("expected migrations 1 and 2 in order, got %+v", migrations)
+	}
+	if migrations[1].Name != "second" {
+		t.Errorf("expected name second, got %q", migrations[1].Name)
+	}
+	if want := []string{"CREATE INDEX a", "CREATE INDEX b"}; !reflect.DeepEqual(migrations[1].Up, want) {
+		t.Errorf("expected up %q, got %q", want, migrations[1].Up)
+	}
+	if want := []string{"DROP INDEX b", "DROP INDEX a"}; !reflect.DeepEqual(migrations[1].Down, want) {
+		t.Errorf("expected down %q, got %q", want, migrations[1].Down)
+	}
+	if len(migrations[0].Down) != 0 {
+		t.Errorf("expected no down statements, got %q", migrations[0].Down)
+	}
+}
+
+func TestMigrationChecksumCoversUpStatements(t *testing.T) {
+	load := func(up, down string) neo4j.Migration {
+		migrations, err := neo4j.LoadMigrations(fstest.MapFS{
+			"0001_first.up.cypher":   {Data: []byte(up)},
+			"0001_first.down.cypher": {Data: []byte(down)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migrations[0]
+	}
+
+	original := load("CREATE INDEX a;", "DROP INDEX a;")
+	if changed := load("CREATE INDEX b;", "DROP INDEX a;"); changed.Checksum == original.Checksum {
+		t.Errorf("expected changed up statements to change the checksum")
+	}
+	if changed := load("CREATE INDEX a;", "DROP INDEX b;"); changed.Checksum != original.Checksum {
+		t.Errorf("expected down statements not to change the checksum")
+	}
+}
+
+func TestLoadMigrationsRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"invalid name":    {"first.up.cypher": {}},
+		"zero version":    {"0000_zero.up.cypher": {}},
+		"different names": {"0001_a.up.cypher": {}, "0001_b.down.cypher": {}},
+		"missing up file": {"0001_first.down.cypher": {}},
+	}
+
+	for name, fsys := range tests {
+		if _, err := neo4j.LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	migrations, err := neo4j.LoadMigrations(os.DirFS("migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range migrations {
+		if len(m.Up) == 0 || len(m.Down) == 0 {
+			t.Errorf("migration %d (%s) must have both up and down statements", m.Version, m.Name)
+		}
+	}
+}
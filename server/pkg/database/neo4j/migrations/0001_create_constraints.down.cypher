@@ -0,0 +1,5 @@
+DROP CONSTRAINT constraint_migration_version_unique IF EXISTS;
+DROP CONSTRAINT constraint_revision_id_unique IF EXISTS;
+DROP CONSTRAINT constraint_folder_id_unique IF EXISTS;
+DROP CONSTRAINT constraint_file_id_unique IF EXISTS;
+DROP CONSTRAINT constraint_user_name_unique IF EXISTS;
@@ -0,0 +1,6 @@
+// NOTE: Constraints used to be created at startup, IF NOT EXISTS keeps this migration a no-op on such databases
+CREATE CONSTRAINT constraint_user_name_unique IF NOT EXISTS FOR (u:User) REQUIRE u.username IS UNIQUE;
+CREATE CONSTRAINT constraint_file_id_unique IF NOT EXISTS FOR (f:File) REQUIRE f.id IS UNIQUE;
+CREATE CONSTRAINT constraint_folder_id_unique IF NOT EXISTS FOR (d:Folder) REQUIRE d.id IS UNIQUE;
+CREATE CONSTRAINT constraint_revision_id_unique IF NOT EXISTS FOR (r:Revision) REQUIRE r.id IS UNIQUE;
+CREATE CONSTRAINT constraint_migration_version_unique IF NOT EXISTS FOR (m:Migration) REQUIRE m.version IS UNIQUE;
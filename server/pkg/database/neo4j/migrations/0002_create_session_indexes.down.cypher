@@ -0,0 +1,2 @@
+DROP INDEX index_session_expires_at IF EXISTS;
+DROP CONSTRAINT constraint_session_id_unique IF EXISTS;
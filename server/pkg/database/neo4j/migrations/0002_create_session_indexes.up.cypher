@@ -0,0 +1,3 @@
+// NOTE: Sessions are looked up by id on every request
+CREATE CONSTRAINT constraint_session_id_unique IF NOT EXISTS FOR (s:Session) REQUIRE s.id IS UNIQUE;
+CREATE INDEX index_session_expires_at IF NOT EXISTS FOR (s:Session) ON (s.expires_at);
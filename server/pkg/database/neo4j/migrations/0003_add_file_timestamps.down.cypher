@@ -0,0 +1,3 @@
+DROP INDEX index_file_updated_at IF EXISTS;
+DROP INDEX index_file_created_at IF EXISTS;
+MATCH (f:File) REMOVE f.created_at, f.updated_at, f.last_edited_by;
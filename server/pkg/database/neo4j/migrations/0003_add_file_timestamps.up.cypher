@@ -0,0 +1,4 @@
+// NOTE: Files created before the timestamps were recorded are treated as created and edited by their owners now
+MATCH (u:User)-[:OWNS]->(f:File) WHERE f.created_at IS NULL SET f.created_at = datetime(), f.updated_at = datetime(), f.last_edited_by = u.username;
+CREATE INDEX index_file_created_at IF NOT EXISTS FOR (f:File) ON (f.created_at);
+CREATE INDEX index_file_updated_at IF NOT EXISTS FOR (f:File) ON (f.updated_at);
@@ -0,0 +1 @@
+DROP INDEX index_file_search IF EXISTS;
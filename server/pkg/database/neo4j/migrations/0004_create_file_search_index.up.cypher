@@ -0,0 +1,2 @@
+// NOTE: Used by the search, files are looked up by the words of their names and contents
+CREATE FULLTEXT INDEX index_file_search IF NOT EXISTS FOR (f:File) ON EACH [f.name, f.content];
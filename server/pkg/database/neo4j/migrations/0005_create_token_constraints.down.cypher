@@ -0,0 +1,2 @@
+DROP CONSTRAINT constraint_token_hash_unique IF EXISTS;
+DROP CONSTRAINT constraint_token_id_unique IF EXISTS;
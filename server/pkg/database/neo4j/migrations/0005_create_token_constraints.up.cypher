@@ -0,0 +1,3 @@
+// NOTE: Tokens are looked up by the hash of the secret on every request made with them
+CREATE CONSTRAINT constraint_token_id_unique IF NOT EXISTS FOR (t:Token) REQUIRE t.id IS UNIQUE;
+CREATE CONSTRAINT constraint_token_hash_unique IF NOT EXISTS FOR (t:Token) REQUIRE t.hash IS UNIQUE;
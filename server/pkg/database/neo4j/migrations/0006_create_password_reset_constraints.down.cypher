@@ -0,0 +1,2 @@
+DROP CONSTRAINT constraint_password_reset_hash_unique IF EXISTS;
+DROP CONSTRAINT constraint_user_email_unique IF EXISTS;
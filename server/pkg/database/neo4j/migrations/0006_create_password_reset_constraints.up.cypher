@@ -0,0 +1,3 @@
+// NOTE: Users are looked up by email to reset the password, users without an email don't have the property
+CREATE CONSTRAINT constraint_user_email_unique IF NOT EXISTS FOR (u:User) REQUIRE u.email IS UNIQUE;
+CREATE CONSTRAINT constraint_password_reset_hash_unique IF NOT EXISTS FOR (r:PasswordReset) REQUIRE r.hash IS UNIQUE;
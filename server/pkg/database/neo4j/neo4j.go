@@ -5,17 +5,41 @@ import (
 	"log"
 	"os"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
 var (
 	driver neo4j.DriverWithContext
 
-	EquivalentSchemaRuleAlreadyExists = "Neo.ClientError.Schema.EquivalentSchemaRuleAlreadyExists"
-	ConstraintValidationFailed        = "Neo.ClientError.Schema.ConstraintValidationFailed"
+	ConstraintValidationFailed = "Neo.ClientError.Schema.ConstraintValidationFailed"
 )
 
+func Repositories() database.Repositories {
+	return database.Repositories{
+		Users:      UserService,
+		Files:      FileService,
+		Folders:    FolderService,
+		Revisions:  RevisionService,
+		Access:     AccessService,
+		Sessions:   SessionService,
+		Tokens:     TokenService,
+		Resets:     ResetService,
+		Search:     SearchService,
+		Transactor: Transactor,
+	}
+}
+
+// MustInitialize connects to the database and applies pending migrations
 func MustInitialize() {
+	MustConnect()
+
+	if _, err := MigrationService.Up(context.Background(), false); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func MustConnect() {
 	var (
 		err error
 
@@ -33,22 +57,4 @@ func MustInitialize() {
 	if err := driver.VerifyConnectivity(context.Background()); err != nil {
 		log.Fatal(err)
 	}
-
-	defineConstraints()
-}
-
-func defineConstraints() {
-	constraints := []string{
-		`CREATE CONSTRAINT constraint_user_name_unique FOR (u:User) REQUIRE u.username IS UNIQUE`,
-		`CREATE CONSTRAINT constraint_file_id_unique FOR (f:File) REQUIRE f.id IS UNIQUE`,
-	}
-
-	ctx := context.Background()
-	session := driver.NewSession(ctx, neo4j.SessionConfig{})
-	for _, constraint := range constraints {
-		_, err := session.Run(ctx, constraint, nil)
-		if err != nil && err.(*neo4j.Neo4jError).Code != EquivalentSchemaRuleAlreadyExists {
-			log.Fatal(err)
-		}
-	}
 }
@@ -0,0 +1,70 @@
+package neo4j_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/databasetest"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+	"github.com/google/uuid"
+)
+
+// NOTE: Runs against the database NEO4J_* variables point to, skipped if there is none
+func TestConformance(t *testing.T) {
+	if os.Getenv("NEO4J_DSN") == "" {
+		t.Skip("NEO4J_DSN isn't set")
+	}
+
+	neo4j.MustInitialize()
+	databasetest.Run(t, neo4j.Repositories())
+}
+
+// NOTE: Nodes left without their user can't be reached through the repositories,
+// so the conformance suite can't tell they weren't deleted
+func TestDeleteUserLeavesNoNodes(t *testing.T) {
+	if os.Getenv("NEO4J_DSN") == "" {
+		t.Skip("NEO4J_DSN isn't set")
+	}
+
+	neo4j.MustInitialize()
+	repos := neo4j.Repositories()
+	ctx := context.Background()
+
+	user := models.User{Username: "user-" + uuid.NewString(), Password: "password"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	session := models.DefaultSessionPolicy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	token, _, _ := models.NewToken(user.Username, "ci", []string{models.ScopeFilesRead}, time.Now().Add(time.Hour))
+	if err := repos.Tokens.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	reset, _, _ := models.NewPasswordReset(user.Username, time.Hour)
+	if err := repos.Resets.Create(ctx, reset); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repos.Users.Delete(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := neo4j.NewSession(ctx)
+	defer sess.Close(ctx)
+
+	params := map[string]any{"session": session.Id, "token": token.Id, "reset": reset.Hash}
+	result, err := sess.Run(ctx, `MATCH (n) WHERE (n:Session AND n.id = $session) OR (n:Token AND n.id = $token)
+		OR (n:PasswordReset AND n.hash = $reset) RETURN COUNT(n) as c`, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count != 0 || err != nil {
+		t.Errorf("expected no nodes of the deleted user, got %d (%v)", count, err)
+	}
+}
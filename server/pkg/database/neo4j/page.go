@@ -0,0 +1,53 @@
+package neo4j
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+)
+
+// listing is a paginated query with a variant for every sort key
+type listing[T any] struct {
+	cyphers map[string]string
+	sorts   map[string]database.Sort[T]
+	alias   string
+	failure string
+}
+
+// NOTE: Template is filled by database.KeysetQueries
+func newListing[T any](template string, keysets map[string]database.Keyset, sorts map[string]database.Sort[T], alias, failure string) listing[T] {
+	return listing[T]{cyphers: database.KeysetQueries(template, keysets), sorts: sorts, alias: alias, failure: failure}
+}
+
+// get runs the query of the requested sort and streams one item over the limit,
+// which tells whether there is a next page
+func (l listing[T]) get(ctx context.Context, params map[string]any, page database.PageRequest) (database.Page[T], error) {
+	sortBy, sort, cursor, err := database.ResolvePage(page, l.sorts)
+	if err != nil {
+		return database.Page[T]{}, err
+	}
+
+	params["after_key"], params["after_time"], params["after_id"] = nil, nil, nil
+	if cursor != nil {
+		params["after_key"], params["after_time"], params["after_id"] = cursor.Key, cursor.Time, cursor.Id
+	}
+
+	limit := page.PageLimit()
+	params["limit"] = limit + 1
+
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, l.cyphers[sortBy], params)
+	if err != nil {
+		return database.Page[T]{}, database.Internal(err, l.failure)
+	}
+
+	items, err := internal.GetStream[T](ctx, result, l.alias)
+	if err != nil {
+		return database.Page[T]{}, database.Internal(err, l.failure)
+	}
+
+	return database.NewPage(items, limit, sortBy, sort), nil
+}
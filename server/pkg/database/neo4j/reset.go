@@ -0,0 +1,77 @@
+package neo4j
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+)
+
+type resetService struct {
+	createCypher string
+
+	useCypher string
+}
+
+func NewResetService() *resetService {
+	return &resetService{
+		createCypher: `MATCH (u:User {username: $username}) OPTIONAL MATCH (u)-[:HAS_RESET]->(old:PasswordReset) DETACH DELETE old
+			WITH DISTINCT u CREATE (u)-[:HAS_RESET]->(r:PasswordReset {hash: $hash, created_at: $created_at, expires_at: $expires_at}) RETURN COUNT(r) as c`,
+
+		// NOTE: Expired resets are deleted as well, they can't be used anyway
+		useCypher: `MATCH (u:User)-[:HAS_RESET]->(r:PasswordReset {hash: $hash}) WITH u, r, r.expires_at > datetime() as valid
+			DETACH DELETE r WITH u, valid WHERE valid RETURN u`,
+	}
+}
+
+var ResetService = NewResetService()
+
+func (s resetService) Create(ctx context.Context, reset models.PasswordReset) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username":   reset.Username,
+		"hash":       reset.Hash,
+		"created_at": reset.CreatedAt,
+		"expires_at": reset.ExpiresAt,
+	}
+
+	result, err := runner.Run(ctx, s.createCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to create a password reset")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s resetService) Use(ctx context.Context, hash string) (models.User, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"hash": hash,
+	}
+
+	result, err := runner.Run(ctx, s.useCypher, params)
+	if err != nil {
+		return models.User{}, database.Internal(err, "failed to use the password reset")
+	}
+
+	user, err := internal.GetSingle[models.User](ctx, result, "u")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, database.NotFound("password reset wasn't found")
+		default:
+			return models.User{}, database.Internal(err, "failed to use the password reset")
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,130 @@
+package neo4j
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+	"github.com/google/uuid"
+)
+
+type revisionService struct {
+	createCypher string
+
+	getByIdCypher   string
+	getLatestCypher string
+	getAllCypher    string
+}
+
+func NewRevisionService() *revisionService {
+	return &revisionService{
+		createCypher: `MATCH (f:File {id: $id}) CREATE (f)-[:HAS_REVISION]->(r:Revision {id: $revision_id, author: $author, created_at: $created_at, content: $content})`,
+
+		getByIdCypher:   `MATCH (f:File {id: $id})-[:HAS_REVISION]->(r:Revision {id: $revision_id}) RETURN r`,
+		getLatestCypher: `MATCH (f:File {id: $id})-[:HAS_REVISION]->(r:Revision) RETURN r ORDER BY r.created_at DESC LIMIT 1`,
+		getAllCypher:    `MATCH (f:File {id: $id})-[:HAS_REVISION]->(r:Revision) WITH r ORDER BY r.created_at DESC RETURN {id: r.id, author: r.author, created_at: r.created_at, content: ""} as r`,
+	}
+}
+
+var RevisionService = NewRevisionService()
+
+func (s revisionService) Create(ctx context.Context, file models.File, revision models.Revision) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id":          file.Id,
+		"revision_id": revision.Id,
+		"author":      revision.Author,
+		"created_at":  revision.CreatedAt,
+		"content":     revision.Content,
+	}
+
+	if _, err := runner.Run(ctx, s.createCypher, params); err != nil {
+		return database.Internal(err, "failed to create a revision")
+	}
+
+	return nil
+}
+
+func (s revisionService) GetById(ctx context.Context, file models.File, id uuid.UUID) (models.Revision, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id":          file.Id,
+		"revision_id": id.String(),
+	}
+
+	result, err := runner.Run(ctx, s.getByIdCypher, params)
+	if err != nil {
+		return models.Revision{}, database.Internal(err, "failed to get the revision from the database")
+	}
+
+	revision, err := internal.GetSingle[models.Revision](ctx, result, "r")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.Revision{}, database.NotFound("revision wasn't found")
+		default:
+			return models.Revision{}, database.Internal(err, "failed to get the revision from the database")
+		}
+	}
+
+	return revision, nil
+}
+
+// NOTE: Latest revision is returned with content
+func (s revisionService) GetLatest(ctx context.Context, file models.File) (models.Revision, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": file.Id,
+	}
+
+	result, err := runner.Run(ctx, s.getLatestCypher, params)
+	if err != nil {
+		return models.Revision{}, database.Internal(err, "failed to get the latest revision from the database")
+	}
+
+	revision, err := internal.GetSingle[models.Revision](ctx, result, "r")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.Revision{}, database.NotFound("revision wasn't found")
+		default:
+			return models.Revision{}, database.Internal(err, "failed to get the latest revision from the database")
+		}
+	}
+
+	return revision, nil
+}
+
+// NOTE: Revisions are returned without content, newest first
+func (s revisionService) GetAll(ctx context.Context, file models.File) ([]models.Revision, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id": file.Id,
+	}
+
+	result, err := runner.Run(ctx, s.getAllCypher, params)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get revisions from the database")
+	}
+
+	revisions, err := internal.GetMultiple[models.Revision](ctx, result, "r")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return []models.Revision{}, nil
+		default:
+			return nil, database.Internal(err, "failed to get revisions from the database")
+		}
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,83 @@
+package neo4j
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+)
+
+type searchService struct {
+	searchCypher string
+}
+
+// NOTE: The full-text index is created by the migrations, files are matched
+// by any of the terms and ranked by the score Lucene gives them
+func NewSearchService() *searchService {
+	return &searchService{
+		searchCypher: `CALL db.index.fulltext.queryNodes("index_file_search", $query) YIELD node AS f, score
+			MATCH (o:User)-[:OWNS]->(f)
+			WHERE o.username = $username OR EXISTS { MATCH (:User {username: $username})-[:CAN_ACCESS]->(:Folder|File)-[:CONTAINS*0..]->(f) }
+			RETURN {file: f, owner: o.username, score: score, content: coalesce(f.content, "")} as r
+			ORDER BY score DESC, f.id LIMIT $limit`,
+	}
+}
+
+var SearchService = NewSearchService()
+
+type searchHit struct {
+	File    models.File `prop:"file"`
+	Owner   string      `prop:"owner"`
+	Score   float64     `prop:"score"`
+	Content string      `prop:"content"`
+}
+
+func (s searchService) Search(ctx context.Context, user models.User, query string, limit int) ([]models.SearchResult, error) {
+	terms := database.SearchTerms(query)
+	if len(terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"query":    luceneQuery(terms),
+		"username": user.Username,
+		"limit":    database.SearchLimit(limit),
+	}
+
+	result, err := runner.Run(ctx, s.searchCypher, params)
+	if err != nil {
+		return nil, database.Internal(err, "failed to search the files")
+	}
+
+	hits, err := internal.GetStream[searchHit](ctx, result, "r")
+	if err != nil {
+		return nil, database.Internal(err, "failed to search the files")
+	}
+
+	results := make([]models.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		results = append(results, models.SearchResult{
+			File:     hit.File,
+			Owner:    hit.Owner,
+			Score:    hit.Score,
+			Snippets: database.Snippets(hit.File.Name, hit.Content, terms),
+		})
+	}
+
+	return results, nil
+}
+
+// NOTE: Terms are only made of letters and digits, but are quoted anyway,
+// so that words like "AND" aren't taken for operators
+func luceneQuery(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+term+`"`)
+	}
+	return strings.Join(quoted, " OR ")
+}
@@ -2,8 +2,8 @@ package neo4j
 
 import (
 	"context"
-	"fmt"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
 	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
 	"github.com/google/uuid"
@@ -12,69 +12,193 @@ import (
 type sessionService struct {
 	createCypher string
 
-	checkCypher string
+	checkCypher   string
+	refreshCypher string
+	getAllCypher  string
 
-	deleteAllCypher string
+	deleteCypher          string
+	deleteAllExceptCypher string
+	deleteAllCypher       string
+	deleteExpiredCypher   string
 }
 
 func NewSessionService() *sessionService {
 	return &sessionService{
-		createCypher: `MATCH (u:User {username: $username}) CREATE (u)-[:HAS]->(s:Session {id: $id, created_at: $created_at, expires_at: $expires_at})`,
+		createCypher: `MATCH (u:User {username: $username}) CREATE (u)-[:HAS]->(s:Session {id: $id, user_agent: $user_agent, ip: $ip, created_at: $created_at, last_seen_at: $last_seen_at, expires_at: $expires_at})`,
 
-		checkCypher: `MATCH (u:User)-[:HAS]->(s:Session {id: $id}) WHERE s.expires_at > datetime() RETURN u`,
+		checkCypher:   `MATCH (u:User)-[:HAS]->(s:Session {id: $id}) WHERE s.expires_at > datetime() RETURN {user: u, session: s {.*, username: u.username}} as r`,
+		refreshCypher: `MATCH (s:Session {id: $id}) WHERE s.expires_at > datetime() SET s.last_seen_at = $last_seen_at, s.expires_at = $expires_at RETURN COUNT(s) as c`,
+		getAllCypher:  `MATCH (u:User {username: $username})-[:HAS]->(s:Session) WHERE s.expires_at > datetime() RETURN s {.*, username: u.username} as s ORDER BY coalesce(s.last_seen_at, s.created_at) DESC`,
 
-		deleteAllCypher: `MATCH (u:User {username: $username})-[:HAS]->(s:Session) DETACH DELETE s`,
+		deleteCypher:          `MATCH (u:User {username: $username})-[:HAS]->(s:Session {id: $id}) DETACH DELETE s RETURN COUNT(*) as c`,
+		deleteAllExceptCypher: `MATCH (u:User {username: $username})-[:HAS]->(s:Session) WHERE s.id <> $id DETACH DELETE s`,
+		deleteAllCypher:       `MATCH (u:User {username: $username})-[:HAS]->(s:Session) DETACH DELETE s`,
+		deleteExpiredCypher:   `MATCH (s:Session) WHERE s.expires_at <= datetime() DETACH DELETE s RETURN COUNT(*) as c`,
 	}
 }
 
 var SessionService = NewSessionService()
 
-func (s sessionService) Create(ctx context.Context, runner runner, session models.Session) error {
+func (s sessionService) Create(ctx context.Context, session models.Session) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
-		"id":         session.Id,
-		"username":   session.Username,
-		"created_at": session.CreatedAt,
-		"expires_at": session.ExpiresAt,
+		"id":           session.Id,
+		"username":     session.Username,
+		"user_agent":   session.UserAgent,
+		"ip":           session.IP,
+		"created_at":   session.CreatedAt,
+		"last_seen_at": session.LastSeenAt,
+		"expires_at":   session.ExpiresAt,
 	}
 
 	if _, err := runner.Run(ctx, s.createCypher, params); err != nil {
-		return fmt.Errorf("failed to create a session")
+		return database.Internal(err, "failed to create a session")
 	}
 
 	return nil
 }
 
-func (s sessionService) Check(ctx context.Context, runner runner, id uuid.UUID) (models.User, error) {
+type checkedSession struct {
+	User    models.User    `prop:"user"`
+	Session models.Session `prop:"session"`
+}
+
+func (s sessionService) Check(ctx context.Context, id uuid.UUID) (models.User, models.Session, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"id": id.String(),
 	}
 
 	result, err := runner.Run(ctx, s.checkCypher, params)
 	if err != nil {
-		return models.User{}, fmt.Errorf("failed to check the session")
+		return models.User{}, models.Session{}, database.Internal(err, "failed to check the session")
 	}
 
-	user, err := internal.GetSingle[models.User](ctx, result, "u")
+	checked, err := internal.GetSingle[checkedSession](ctx, result, "r")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return models.User{}, fmt.Errorf("user wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, models.Session{}, database.NotFound("user wasn't found")
 		default:
-			return models.User{}, fmt.Errorf("failed to check the session")
+			return models.User{}, models.Session{}, database.Internal(err, "failed to check the session")
 		}
 	}
 
-	return user, nil
+	return checked.User, checked.Session, nil
+}
+
+func (s sessionService) Refresh(ctx context.Context, session models.Session) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"id":           session.Id,
+		"last_seen_at": session.LastSeenAt,
+		"expires_at":   session.ExpiresAt,
+	}
+
+	result, err := runner.Run(ctx, s.refreshCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to refresh the session")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("session wasn't found")
+	}
+
+	return nil
+}
+
+func (s sessionService) GetAll(ctx context.Context, user models.User) ([]models.Session, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+	}
+
+	result, err := runner.Run(ctx, s.getAllCypher, params)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the sessions from the database")
+	}
+
+	sessions, err := internal.GetStream[models.Session](ctx, result, "s")
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the sessions from the database")
+	}
+
+	return sessions, nil
+}
+
+func (s sessionService) Delete(ctx context.Context, user models.User, id uuid.UUID) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+		"id":       id.String(),
+	}
+
+	result, err := runner.Run(ctx, s.deleteCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to delete the session")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("session wasn't found")
+	}
+
+	return nil
 }
 
-func (s sessionService) DeleteAll(ctx context.Context, runner runner, user models.User) error {
+func (s sessionService) DeleteAllExcept(ctx context.Context, user models.User, id uuid.UUID) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+		"id":       id.String(),
+	}
+
+	if _, err := runner.Run(ctx, s.deleteAllExceptCypher, params); err != nil {
+		return database.Internal(err, "failed to delete the other sessions")
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteAll(ctx context.Context, user models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": user.Username,
 	}
 
 	if _, err := runner.Run(ctx, s.deleteAllCypher, params); err != nil {
-		return fmt.Errorf("failed to delete all sessions")
+		return database.Internal(err, "failed to delete all sessions")
 	}
 
 	return nil
 }
+
+func (s sessionService) DeleteExpired(ctx context.Context) (int, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	result, err := runner.Run(ctx, s.deleteExpiredCypher, nil)
+	if err != nil {
+		return 0, database.Internal(err, "failed to delete the expired sessions")
+	}
+
+	count, err := internal.GetSingle[int64](ctx, result, "c")
+	if err != nil {
+		return 0, database.Internal(err, "failed to delete the expired sessions")
+	}
+
+	return int(count), nil
+}
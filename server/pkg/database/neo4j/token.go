@@ -0,0 +1,131 @@
+package neo4j
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
+	"github.com/google/uuid"
+)
+
+type tokenService struct {
+	createCypher string
+
+	checkCypher  string
+	getAllCypher string
+
+	revokeCypher string
+}
+
+func NewTokenService() *tokenService {
+	return &tokenService{
+		createCypher: `MATCH (u:User {username: $username}) CREATE (u)-[:HAS_TOKEN]->(t:Token {id: $id, name: $name, scopes: $scopes, hash: $hash, created_at: $created_at, expires_at: $expires_at}) RETURN COUNT(t) as c`,
+
+		checkCypher:  `MATCH (u:User)-[:HAS_TOKEN]->(t:Token {hash: $hash}) WHERE t.expires_at > datetime() RETURN {user: u, token: t {.*, username: u.username}} as r`,
+		getAllCypher: `MATCH (u:User {username: $username})-[:HAS_TOKEN]->(t:Token) RETURN t {.*, username: u.username} as t ORDER BY t.created_at DESC`,
+
+		revokeCypher: `MATCH (u:User {username: $username})-[:HAS_TOKEN]->(t:Token {id: $id}) DETACH DELETE t RETURN COUNT(*) as c`,
+	}
+}
+
+var TokenService = NewTokenService()
+
+type checkedToken struct {
+	User  models.User  `prop:"user"`
+	Token models.Token `prop:"token"`
+}
+
+func (s tokenService) Create(ctx context.Context, token models.Token) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username":   token.Username,
+		"id":         token.Id,
+		"name":       token.Name,
+		"scopes":     token.Scopes,
+		"hash":       token.Hash,
+		"created_at": token.CreatedAt,
+		"expires_at": token.ExpiresAt,
+	}
+
+	result, err := runner.Run(ctx, s.createCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to create a token")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s tokenService) Check(ctx context.Context, hash string) (models.User, models.Token, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"hash": hash,
+	}
+
+	result, err := runner.Run(ctx, s.checkCypher, params)
+	if err != nil {
+		return models.User{}, models.Token{}, database.Internal(err, "failed to check the token")
+	}
+
+	checked, err := internal.GetSingle[checkedToken](ctx, result, "r")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, models.Token{}, database.NotFound("token wasn't found")
+		default:
+			return models.User{}, models.Token{}, database.Internal(err, "failed to check the token")
+		}
+	}
+
+	return checked.User, checked.Token, nil
+}
+
+func (s tokenService) GetAll(ctx context.Context, user models.User) ([]models.Token, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+	}
+
+	result, err := runner.Run(ctx, s.getAllCypher, params)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the tokens from the database")
+	}
+
+	tokens, err := internal.GetStream[models.Token](ctx, result, "t")
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the tokens from the database")
+	}
+
+	return tokens, nil
+}
+
+func (s tokenService) Revoke(ctx context.Context, user models.User, id uuid.UUID) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+		"id":       id.String(),
+	}
+
+	result, err := runner.Run(ctx, s.revokeCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to revoke the token")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("token wasn't found")
+	}
+
+	return nil
+}
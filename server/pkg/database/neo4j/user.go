@@ -2,8 +2,9 @@ package neo4j
 
 import (
 	"context"
-	"fmt"
+	"strings"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
 	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j/internal"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
@@ -13,69 +14,126 @@ type userService struct {
 	createCypher string
 
 	getByUsernameCypher string
+	getByEmailCypher    string
 
 	updateUsernameCypher string
 	updatePasswordCypher string
+	updateEmailCypher    string
+
+	updateTwoFactorCypher string
+	useTOTPStepCypher     string
+	useRecoveryCodeCypher string
 
 	deleteCypher string
 }
 
 func NewUserService() *userService {
 	return &userService{
-		createCypher: `CREATE (u:User {username: $username, password: $password})`,
+		createCypher: `CREATE (u:User {username: $username, password: $password, email: $email})`,
 
 		getByUsernameCypher: `MATCH (u:User {username: $username}) RETURN u`,
+		getByEmailCypher:    `MATCH (u:User {email: $email}) RETURN u`,
 
 		updateUsernameCypher: `MATCH (u:User {username: $username}) SET u.username = $new_username RETURN COUNT(u) as c`,
 		updatePasswordCypher: `MATCH (u:User {username: $username}) SET u.password = $new_password RETURN COUNT(u) as c`,
+		updateEmailCypher:    `MATCH (u:User {username: $username}) SET u.email = $new_email RETURN COUNT(u) as c`,
+
+		updateTwoFactorCypher: `MATCH (u:User {username: $username}) SET u.totp_secret = $secret, u.totp_enabled = $enabled, u.recovery_codes = $recovery_codes RETURN COUNT(u) as c`,
+		useTOTPStepCypher:     `MATCH (u:User {username: $username}) WHERE coalesce(u.totp_last_step, 0) < $step SET u.totp_last_step = $step RETURN COUNT(u) as c`,
+		useRecoveryCodeCypher: `MATCH (u:User {username: $username}) WHERE $hash IN u.recovery_codes SET u.recovery_codes = [code IN u.recovery_codes WHERE code <> $hash] RETURN COUNT(u) as c`,
 
-		deleteCypher: `MATCH (u:User {username: $username}) OPTIONAL MATCH (u)-[r:OWNS]->(f:File) DETACH DELETE u, r, f`,
+		// NOTE: Sessions, tokens and resets are collected first, so they don't multiply the rows of the files
+		deleteCypher: `MATCH (u:User {username: $username})
+			OPTIONAL MATCH (u)-[:HAS]->(s:Session) WITH u, collect(s) as sessions
+			OPTIONAL MATCH (u)-[:HAS_TOKEN]->(t:Token) WITH u, sessions + collect(t) as nodes
+			OPTIONAL MATCH (u)-[:HAS_RESET]->(r:PasswordReset) WITH u, nodes + collect(r) as nodes
+			FOREACH (n IN nodes | DETACH DELETE n)
+			WITH u OPTIONAL MATCH (u)-[r:OWNS]->(f) OPTIONAL MATCH (f)-[:HAS_REVISION]->(rv:Revision) DETACH DELETE u, r, f, rv`,
 	}
 }
 
 var UserService = NewUserService()
 
-func (s userService) Create(ctx context.Context, runner runner, user models.User) error {
+func (s userService) Create(ctx context.Context, user models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": user.Username,
 		"password": user.Password,
+		"email":    emailOrNil(user.Email),
 	}
 
 	_, err := runner.Run(ctx, s.createCypher, params)
 	if err != nil {
 		if neo4jErr, ok := err.(*neo4j.Neo4jError); ok && neo4jErr.Code == ConstraintValidationFailed {
-			return fmt.Errorf("username already taken")
+			if strings.Contains(neo4jErr.Msg, "email") {
+				return database.Conflict("email already taken")
+			}
+			return database.Conflict("username already taken")
 		} else {
-			return fmt.Errorf("failed to store user in the database")
+			return database.Internal(err, "failed to store user in the database")
 		}
 	}
 	return nil
 }
 
-func (s userService) GetByUsername(ctx context.Context, runner runner, username string) (models.User, error) {
+func (s userService) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": username,
 	}
 
 	result, err := runner.Run(ctx, s.getByUsernameCypher, params)
 	if err != nil {
-		return models.User{}, fmt.Errorf("failed to get the user from the database")
+		return models.User{}, database.Internal(err, "failed to get the user from the database")
 	}
 
 	user, err := internal.GetSingle[models.User](ctx, result, "u")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return models.User{}, fmt.Errorf("user wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, database.NotFound("user wasn't found")
 		default:
-			return models.User{}, fmt.Errorf("failed to get the user from the database")
+			return models.User{}, database.Internal(err, "failed to get the user from the database")
 		}
 	}
 
 	return user, nil
 }
 
-func (s userService) UpdateUsername(ctx context.Context, runner runner, user models.User, newUsername string) error {
+func (s userService) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"email": emailOrNil(email),
+	}
+
+	result, err := runner.Run(ctx, s.getByEmailCypher, params)
+	if err != nil {
+		return models.User{}, database.Internal(err, "failed to get the user from the database")
+	}
+
+	user, err := internal.GetSingle[models.User](ctx, result, "u")
+	if err != nil {
+		switch {
+		case internal.IsNotFound(err):
+			return models.User{}, database.NotFound("user wasn't found")
+		default:
+			return models.User{}, database.Internal(err, "failed to get the user from the database")
+		}
+	}
+
+	return user, nil
+}
+
+func (s userService) UpdateUsername(ctx context.Context, user models.User, newUsername string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username":     user.Username,
 		"new_username": newUsername,
@@ -84,30 +142,33 @@ func (s userService) UpdateUsername(ctx context.Context, runner runner, user mod
 	result, err := runner.Run(ctx, s.updateUsernameCypher, params)
 	if err != nil {
 		if neo4jErr, ok := err.(*neo4j.Neo4jError); ok && neo4jErr.Code == ConstraintValidationFailed {
-			return fmt.Errorf("username already taken")
+			return database.Conflict("username already taken")
 		} else {
-			return fmt.Errorf("failed to update user's username")
+			return database.Internal(err, "failed to update user's username")
 		}
 	}
 
 	count, err := internal.GetSingle[int64](ctx, result, "c")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return fmt.Errorf("user wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return database.NotFound("user wasn't found")
 		default:
-			return fmt.Errorf("failed to update user's username")
+			return database.Internal(err, "failed to update user's username")
 		}
 	}
 
 	if count <= 0 {
-		return fmt.Errorf("failed to update user's username")
+		return database.NotFound("user wasn't found")
 	}
 
 	return nil
 }
 
-func (s userService) UpdatePassword(ctx context.Context, runner runner, user models.User, newPassword string) error {
+func (s userService) UpdatePassword(ctx context.Context, user models.User, newPassword string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username":     user.Username,
 		"new_password": newPassword,
@@ -115,33 +176,133 @@ func (s userService) UpdatePassword(ctx context.Context, runner runner, user mod
 
 	result, err := runner.Run(ctx, s.updatePasswordCypher, params)
 	if err != nil {
-		return fmt.Errorf("failed to update user's password")
+		return database.Internal(err, "failed to update user's password")
 	}
 
 	count, err := internal.GetSingle[int64](ctx, result, "c")
 	if err != nil {
-		switch err.(type) {
-		case internal.ErrorNoRecords, internal.ErrorNilRecord:
-			return fmt.Errorf("user wasn't found")
+		switch {
+		case internal.IsNotFound(err):
+			return database.NotFound("user wasn't found")
 		default:
-			return fmt.Errorf("failed to update user's password")
+			return database.Internal(err, "failed to update user's password")
 		}
 	}
 
 	if count <= 0 {
-		return fmt.Errorf("failed to update user's password")
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UpdateEmail(ctx context.Context, user models.User, newEmail string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username":  user.Username,
+		"new_email": emailOrNil(newEmail),
+	}
+
+	result, err := runner.Run(ctx, s.updateEmailCypher, params)
+	if err != nil {
+		if neo4jErr, ok := err.(*neo4j.Neo4jError); ok && neo4jErr.Code == ConstraintValidationFailed {
+			return database.Conflict("email already taken")
+		}
+		return database.Internal(err, "failed to update user's email")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UpdateTwoFactor(ctx context.Context, user models.User, secret string, enabled bool, recoveryCodes []string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username":       user.Username,
+		"secret":         secret,
+		"enabled":        enabled,
+		"recovery_codes": recoveryCodes,
+	}
+
+	result, err := runner.Run(ctx, s.updateTwoFactorCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to update user's two-factor authentication")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("user wasn't found")
 	}
 
 	return nil
 }
 
-func (s userService) Delete(ctx context.Context, runner runner, user models.User) error {
+func (s userService) UseTOTPStep(ctx context.Context, user models.User, step int64) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+		"step":     step,
+	}
+
+	result, err := runner.Run(ctx, s.useTOTPStepCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to use the code")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("code was already used")
+	}
+
+	return nil
+}
+
+func (s userService) UseRecoveryCode(ctx context.Context, user models.User, hash string) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
+	params := map[string]any{
+		"username": user.Username,
+		"hash":     hash,
+	}
+
+	result, err := runner.Run(ctx, s.useRecoveryCodeCypher, params)
+	if err != nil {
+		return database.Internal(err, "failed to use the recovery code")
+	}
+
+	if count, err := internal.GetSingle[int64](ctx, result, "c"); count <= 0 || err != nil {
+		return database.NotFound("recovery code wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) Delete(ctx context.Context, user models.User) error {
+	runner, done := getRunner(ctx)
+	defer done()
+
 	params := map[string]any{
 		"username": user.Username,
 	}
 
 	if _, err := runner.Run(ctx, s.deleteCypher, params); err != nil {
-		return fmt.Errorf("failed to delete the user")
+		return database.Internal(err, "failed to delete the user")
 	}
 	return nil
 }
+
+// NOTE: Users without an email don't have the property, so that the uniqueness constraint ignores them
+func emailOrNil(email string) any {
+	if email == "" {
+		return nil
+	}
+	return email
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// PageRequest asks for at most Limit items of a listing sorted by SortBy, that follow
+// the item the cursor points to. Empty cursor stands for the first page, empty sort key for the default one
+type PageRequest struct {
+	Limit  int
+	After  string
+	SortBy string
+}
+
+// NOTE: Zero limit picks the default one, limit above the maximum is reduced to it
+func (r PageRequest) PageLimit() int {
+	switch {
+	case r.Limit <= 0:
+		return DefaultPageLimit
+	case r.Limit > MaxPageLimit:
+		return MaxPageLimit
+	default:
+		return r.Limit
+	}
+}
+
+// Page is a part of a listing. Next is the cursor of the following page, it's empty on the last one
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+// Cursor points to the last item of a page by its sort key and id. Only one of the key and the time is set,
+// depending on the sort. Clients get it encoded and must treat it as an opaque string
+type Cursor struct {
+	SortBy string    `json:"s"`
+	Key    string    `json:"k,omitempty"`
+	Time   time.Time `json:"t"`
+	Id     string    `json:"i"`
+}
+
+func (c Cursor) Encode() string {
+	bytes, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var cursor Cursor
+
+	bytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, Validation("invalid cursor")
+	}
+	if err := json.Unmarshal(bytes, &cursor); err != nil || cursor.Id == "" {
+		return cursor, Validation("invalid cursor")
+	}
+
+	return cursor, nil
+}
+
+// Sort is an order of a listing. Items are ordered by the key (either a string or a time),
+// ties are broken by the id, so that the order is total and the pages never overlap
+type Sort[T any] struct {
+	Desc   bool
+	Cursor func(item T) Cursor
+}
+
+func (s Sort[T]) compare(a, b Cursor) int {
+	c := strings.Compare(a.Key, b.Key)
+	if c == 0 {
+		c = a.Time.Compare(b.Time)
+	}
+	if s.Desc {
+		c = -c
+	}
+	if c == 0 {
+		c = strings.Compare(a.Id, b.Id)
+	}
+	return c
+}
+
+func (s Sort[T]) Less(a, b T) bool {
+	return s.compare(s.Cursor(a), s.Cursor(b)) < 0
+}
+
+// NOTE: Nil cursor stands for the first page, so every item is after it
+func (s Sort[T]) After(item T, cursor *Cursor) bool {
+	return cursor == nil || s.compare(s.Cursor(item), *cursor) > 0
+}
+
+// ResolvePage finds the sort of the request among the sorts of the listing and decodes its cursor,
+// which is nil for the first page. Cursor made for a different sort is rejected
+func ResolvePage[T any](page PageRequest, sorts map[string]Sort[T]) (string, Sort[T], *Cursor, error) {
+	sortBy := page.SortBy
+	if sortBy == "" {
+		sortBy = SortByName
+	}
+
+	sort, ok := sorts[sortBy]
+	if !ok {
+		return "", sort, nil, Validation("unknown sort key: %s", sortBy)
+	}
+
+	if page.After == "" {
+		return sortBy, sort, nil, nil
+	}
+
+	cursor, err := DecodeCursor(page.After)
+	if err != nil {
+		return "", sort, nil, err
+	}
+	if cursor.SortBy != sortBy {
+		return "", sort, nil, Validation("cursor doesn't match the sort key")
+	}
+
+	return sortBy, sort, &cursor, nil
+}
+
+// Keyset is how the backends page through a listing in the query, the items are ordered by the key and the id,
+// and a page starts right after the item the cursor points to. After is the parameter the key is compared with,
+// either $after_key or $after_time, the id is compared with $after_id. Both Cypher and SQL accept the conditions
+type Keyset struct {
+	Key   string
+	After string
+	Id    string
+	Desc  bool
+}
+
+func (k Keyset) Where() string {
+	op := ">"
+	if k.Desc {
+		op = "<"
+	}
+	return fmt.Sprintf(`($after_id IS NULL OR %[1]s %[4]s %[2]s OR (%[1]s = %[2]s AND %[3]s > $after_id))`, k.Key, k.After, k.Id, op)
+}
+
+func (k Keyset) OrderBy() string {
+	if k.Desc {
+		return fmt.Sprintf("%s DESC, %s", k.Key, k.Id)
+	}
+	return fmt.Sprintf("%s, %s", k.Key, k.Id)
+}
+
+// KeysetQueries makes a query of every sort key, the template takes the keyset condition and the order, in this order
+func KeysetQueries(template string, keysets map[string]Keyset) map[string]string {
+	queries := make(map[string]string, len(keysets))
+	for sortBy, k := range keysets {
+		queries[sortBy] = fmt.Sprintf(template, k.Where(), k.OrderBy())
+	}
+	return queries
+}
+
+// NewPage makes a page of the items fetched with one extra item over the limit,
+// which is only used to tell whether there is a next page
+func NewPage[T any](items []T, limit int, sortBy string, sort Sort[T]) Page[T] {
+	if len(items) <= limit {
+		return Page[T]{Items: items}
+	}
+
+	items = items[:limit]
+	cursor := sort.Cursor(items[limit-1])
+	cursor.SortBy = sortBy
+	return Page[T]{Items: items, Next: cursor.Encode()}
+}
+
+// NOTE: Access has no id of its own, the user can get access to the file
+// from the file itself and from any of its folders, granted by different users
+func AccessId(access models.Access) string {
+	return access.Source + "/" + access.Granter + "/" + access.Receiver
+}
+
+// NOTE: The most recent files go first, when sorted by time
+var FileSorts = map[string]Sort[models.File]{
+	SortByName: {Cursor: func(f models.File) Cursor {
+		return Cursor{Key: f.Name, Id: f.Id}
+	}},
+	SortByCreatedAt: {Desc: true, Cursor: func(f models.File) Cursor {
+		return Cursor{Time: f.CreatedAt, Id: f.Id}
+	}},
+	SortByUpdatedAt: {Desc: true, Cursor: func(f models.File) Cursor {
+		return Cursor{Time: f.UpdatedAt, Id: f.Id}
+	}},
+}
+
+var SharedFileSorts = map[string]Sort[models.SharedFile]{
+	SortByName: {Cursor: func(f models.SharedFile) Cursor {
+		return Cursor{Key: f.Name, Id: f.Id}
+	}},
+	SortByGrantedAt: {Desc: true, Cursor: func(f models.SharedFile) Cursor {
+		return Cursor{Time: f.GrantedAt, Id: f.Id}
+	}},
+}
+
+// NOTE: Accesses are sorted by the name of the receiver
+var AccessSorts = map[string]Sort[models.Access]{
+	SortByName: {Cursor: func(a models.Access) Cursor {
+		return Cursor{Key: a.Receiver, Id: AccessId(a)}
+	}},
+	SortByGrantedAt: {Desc: true, Cursor: func(a models.Access) Cursor {
+		return Cursor{Time: a.GrantedAt, Id: AccessId(a)}
+	}},
+}
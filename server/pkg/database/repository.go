@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+const (
+	SortByName      = "name"
+	SortByGrantedAt = "granted"
+	SortByCreatedAt = "created"
+	SortByUpdatedAt = "updated"
+)
+
+// NOTE: Emails are unique, empty email means the user has none.
+// UpdateTwoFactor replaces the TOTP secret, whether it's enabled and the hashes of the recovery codes.
+// UseTOTPStep records the time step of the accepted code, it's NotFound if the same or a later step was already used.
+// UseRecoveryCode removes the hash, so that the code can't be used again, it's NotFound if there is none
+type UserRepository interface {
+	Create(ctx context.Context, user models.User) error
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	UpdateUsername(ctx context.Context, user models.User, newUsername string) error
+	UpdatePassword(ctx context.Context, user models.User, newPassword string) error
+	UpdateEmail(ctx context.Context, user models.User, newEmail string) error
+	UpdateTwoFactor(ctx context.Context, user models.User, secret string, enabled bool, recoveryCodes []string) error
+	UseTOTPStep(ctx context.Context, user models.User, step int64) error
+	UseRecoveryCode(ctx context.Context, user models.User, hash string) error
+	Delete(ctx context.Context, user models.User) error
+}
+
+type FileRepository interface {
+	Create(ctx context.Context, file models.File, owner models.User) error
+	GetById(ctx context.Context, id uuid.UUID) (models.File, error)
+	GetOwner(ctx context.Context, file models.File) (models.User, error)
+	GetAll(ctx context.Context, page PageRequest) (Page[models.File], error)
+	GetAllForOwner(ctx context.Context, owner models.User, filter FileFilter, page PageRequest) (Page[models.File], error)
+	GetAllSharedWith(ctx context.Context, user models.User, level string, page PageRequest) (Page[models.SharedFile], error)
+	GetContent(ctx context.Context, file models.File) (models.Content, error)
+	UpdateName(ctx context.Context, file models.File, name string, editor models.User) error
+	UpdateContent(ctx context.Context, file models.File, content models.Content, editor models.User) error
+	Delete(ctx context.Context, file models.File) error
+	DeleteAllForOwner(ctx context.Context, owner models.User) error
+}
+
+type FolderRepository interface {
+	Create(ctx context.Context, folder models.Folder, parent *models.Folder, owner models.User) error
+	GetById(ctx context.Context, id uuid.UUID) (models.Folder, error)
+	GetOwner(ctx context.Context, folder models.Folder) (models.User, error)
+	GetChildren(ctx context.Context, folder models.Folder) ([]models.Folder, []models.File, error)
+	GetRootChildren(ctx context.Context, owner models.User) ([]models.Folder, []models.File, error)
+	GetTree(ctx context.Context, owner models.User) (models.FolderTree, error)
+	UpdateName(ctx context.Context, folder models.Folder, name string) error
+	Move(ctx context.Context, folder models.Folder, parent *models.Folder) error
+	MoveFile(ctx context.Context, file models.File, parent *models.Folder) error
+	Delete(ctx context.Context, folder models.Folder) error
+}
+
+type RevisionRepository interface {
+	Create(ctx context.Context, file models.File, revision models.Revision) error
+	GetById(ctx context.Context, file models.File, id uuid.UUID) (models.Revision, error)
+	GetLatest(ctx context.Context, file models.File) (models.Revision, error)
+	GetAll(ctx context.Context, file models.File) ([]models.Revision, error)
+}
+
+type AccessRepository interface {
+	Grant(ctx context.Context, file models.File, access models.Access) error
+	GrantForFolder(ctx context.Context, folder models.Folder, access models.Access) error
+	Get(ctx context.Context, file models.File, user models.User) (models.Access, error)
+	GetForFolder(ctx context.Context, folder models.Folder, user models.User) (models.Access, error)
+	GetAccesses(ctx context.Context, file models.File, page PageRequest) (Page[models.Access], error)
+	GetAccessesForFolder(ctx context.Context, folder models.Folder, page PageRequest) (Page[models.Access], error)
+	GetLevel(ctx context.Context, file models.File, user models.User) (string, error)
+	GetLevelForFolder(ctx context.Context, folder models.Folder, user models.User) (string, error)
+	UpdateLevel(ctx context.Context, file models.File, access models.Access, newLevel string) error
+	UpdateLevelForFolder(ctx context.Context, folder models.Folder, access models.Access, newLevel string) error
+	Revoke(ctx context.Context, file models.File, access models.Access) error
+	RevokeForFolder(ctx context.Context, folder models.Folder, access models.Access) error
+}
+
+// NOTE: Expired sessions aren't found, Refresh stores the new last seen and expiry times
+// of an unexpired session. DeleteExpired returns the number of deleted sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session models.Session) error
+	Check(ctx context.Context, id uuid.UUID) (models.User, models.Session, error)
+	Refresh(ctx context.Context, session models.Session) error
+	GetAll(ctx context.Context, user models.User) ([]models.Session, error)
+	Delete(ctx context.Context, user models.User, id uuid.UUID) error
+	DeleteAllExcept(ctx context.Context, user models.User, id uuid.UUID) error
+	DeleteAll(ctx context.Context, user models.User) error
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+// NOTE: Creating a reset replaces the previous ones of the user, only the latest mailed one works.
+// Use deletes the reset, so that it can't be used again, expired resets are NotFound
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset models.PasswordReset) error
+	Use(ctx context.Context, hash string) (models.User, error)
+}
+
+// NOTE: Tokens are looked up by the hash of the secret, expired tokens aren't found
+type TokenRepository interface {
+	Create(ctx context.Context, token models.Token) error
+	Check(ctx context.Context, hash string) (models.User, models.Token, error)
+	GetAll(ctx context.Context, user models.User) ([]models.Token, error)
+	Revoke(ctx context.Context, user models.User, id uuid.UUID) error
+}
+
+// SearchRepository looks for the terms of the query in names and contents of the files
+// the user owns or has access to, the most relevant go first
+type SearchRepository interface {
+	Search(ctx context.Context, user models.User, query string, limit int) ([]models.SearchResult, error)
+}
+
+// Transactor runs the function in a transaction. Repositories called with
+// the context passed to the function take part in that transaction.
+// Transaction is committed if the function returns nil, and rolled back otherwise.
+type Transactor interface {
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Repositories is a storage backend
+type Repositories struct {
+	Users      UserRepository
+	Files      FileRepository
+	Folders    FolderRepository
+	Revisions  RevisionRepository
+	Access     AccessRepository
+	Sessions   SessionRepository
+	Tokens     TokenRepository
+	Resets     PasswordResetRepository
+	Search     SearchRepository
+	Transactor Transactor
+}
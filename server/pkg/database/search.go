@@ -0,0 +1,165 @@
+package database
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	maxSnippets   = 3
+	snippetRadius = 40
+)
+
+// SearchTerms splits the query into lowercase words, repeated words are dropped
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isSeparator) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Score counts occurrences of the terms in the name and the content, matches in the name
+// weigh more. Zero score means none of the terms was found
+func Score(name, content string, terms []string) float64 {
+	name, content = strings.ToLower(name), strings.ToLower(content)
+
+	score := 0.0
+	for _, term := range terms {
+		score += 3*float64(strings.Count(name, term)) + float64(strings.Count(content, term))
+	}
+	return score
+}
+
+// Snippets highlights the terms in the name and in up to a few fragments of the content around the matches.
+// The name is only included if it matches
+func Snippets(name, content string, terms []string) []string {
+	snippets := []string{}
+	if spans := matches(name, terms); len(spans) > 0 {
+		snippets = append(snippets, highlight([]rune(name), spans))
+	}
+
+	runes := []rune(content)
+	spans := matches(content, terms)
+
+	for i, n := 0, 0; i < len(spans) && n < maxSnippets; n++ {
+		start, end := max(spans[i].start-snippetRadius, 0), min(spans[i].end+snippetRadius, len(runes))
+
+		// NOTE: Matches close to each other share a snippet
+		j := i
+		for j < len(spans) && spans[j].start < end {
+			end = min(max(end, spans[j].end+snippetRadius), len(runes))
+			j++
+		}
+
+		fragment := []span{}
+		for _, s := range spans[i:j] {
+			fragment = append(fragment, span{s.start - start, s.end - start})
+		}
+
+		snippet := strings.TrimSpace(highlight(runes[start:end], fragment))
+		if start > 0 {
+			snippet = "…" + snippet
+		}
+		if end < len(runes) {
+			snippet = snippet + "…"
+		}
+		snippets = append(snippets, snippet)
+
+		i = j
+	}
+
+	return snippets
+}
+
+// span is a match of a term, in runes
+type span struct {
+	start, end int
+}
+
+// NOTE: Overlapping matches of different terms are merged
+func matches(text string, terms []string) []span {
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len([]rune(text)) {
+		// NOTE: Lowercasing changed the number of runes, positions can't be mapped back
+		return nil
+	}
+
+	spans := []span{}
+	for _, term := range terms {
+		needle := []rune(term)
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) == term {
+				spans = append(spans, span{i, i + len(needle)})
+			}
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	merged := []span{}
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func highlight(text []rune, spans []span) string {
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(html.EscapeString(string(text[last:s.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(text[s.start:s.end])))
+		b.WriteString("</mark>")
+		last = s.end
+	}
+	b.WriteString(html.EscapeString(string(text[last:])))
+	return b.String()
+}
+
+// SortSearchResults orders the results by the score, the most relevant first, and cuts them to the limit
+func SortSearchResults(results []models.SearchResult, limit int) []models.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].File.Id < results[j].File.Id
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// NOTE: Zero limit picks the default one, limit above the maximum is reduced to it
+func SearchLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultSearchLimit
+	case limit > MaxSearchLimit:
+		return MaxSearchLimit
+	default:
+		return limit
+	}
+}
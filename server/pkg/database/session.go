@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// SweepSessions deletes the expired sessions every interval, until the context is done
+func SweepSessions(ctx context.Context, sessions SessionRepository, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := sessions.DeleteExpired(ctx); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+// NOTE: Access can be granted either to a file or to a folder,
+// the same queries are used for both with a different table
+type accessQueries struct {
+	resource string
+
+	grantQuery string
+
+	getQuery      string
+	getLevelQuery string
+
+	getAccessors listing[models.Access]
+
+	updateLevelQuery string
+
+	revokeQuery string
+}
+
+// NOTE: Path is the resource itself followed by all the folders containing it,
+// grants to any of them apply to the resource
+const pathQuery = `WITH RECURSIVE
+	path (kind, id, parent_id, depth) AS (
+		SELECT '%[1]s', id, parent_id, 0 FROM %[1]ss WHERE id = $id
+		UNION ALL
+		SELECT 'folder', d.id, d.parent_id, p.depth + 1 FROM folders d JOIN path p ON d.id = p.parent_id
+	),
+	grants (kind, resource_id, receiver, granter, level, granted_at) AS (
+		SELECT 'file', resource_id, receiver, granter, level, granted_at FROM file_accesses
+		UNION ALL
+		SELECT 'folder', resource_id, receiver, granter, level, granted_at FROM folder_accesses
+	)`
+
+// NOTE: Mirrors database.AccessId
+const accessIdQuery = `source || '/' || granter || '/' || receiver`
+
+func newAccessQueries(resource string) accessQueries {
+	path := fmt.Sprintf(pathQuery, resource)
+
+	return accessQueries{
+		resource: resource,
+
+		grantQuery: fmt.Sprintf(`INSERT INTO %[1]s_accesses (resource_id, receiver, granter, level, granted_at)
+			SELECT r.id, u.username, $granter, $level, $granted_at FROM %[1]ss r, users u WHERE r.id = $id AND u.username = $receiver`, resource),
+
+		getQuery: fmt.Sprintf(`SELECT granter, receiver, level, granted_at FROM %s_accesses WHERE resource_id = $id AND receiver = $username LIMIT 1`, resource),
+		// NOTE: Owner has the highest level, otherwise the most permissive of direct and inherited grants wins
+		getLevelQuery: path + fmt.Sprintf(`,
+			levels (level) AS (
+				SELECT g.level FROM path p JOIN grants g ON g.kind = p.kind AND g.resource_id = p.id WHERE g.receiver = $username
+			)
+			SELECT CASE
+				WHEN r.owner = $username THEN 'O'
+				WHEN EXISTS (SELECT 1 FROM levels WHERE level = 'RW') THEN 'RW'
+				WHEN EXISTS (SELECT 1 FROM levels WHERE level = 'R') THEN 'R'
+				ELSE ''
+			END FROM %ss r WHERE r.id = $id`, resource),
+
+		// NOTE: Grants on the folders containing the target are inherited
+		getAccessors: newListing(
+			path+`,
+			accessors (granter, receiver, level, inherited, source, granted_at) AS (
+				SELECT g.granter, g.receiver, g.level, p.depth > 0, p.id, g.granted_at
+				FROM path p JOIN grants g ON g.kind = p.kind AND g.resource_id = p.id
+			)
+			SELECT granter, receiver, level, inherited, source, granted_at FROM accessors
+			WHERE %s ORDER BY %s LIMIT $limit`,
+			map[string]database.Keyset{
+				database.SortByName:      {Key: "receiver", After: "$after_key", Id: accessIdQuery},
+				database.SortByGrantedAt: {Key: "granted_at", After: "$after_time", Id: accessIdQuery, Desc: true},
+			},
+			database.AccessSorts, scanAccess, "failed to get accessors",
+		),
+
+		updateLevelQuery: fmt.Sprintf(`UPDATE %s_accesses SET level = $new_level WHERE resource_id = $id AND receiver = $receiver AND granter = $granter`, resource),
+
+		revokeQuery: fmt.Sprintf(`DELETE FROM %s_accesses WHERE resource_id = $id AND receiver = $receiver AND granter = $granter`, resource),
+	}
+}
+
+type accessService struct {
+	db *sql.DB
+
+	file   accessQueries
+	folder accessQueries
+}
+
+func NewAccessService(db *sql.DB) *accessService {
+	return &accessService{
+		db: db,
+
+		file:   newAccessQueries("file"),
+		folder: newAccessQueries("folder"),
+	}
+}
+
+func (s accessService) Grant(ctx context.Context, file models.File, access models.Access) error {
+	return s.grant(ctx, s.file, file.Id, access)
+}
+
+func (s accessService) GrantForFolder(ctx context.Context, folder models.Folder, access models.Access) error {
+	return s.grant(ctx, s.folder, folder.Id, access)
+}
+
+func (s accessService) grant(ctx context.Context, queries accessQueries, id string, access models.Access) error {
+	switch access.Level {
+	case models.RWAccess, models.RAcess:
+	default:
+		return database.Validation("unknown access level value: %s", access.Level)
+	}
+
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, queries.grantQuery,
+		sql.Named("receiver", access.Receiver),
+		sql.Named("id", id),
+		sql.Named("granter", access.Granter),
+		sql.Named("level", access.Level),
+		sql.Named("granted_at", toTimestamp(time.Now())),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to grant an access")
+	}
+
+	return nil
+}
+
+// NOTE: Only the access granted to the file directly is returned
+func (s accessService) Get(ctx context.Context, file models.File, user models.User) (models.Access, error) {
+	return s.get(ctx, s.file, file.Id, user)
+}
+
+func (s accessService) GetForFolder(ctx context.Context, folder models.Folder, user models.User) (models.Access, error) {
+	return s.get(ctx, s.folder, folder.Id, user)
+}
+
+func (s accessService) get(ctx context.Context, queries accessQueries, id string, user models.User) (models.Access, error) {
+	var grantedAt int64
+	access := models.Access{Source: id}
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, queries.getQuery,
+		sql.Named("username", user.Username),
+		sql.Named("id", id),
+	).Scan(&access.Granter, &access.Receiver, &access.Level, &grantedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Access{}, database.NotFound("access wasn't found")
+		}
+		return models.Access{}, database.Internal(err, "failed to get the access")
+	}
+
+	access.GrantedAt = fromTimestamp(grantedAt)
+	return access, nil
+}
+
+// NOTE: Both direct and inherited accesses are returned
+func (s accessService) GetAccesses(ctx context.Context, file models.File, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.file.getAccessors.get(ctx, s.db, page, sql.Named("id", file.Id))
+}
+
+func (s accessService) GetAccessesForFolder(ctx context.Context, folder models.Folder, page database.PageRequest) (database.Page[models.Access], error) {
+	return s.folder.getAccessors.get(ctx, s.db, page, sql.Named("id", folder.Id))
+}
+
+func scanAccess(rows scanner) (models.Access, error) {
+	var (
+		access    models.Access
+		grantedAt int64
+	)
+	if err := rows.Scan(&access.Granter, &access.Receiver, &access.Level, &access.Inherited, &access.Source, &grantedAt); err != nil {
+		return models.Access{}, err
+	}
+	access.GrantedAt = fromTimestamp(grantedAt)
+	return access, nil
+}
+
+// GetLevel resolves the effective access level of the user to the file,
+// taking ownership and the accesses inherited from the folders into account
+func (s accessService) GetLevel(ctx context.Context, file models.File, user models.User) (string, error) {
+	return s.getLevel(ctx, s.file, file.Id, user)
+}
+
+func (s accessService) GetLevelForFolder(ctx context.Context, folder models.Folder, user models.User) (string, error) {
+	return s.getLevel(ctx, s.folder, folder.Id, user)
+}
+
+func (s accessService) getLevel(ctx context.Context, queries accessQueries, id string, user models.User) (string, error) {
+	var level string
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, queries.getLevelQuery,
+		sql.Named("username", user.Username),
+		sql.Named("id", id),
+	).Scan(&level)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", database.NotFound("%s wasn't found", queries.resource)
+		}
+		return "", database.Internal(err, "failed to get the access level")
+	}
+
+	if level == "" {
+		return "", database.Forbidden("access wasn't found")
+	}
+
+	return level, nil
+}
+
+func (s accessService) UpdateLevel(ctx context.Context, file models.File, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, s.file, file.Id, access, newLevel)
+}
+
+func (s accessService) UpdateLevelForFolder(ctx context.Context, folder models.Folder, access models.Access, newLevel string) error {
+	return s.updateLevel(ctx, s.folder, folder.Id, access, newLevel)
+}
+
+func (s accessService) updateLevel(ctx context.Context, queries accessQueries, id string, access models.Access, newLevel string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, queries.updateLevelQuery,
+		sql.Named("receiver", access.Receiver),
+		sql.Named("granter", access.Granter),
+		sql.Named("id", id),
+		sql.Named("new_level", newLevel),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update access level")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("access record wasn't found")
+	}
+
+	return nil
+}
+
+func (s accessService) Revoke(ctx context.Context, file models.File, access models.Access) error {
+	return s.revoke(ctx, s.file, file.Id, access)
+}
+
+func (s accessService) RevokeForFolder(ctx context.Context, folder models.Folder, access models.Access) error {
+	return s.revoke(ctx, s.folder, folder.Id, access)
+}
+
+func (s accessService) revoke(ctx context.Context, queries accessQueries, id string, access models.Access) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, queries.revokeQuery,
+		sql.Named("receiver", access.Receiver),
+		sql.Named("granter", access.Granter),
+		sql.Named("id", id),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to revoke the access")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+)
+
+type runner interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type transactionKey struct{}
+
+// NOTE: Queries run in the transaction carried by the context, if there is one
+func getRunner(ctx context.Context, db *sql.DB) runner {
+	if tx, ok := ctx.Value(transactionKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+type transactor struct {
+	db *sql.DB
+}
+
+func (t transactor) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(transactionKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Internal(err, "failed to start a database transaction")
+	}
+
+	if err := fn(context.WithValue(ctx, transactionKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.Internal(err, "failed to commit the database transaction")
+	}
+	return nil
+}
+
+func rowsAffected(result sql.Result) int64 {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+	return count
+}
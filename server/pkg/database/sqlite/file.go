@@ -0,0 +1,296 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type fileService struct {
+	db *sql.DB
+
+	createQuery string
+
+	getByIdQuery    string
+	getOwnerQuery   string
+	getContentQuery string
+
+	getAll           listing[models.File]
+	getAllForOwner   listing[models.File]
+	getAllSharedWith listing[models.SharedFile]
+
+	updateNameQuery    string
+	updateContentQuery string
+
+	deleteQuery            string
+	deleteAllForOwnerQuery string
+}
+
+func NewFileService(db *sql.DB) *fileService {
+	return &fileService{
+		db: db,
+
+		createQuery: `INSERT INTO files (id, name, owner, created_at, updated_at, last_edited_by)
+			SELECT $id, $name, username, $now, $now, username FROM users WHERE username = $username`,
+
+		getByIdQuery:    `SELECT id, name, created_at, updated_at, last_edited_by FROM files WHERE id = $id`,
+		getOwnerQuery:   `SELECT u.username, u.password FROM files f JOIN users u ON u.username = f.owner WHERE f.id = $id`,
+		getContentQuery: `SELECT content, content_state FROM files WHERE id = $id`,
+
+		getAll: newListing(
+			`SELECT id, name, created_at, updated_at, last_edited_by FROM files WHERE %s ORDER BY %s LIMIT $limit`,
+			fileKeysets, database.FileSorts, scanFile, "failed to get all files from the database",
+		),
+		getAllForOwner: newListing(
+			ownedQuery, fileKeysets, database.FileSorts, scanFile, "failed to get all files for owner from the database",
+		),
+		getAllSharedWith: newListing(
+			sharedWithQuery,
+			map[string]database.Keyset{
+				database.SortByName:      {Key: "f.name", After: "$after_key", Id: "f.id"},
+				database.SortByGrantedAt: {Key: "g.granted_at", After: "$after_time", Id: "f.id", Desc: true},
+			},
+			database.SharedFileSorts, scanSharedFile, "failed to get shared files from the database",
+		),
+
+		updateNameQuery:    `UPDATE files SET name = $new_name, updated_at = $now, last_edited_by = $editor WHERE id = $id`,
+		updateContentQuery: `UPDATE files SET content = $content, content_state = $state, updated_at = $now, last_edited_by = $editor WHERE id = $id`,
+
+		// NOTE: Revisions and accesses are deleted by the foreign keys
+		deleteQuery:            `DELETE FROM files WHERE id = $id`,
+		deleteAllForOwnerQuery: `DELETE FROM files WHERE owner = $username`,
+	}
+}
+
+var fileKeysets = map[string]database.Keyset{
+	database.SortByName:      {Key: "name", After: "$after_key", Id: "id"},
+	database.SortByCreatedAt: {Key: "created_at", After: "$after_time", Id: "id", Desc: true},
+	database.SortByUpdatedAt: {Key: "updated_at", After: "$after_time", Id: "id", Desc: true},
+}
+
+// NOTE: Filter parameters left null match any file
+const ownedQuery = `SELECT id, name, created_at, updated_at, last_edited_by FROM files
+	WHERE owner = $username
+	AND ($edited_by IS NULL OR last_edited_by = $edited_by)
+	AND ($created_after IS NULL OR created_at > $created_after)
+	AND ($created_before IS NULL OR created_at < $created_before)
+	AND ($updated_after IS NULL OR updated_at > $updated_after)
+	AND ($updated_before IS NULL OR updated_at < $updated_before)
+	AND %s
+	ORDER BY %s LIMIT $limit`
+
+// NOTE: Grants to a folder apply to every file in its subtree. For every file only the most
+// permissive grant is kept (the latest one among equals)
+const sharedWithQuery = `WITH RECURSIVE
+	granted_folders (id, level, granter, granted_at) AS (
+		SELECT resource_id, level, granter, granted_at FROM folder_accesses WHERE receiver = $username
+		UNION ALL
+		SELECT d.id, g.level, g.granter, g.granted_at FROM folders d JOIN granted_folders g ON d.parent_id = g.id
+	),
+	grants (file_id, level, granter, granted_at) AS (
+		SELECT resource_id, level, granter, granted_at FROM file_accesses WHERE receiver = $username
+		UNION ALL
+		SELECT f.id, g.level, g.granter, g.granted_at FROM files f JOIN granted_folders g ON f.parent_id = g.id
+	),
+	ranked (file_id, level, granter, granted_at, n) AS (
+		SELECT file_id, level, granter, granted_at, ROW_NUMBER() OVER (
+			PARTITION BY file_id ORDER BY CASE level WHEN 'RW' THEN 0 ELSE 1 END, granted_at DESC
+		) FROM grants
+	)
+	SELECT f.id, f.name, f.owner, g.level, g.granter, g.granted_at
+	FROM ranked g JOIN files f ON f.id = g.file_id
+	WHERE g.n = 1 AND f.owner <> $username AND ($level = '' OR g.level = $level) AND %s
+	ORDER BY %s LIMIT $limit`
+
+func (s fileService) Create(ctx context.Context, file models.File, owner models.User) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, s.createQuery,
+		sql.Named("username", owner.Username),
+		sql.Named("id", file.Id),
+		sql.Named("name", file.Name),
+		sql.Named("now", toTimestamp(time.Now())),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return database.Conflict("file with this id already exists")
+		} else {
+			return database.Internal(err, "failed to store the file in the database")
+		}
+	}
+
+	return nil
+}
+
+func (s fileService) GetById(ctx context.Context, id uuid.UUID) (models.File, error) {
+	file, err := scanFile(getRunner(ctx, s.db).QueryRowContext(ctx, s.getByIdQuery, sql.Named("id", id.String())))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.File{}, database.NotFound("file wasn't found")
+		}
+		return models.File{}, database.Internal(err, "failed to get the file from the database")
+	}
+
+	return file, nil
+}
+
+func (s fileService) GetOwner(ctx context.Context, file models.File) (models.User, error) {
+	var owner models.User
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.getOwnerQuery, sql.Named("id", file.Id)).
+		Scan(&owner.Username, &owner.Password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, database.NotFound("owner wasn't found")
+		}
+		return models.User{}, database.Internal(err, "failed to get the file's owner from the database")
+	}
+
+	return owner, nil
+}
+
+// NOTE: Every file of every user, used to rebuild the search index
+func (s fileService) GetAll(ctx context.Context, page database.PageRequest) (database.Page[models.File], error) {
+	return s.getAll.get(ctx, s.db, page)
+}
+
+func (s fileService) GetAllForOwner(ctx context.Context, owner models.User, filter database.FileFilter, page database.PageRequest) (database.Page[models.File], error) {
+	var editedBy any
+	if filter.LastEditedBy != "" {
+		editedBy = filter.LastEditedBy
+	}
+
+	return s.getAllForOwner.get(ctx, s.db, page,
+		sql.Named("username", owner.Username),
+		sql.Named("edited_by", editedBy),
+		sql.Named("created_after", timestampOrNil(filter.CreatedAfter)),
+		sql.Named("created_before", timestampOrNil(filter.CreatedBefore)),
+		sql.Named("updated_after", timestampOrNil(filter.UpdatedAfter)),
+		sql.Named("updated_before", timestampOrNil(filter.UpdatedBefore)),
+	)
+}
+
+func timestampOrNil(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return toTimestamp(t)
+}
+
+// GetAllSharedWith returns files other users shared with the user. Level filters the files
+// by the access level (empty level matches any), they are sorted either by database.SortByName or database.SortByGrantedAt.
+func (s fileService) GetAllSharedWith(ctx context.Context, user models.User, level string, page database.PageRequest) (database.Page[models.SharedFile], error) {
+	return s.getAllSharedWith.get(ctx, s.db, page,
+		sql.Named("username", user.Username),
+		sql.Named("level", level),
+	)
+}
+
+func scanSharedFile(rows scanner) (models.SharedFile, error) {
+	var (
+		file      models.SharedFile
+		grantedAt int64
+	)
+	if err := rows.Scan(&file.Id, &file.Name, &file.Owner, &file.Level, &file.GrantedBy, &grantedAt); err != nil {
+		return models.SharedFile{}, err
+	}
+	file.GrantedAt = fromTimestamp(grantedAt)
+	return file, nil
+}
+
+func (s fileService) GetContent(ctx context.Context, file models.File) (models.Content, error) {
+	var content models.Content
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.getContentQuery, sql.Named("id", file.Id)).
+		Scan(&content.Text, &content.State)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Content{}, database.NotFound("file wasn't found")
+		}
+		return models.Content{}, database.Internal(err, "failed to get file's content from the database")
+	}
+
+	return content, nil
+}
+
+func (s fileService) UpdateName(ctx context.Context, file models.File, name string, editor models.User) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateNameQuery,
+		sql.Named("id", file.Id),
+		sql.Named("new_name", name),
+		sql.Named("now", toTimestamp(time.Now())),
+		sql.Named("editor", editor.Username),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update file's name")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("file wasn't found")
+	}
+
+	return nil
+}
+
+func (s fileService) UpdateContent(ctx context.Context, file models.File, content models.Content, editor models.User) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateContentQuery,
+		sql.Named("id", file.Id),
+		sql.Named("content", content.Text),
+		sql.Named("state", content.State),
+		sql.Named("now", toTimestamp(time.Now())),
+		sql.Named("editor", editor.Username),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update file's content")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("file wasn't found")
+	}
+
+	return nil
+}
+
+func (s fileService) Delete(ctx context.Context, file models.File) error {
+	if _, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteQuery, sql.Named("id", file.Id)); err != nil {
+		return database.Internal(err, "failed to delete the file")
+	}
+
+	return nil
+}
+
+func (s fileService) DeleteAllForOwner(ctx context.Context, owner models.User) error {
+	if _, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteAllForOwnerQuery, sql.Named("username", owner.Username)); err != nil {
+		return database.Internal(err, "failed to delete all files for owner")
+	}
+
+	return nil
+}
+
+func scanFiles(rows *sql.Rows) ([]models.File, error) {
+	defer rows.Close()
+
+	files := []models.File{}
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+// NOTE: Scans the columns id, name, created_at, updated_at and last_edited_by, in this order
+func scanFile(row scanner) (models.File, error) {
+	var (
+		file                 models.File
+		createdAt, updatedAt int64
+	)
+	if err := row.Scan(&file.Id, &file.Name, &createdAt, &updatedAt, &file.LastEditedBy); err != nil {
+		return models.File{}, err
+	}
+	file.CreatedAt, file.UpdatedAt = fromTimestamp(createdAt), fromTimestamp(updatedAt)
+	return file, nil
+}
@@ -0,0 +1,354 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type folderService struct {
+	db *sql.DB
+
+	createQuery         string
+	createInFolderQuery string
+
+	getByIdQuery           string
+	getOwnerQuery          string
+	getChildFoldersQuery   string
+	getChildFilesQuery     string
+	getRootFoldersQuery    string
+	getRootFilesQuery      string
+	getFoldersForTreeQuery string
+	getFilesForTreeQuery   string
+
+	updateNameQuery     string
+	moveQuery           string
+	moveToRootQuery     string
+	moveFileQuery       string
+	moveFileToRootQuery string
+
+	deleteFilesQuery   string
+	deleteFoldersQuery string
+}
+
+func NewFolderService(db *sql.DB) *folderService {
+	return &folderService{
+		db: db,
+
+		createQuery:         `INSERT INTO folders (id, name, owner) SELECT $id, $name, username FROM users WHERE username = $username`,
+		createInFolderQuery: `INSERT INTO folders (id, name, owner, parent_id) SELECT $id, $name, u.username, p.id FROM users u, folders p WHERE u.username = $username AND p.id = $parent_id`,
+
+		getByIdQuery:           `SELECT id, name FROM folders WHERE id = $id`,
+		getOwnerQuery:          `SELECT u.username, u.password FROM folders d JOIN users u ON u.username = d.owner WHERE d.id = $id`,
+		getChildFoldersQuery:   `SELECT id, name FROM folders WHERE parent_id = $id ORDER BY name`,
+		getChildFilesQuery:     `SELECT id, name, created_at, updated_at, last_edited_by FROM files WHERE parent_id = $id ORDER BY name`,
+		getRootFoldersQuery:    `SELECT id, name FROM folders WHERE owner = $username AND parent_id IS NULL ORDER BY name`,
+		getRootFilesQuery:      `SELECT id, name, created_at, updated_at, last_edited_by FROM files WHERE owner = $username AND parent_id IS NULL ORDER BY name`,
+		getFoldersForTreeQuery: `SELECT id, name, coalesce(parent_id, '') FROM folders WHERE owner = $username ORDER BY name`,
+		getFilesForTreeQuery:   `SELECT id, name, created_at, updated_at, last_edited_by, coalesce(parent_id, '') FROM files WHERE owner = $username ORDER BY name`,
+
+		updateNameQuery: `UPDATE folders SET name = $new_name WHERE id = $id`,
+		// NOTE: Folder can't be moved into itself or into any of its subfolders
+		moveQuery: `WITH RECURSIVE ancestors (id) AS (
+				SELECT $parent_id
+				UNION ALL
+				SELECT d.parent_id FROM folders d JOIN ancestors a ON d.id = a.id WHERE d.parent_id IS NOT NULL
+			)
+			UPDATE folders SET parent_id = $parent_id
+			WHERE id = $id AND EXISTS (SELECT 1 FROM folders WHERE id = $parent_id) AND id NOT IN ancestors`,
+		moveToRootQuery:     `UPDATE folders SET parent_id = NULL WHERE id = $id`,
+		moveFileQuery:       `UPDATE files SET parent_id = $parent_id WHERE id = $id AND EXISTS (SELECT 1 FROM folders WHERE id = $parent_id)`,
+		moveFileToRootQuery: `UPDATE files SET parent_id = NULL WHERE id = $id`,
+
+		deleteFilesQuery:   fmt.Sprintf(`%s DELETE FROM files WHERE parent_id IN subtree`, subtreeQuery),
+		deleteFoldersQuery: fmt.Sprintf(`%s DELETE FROM folders WHERE id IN subtree`, subtreeQuery),
+	}
+}
+
+const subtreeQuery = `WITH RECURSIVE subtree (id) AS (
+	SELECT $id
+	UNION ALL
+	SELECT d.id FROM folders d JOIN subtree s ON d.parent_id = s.id
+)`
+
+// NOTE: Folder is created in the root, if parent is nil
+func (s folderService) Create(ctx context.Context, folder models.Folder, parent *models.Folder, owner models.User) error {
+	args := []any{
+		sql.Named("username", owner.Username),
+		sql.Named("id", folder.Id),
+		sql.Named("name", folder.Name),
+	}
+
+	query := s.createQuery
+	if parent != nil {
+		query = s.createInFolderQuery
+		args = append(args, sql.Named("parent_id", parent.Id))
+	}
+
+	if _, err := getRunner(ctx, s.db).ExecContext(ctx, query, args...); err != nil {
+		if isUniqueViolation(err) {
+			return database.Conflict("folder with this id already exists")
+		} else {
+			return database.Internal(err, "failed to store the folder in the database")
+		}
+	}
+
+	return nil
+}
+
+func (s folderService) GetById(ctx context.Context, id uuid.UUID) (models.Folder, error) {
+	var folder models.Folder
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.getByIdQuery, sql.Named("id", id.String())).
+		Scan(&folder.Id, &folder.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Folder{}, database.NotFound("folder wasn't found")
+		}
+		return models.Folder{}, database.Internal(err, "failed to get the folder from the database")
+	}
+
+	return folder, nil
+}
+
+func (s folderService) GetOwner(ctx context.Context, folder models.Folder) (models.User, error) {
+	var owner models.User
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.getOwnerQuery, sql.Named("id", folder.Id)).
+		Scan(&owner.Username, &owner.Password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, database.NotFound("owner wasn't found")
+		}
+		return models.User{}, database.Internal(err, "failed to get the folder's owner from the database")
+	}
+
+	return owner, nil
+}
+
+func (s folderService) GetChildren(ctx context.Context, folder models.Folder) ([]models.Folder, []models.File, error) {
+	return s.getChildren(ctx, s.getChildFoldersQuery, s.getChildFilesQuery, sql.Named("id", folder.Id))
+}
+
+// NOTE: Root children are owner's folders and files that aren't contained in any folder
+func (s folderService) GetRootChildren(ctx context.Context, owner models.User) ([]models.Folder, []models.File, error) {
+	return s.getChildren(ctx, s.getRootFoldersQuery, s.getRootFilesQuery, sql.Named("username", owner.Username))
+}
+
+func (s folderService) getChildren(ctx context.Context, foldersQuery, filesQuery string, arg sql.NamedArg) ([]models.Folder, []models.File, error) {
+	runner := getRunner(ctx, s.db)
+
+	rows, err := runner.QueryContext(ctx, foldersQuery, arg)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	folders, err := scanFolders(rows)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	rows, err = runner.QueryContext(ctx, filesQuery, arg)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	files, err := scanFiles(rows)
+	if err != nil {
+		return nil, nil, database.Internal(err, "failed to get folder's children from the database")
+	}
+
+	return folders, files, nil
+}
+
+type treeEntry struct {
+	Id       string
+	Name     string
+	ParentId string
+}
+
+type fileTreeEntry struct {
+	models.File
+	ParentId string
+}
+
+func scanTreeEntry(row scanner) (treeEntry, error) {
+	var entry treeEntry
+	err := row.Scan(&entry.Id, &entry.Name, &entry.ParentId)
+	return entry, err
+}
+
+func scanFileTreeEntry(row scanner) (fileTreeEntry, error) {
+	var (
+		entry                fileTreeEntry
+		createdAt, updatedAt int64
+	)
+	if err := row.Scan(&entry.Id, &entry.Name, &createdAt, &updatedAt, &entry.LastEditedBy, &entry.ParentId); err != nil {
+		return fileTreeEntry{}, err
+	}
+	entry.CreatedAt, entry.UpdatedAt = fromTimestamp(createdAt), fromTimestamp(updatedAt)
+	return entry, nil
+}
+
+func (s folderService) GetTree(ctx context.Context, owner models.User) (models.FolderTree, error) {
+	arg := sql.Named("username", owner.Username)
+
+	folders, err := getTreeEntries(ctx, s.db, s.getFoldersForTreeQuery, arg, scanTreeEntry)
+	if err != nil {
+		return models.FolderTree{}, err
+	}
+
+	files, err := getTreeEntries(ctx, s.db, s.getFilesForTreeQuery, arg, scanFileTreeEntry)
+	if err != nil {
+		return models.FolderTree{}, err
+	}
+
+	childFolders := make(map[string][]treeEntry)
+	for _, folder := range folders {
+		childFolders[folder.ParentId] = append(childFolders[folder.ParentId], folder)
+	}
+
+	childFiles := make(map[string][]models.File)
+	for _, file := range files {
+		childFiles[file.ParentId] = append(childFiles[file.ParentId], file.File)
+	}
+
+	var build func(folder *models.Folder) models.FolderTree
+	build = func(folder *models.Folder) models.FolderTree {
+		id := ""
+		if folder != nil {
+			id = folder.Id
+		}
+
+		tree := models.FolderTree{Folder: folder, Folders: []models.FolderTree{}, Files: childFiles[id]}
+		if tree.Files == nil {
+			tree.Files = []models.File{}
+		}
+		for _, child := range childFolders[id] {
+			tree.Folders = append(tree.Folders, build(&models.Folder{Id: child.Id, Name: child.Name}))
+		}
+		return tree
+	}
+
+	return build(nil), nil
+}
+
+func getTreeEntries[T any](ctx context.Context, db *sql.DB, query string, arg sql.NamedArg, scan func(row scanner) (T, error)) ([]T, error) {
+	rows, err := getRunner(ctx, db).QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the folder tree from the database")
+	}
+	defer rows.Close()
+
+	entries := []T{}
+	for rows.Next() {
+		entry, err := scan(rows)
+		if err != nil {
+			return nil, database.Internal(err, "failed to get the folder tree from the database")
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.Internal(err, "failed to get the folder tree from the database")
+	}
+
+	return entries, nil
+}
+
+func (s folderService) UpdateName(ctx context.Context, folder models.Folder, name string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateNameQuery,
+		sql.Named("id", folder.Id),
+		sql.Named("new_name", name),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update folder's name")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("folder wasn't found")
+	}
+
+	return nil
+}
+
+// NOTE: Folder is moved to the root, if parent is nil
+func (s folderService) Move(ctx context.Context, folder models.Folder, parent *models.Folder) error {
+	args := []any{sql.Named("id", folder.Id)}
+
+	query := s.moveToRootQuery
+	if parent != nil {
+		query = s.moveQuery
+		args = append(args, sql.Named("parent_id", parent.Id))
+	}
+
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return database.Internal(err, "failed to move the folder")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.Conflict("folder can't be moved into itself or its subfolder")
+	}
+
+	return nil
+}
+
+// NOTE: File is moved to the root, if parent is nil
+func (s folderService) MoveFile(ctx context.Context, file models.File, parent *models.Folder) error {
+	args := []any{sql.Named("id", file.Id)}
+
+	query := s.moveFileToRootQuery
+	if parent != nil {
+		query = s.moveFileQuery
+		args = append(args, sql.Named("parent_id", parent.Id))
+	}
+
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return database.Internal(err, "failed to move the file")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("file wasn't found")
+	}
+
+	return nil
+}
+
+// NOTE: Everything the folder contains is deleted as well
+func (s folderService) Delete(ctx context.Context, folder models.Folder) error {
+	arg := sql.Named("id", folder.Id)
+
+	err := transactor{db: s.db}.InTransaction(ctx, func(ctx context.Context) error {
+		runner := getRunner(ctx, s.db)
+		if _, err := runner.ExecContext(ctx, s.deleteFilesQuery, arg); err != nil {
+			return err
+		}
+		_, err := runner.ExecContext(ctx, s.deleteFoldersQuery, arg)
+		return err
+	})
+	if err != nil {
+		return database.Internal(err, "failed to delete the folder")
+	}
+
+	return nil
+}
+
+func scanFolders(rows *sql.Rows) ([]models.Folder, error) {
+	defer rows.Close()
+
+	folders := []models.Folder{}
+	for rows.Next() {
+		var folder models.Folder
+		if err := rows.Scan(&folder.Id, &folder.Name); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
@@ -0,0 +1,195 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+// NOTE: Migrations are applied in order and must never be changed once released,
+// schema changes go to a new migration appended to the end
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create users and sessions",
+		up: `
+			CREATE TABLE users (
+				username TEXT PRIMARY KEY,
+				password TEXT NOT NULL
+			);
+			CREATE TABLE sessions (
+				id         TEXT PRIMARY KEY,
+				username   TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				created_at INTEGER NOT NULL,
+				expires_at INTEGER NOT NULL
+			);
+			CREATE INDEX sessions_username ON sessions (username);`,
+	},
+	{
+		version: 2,
+		name:    "create folders, files and revisions",
+		up: `
+			CREATE TABLE folders (
+				id        TEXT PRIMARY KEY,
+				name      TEXT NOT NULL,
+				owner     TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				parent_id TEXT REFERENCES folders (id) ON DELETE SET NULL
+			);
+			CREATE INDEX folders_owner ON folders (owner);
+			CREATE INDEX folders_parent_id ON folders (parent_id);
+			CREATE TABLE files (
+				id            TEXT PRIMARY KEY,
+				name          TEXT NOT NULL,
+				owner         TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				parent_id     TEXT REFERENCES folders (id) ON DELETE SET NULL,
+				content       TEXT NOT NULL DEFAULT '',
+				content_state TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX files_owner ON files (owner);
+			CREATE INDEX files_parent_id ON files (parent_id);
+			CREATE TABLE revisions (
+				id         TEXT PRIMARY KEY,
+				file_id    TEXT NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+				author     TEXT NOT NULL,
+				created_at INTEGER NOT NULL,
+				content    TEXT NOT NULL
+			);
+			CREATE INDEX revisions_file_id ON revisions (file_id, created_at);`,
+	},
+	{
+		version: 3,
+		name:    "create accesses",
+		up: `
+			CREATE TABLE file_accesses (
+				resource_id TEXT NOT NULL REFERENCES files (id) ON DELETE CASCADE,
+				receiver    TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				granter     TEXT NOT NULL,
+				level       TEXT NOT NULL,
+				granted_at  INTEGER NOT NULL
+			);
+			CREATE INDEX file_accesses_resource_id ON file_accesses (resource_id);
+			CREATE INDEX file_accesses_receiver ON file_accesses (receiver);
+			CREATE TABLE folder_accesses (
+				resource_id TEXT NOT NULL REFERENCES folders (id) ON DELETE CASCADE,
+				receiver    TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				granter     TEXT NOT NULL,
+				level       TEXT NOT NULL,
+				granted_at  INTEGER NOT NULL
+			);
+			CREATE INDEX folder_accesses_resource_id ON folder_accesses (resource_id);
+			CREATE INDEX folder_accesses_receiver ON folder_accesses (receiver);`,
+	},
+	{
+		version: 4,
+		name:    "add file timestamps",
+		// NOTE: Existing files are treated as created and edited by their owners now
+		up: `
+			ALTER TABLE files ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE files ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE files ADD COLUMN last_edited_by TEXT NOT NULL DEFAULT '';
+			UPDATE files SET created_at = unixepoch() * 1000000000, updated_at = unixepoch() * 1000000000, last_edited_by = owner;
+			CREATE INDEX files_owner_created_at ON files (owner, created_at);
+			CREATE INDEX files_owner_updated_at ON files (owner, updated_at);`,
+	},
+	{
+		version: 5,
+		name:    "create tokens",
+		up: `
+			CREATE TABLE tokens (
+				id         TEXT PRIMARY KEY,
+				username   TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				name       TEXT NOT NULL,
+				scopes     TEXT NOT NULL,
+				hash       TEXT NOT NULL UNIQUE,
+				created_at INTEGER NOT NULL,
+				expires_at INTEGER NOT NULL
+			);
+			CREATE INDEX tokens_username ON tokens (username);`,
+	},
+	{
+		version: 6,
+		name:    "add session devices",
+		up: `
+			ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN last_seen_at INTEGER NOT NULL DEFAULT 0;
+			UPDATE sessions SET last_seen_at = created_at;`,
+	},
+	{
+		version: 7,
+		name:    "create session expiry index",
+		up:      `CREATE INDEX sessions_expires_at ON sessions (expires_at);`,
+	},
+	{
+		version: 8,
+		name:    "add two-factor authentication",
+		up: `
+			ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+			ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE users ADD COLUMN recovery_codes TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 9,
+		name:    "create password resets",
+		// NOTE: Empty email means the user has none, any number of users can have none
+		up: `
+			ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT '';
+			CREATE UNIQUE INDEX users_email ON users (email) WHERE email <> '';
+			CREATE TABLE password_resets (
+				hash       TEXT PRIMARY KEY,
+				username   TEXT NOT NULL REFERENCES users (username) ON DELETE CASCADE ON UPDATE CASCADE,
+				created_at INTEGER NOT NULL,
+				expires_at INTEGER NOT NULL
+			);
+			CREATE INDEX password_resets_username ON password_resets (username);`,
+	},
+	{
+		version: 10,
+		name:    "add last used TOTP step",
+		up:      `ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;`,
+	},
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create the migrations table: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT coalesce(max(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to get the schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err := transactor{db: db}.InTransaction(ctx, func(ctx context.Context) error {
+			tx := getRunner(ctx, db)
+			if _, err := tx.ExecContext(ctx, m.up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($version, $name, $applied_at)`,
+				sql.Named("version", m.version), sql.Named("name", m.name), sql.Named("applied_at", toTimestamp(time.Now())))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
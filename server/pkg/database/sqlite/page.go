@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+)
+
+// scanner is either *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// listing is a paginated query with a variant for every sort key
+type listing[T any] struct {
+	queries map[string]string
+	sorts   map[string]database.Sort[T]
+	scan    func(row scanner) (T, error)
+	failure string
+}
+
+// NOTE: Template is filled by database.KeysetQueries
+func newListing[T any](template string, keysets map[string]database.Keyset, sorts map[string]database.Sort[T], scan func(row scanner) (T, error), failure string) listing[T] {
+	return listing[T]{queries: database.KeysetQueries(template, keysets), sorts: sorts, scan: scan, failure: failure}
+}
+
+// get runs the query of the requested sort and scans one item over the limit,
+// which tells whether there is a next page
+func (l listing[T]) get(ctx context.Context, db *sql.DB, page database.PageRequest, args ...any) (database.Page[T], error) {
+	sortBy, sort, cursor, err := database.ResolvePage(page, l.sorts)
+	if err != nil {
+		return database.Page[T]{}, err
+	}
+
+	after := []any{sql.Named("after_key", nil), sql.Named("after_time", nil), sql.Named("after_id", nil)}
+	if cursor != nil {
+		after = []any{sql.Named("after_key", cursor.Key), sql.Named("after_time", toTimestamp(cursor.Time)), sql.Named("after_id", cursor.Id)}
+	}
+
+	limit := page.PageLimit()
+	args = append(args, after...)
+	args = append(args, sql.Named("limit", limit+1))
+
+	rows, err := getRunner(ctx, db).QueryContext(ctx, l.queries[sortBy], args...)
+	if err != nil {
+		return database.Page[T]{}, database.Internal(err, l.failure)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := l.scan(rows)
+		if err != nil {
+			return database.Page[T]{}, database.Internal(err, l.failure)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return database.Page[T]{}, database.Internal(err, l.failure)
+	}
+
+	return database.NewPage(items, limit, sortBy, sort), nil
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type resetService struct {
+	db    *sql.DB
+	users *userService
+
+	deleteForUserQuery string
+	createQuery        string
+
+	useQuery string
+}
+
+func NewResetService(db *sql.DB) *resetService {
+	return &resetService{
+		db:    db,
+		users: NewUserService(db),
+
+		deleteForUserQuery: `DELETE FROM password_resets WHERE username = $username`,
+		createQuery: `INSERT INTO password_resets (hash, username, created_at, expires_at)
+			SELECT $hash, username, $created_at, $expires_at FROM users WHERE username = $username`,
+
+		// NOTE: Expired resets are deleted as well, they can't be used anyway
+		useQuery: `DELETE FROM password_resets WHERE hash = $hash RETURNING username, expires_at`,
+	}
+}
+
+func (s resetService) Create(ctx context.Context, reset models.PasswordReset) error {
+	return transactor{db: s.db}.InTransaction(ctx, func(ctx context.Context) error {
+		runner := getRunner(ctx, s.db)
+
+		if _, err := runner.ExecContext(ctx, s.deleteForUserQuery, sql.Named("username", reset.Username)); err != nil {
+			return database.Internal(err, "failed to create a password reset")
+		}
+
+		result, err := runner.ExecContext(ctx, s.createQuery,
+			sql.Named("hash", reset.Hash),
+			sql.Named("username", reset.Username),
+			sql.Named("created_at", toTimestamp(reset.CreatedAt)),
+			sql.Named("expires_at", toTimestamp(reset.ExpiresAt)),
+		)
+		if err != nil {
+			return database.Internal(err, "failed to create a password reset")
+		}
+
+		if rowsAffected(result) <= 0 {
+			return database.NotFound("user wasn't found")
+		}
+
+		return nil
+	})
+}
+
+func (s resetService) Use(ctx context.Context, hash string) (models.User, error) {
+	var username string
+	var expiresAt int64
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.useQuery, sql.Named("hash", hash)).Scan(&username, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, database.NotFound("password reset wasn't found")
+		}
+		return models.User{}, database.Internal(err, "failed to use the password reset")
+	}
+
+	if !fromTimestamp(expiresAt).After(time.Now()) {
+		return models.User{}, database.NotFound("password reset wasn't found")
+	}
+
+	return s.users.GetByUsername(ctx, username)
+}
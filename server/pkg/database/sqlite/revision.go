@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type revisionService struct {
+	db *sql.DB
+
+	createQuery string
+
+	getByIdQuery   string
+	getLatestQuery string
+	getAllQuery    string
+}
+
+func NewRevisionService(db *sql.DB) *revisionService {
+	return &revisionService{
+		db: db,
+
+		createQuery: `INSERT INTO revisions (id, file_id, author, created_at, content) SELECT $revision_id, id, $author, $created_at, $content FROM files WHERE id = $id`,
+
+		getByIdQuery:   `SELECT id, author, created_at, content FROM revisions WHERE file_id = $id AND id = $revision_id`,
+		getLatestQuery: `SELECT id, author, created_at, content FROM revisions WHERE file_id = $id ORDER BY created_at DESC, rowid DESC LIMIT 1`,
+		getAllQuery:    `SELECT id, author, created_at, '' FROM revisions WHERE file_id = $id ORDER BY created_at DESC, rowid DESC`,
+	}
+}
+
+func (s revisionService) Create(ctx context.Context, file models.File, revision models.Revision) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, s.createQuery,
+		sql.Named("id", file.Id),
+		sql.Named("revision_id", revision.Id),
+		sql.Named("author", revision.Author),
+		sql.Named("created_at", toTimestamp(revision.CreatedAt)),
+		sql.Named("content", revision.Content),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to create a revision")
+	}
+
+	return nil
+}
+
+func (s revisionService) GetById(ctx context.Context, file models.File, id uuid.UUID) (models.Revision, error) {
+	row := getRunner(ctx, s.db).QueryRowContext(ctx, s.getByIdQuery,
+		sql.Named("id", file.Id),
+		sql.Named("revision_id", id.String()),
+	)
+
+	revision, err := scanRevision(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Revision{}, database.NotFound("revision wasn't found")
+		}
+		return models.Revision{}, database.Internal(err, "failed to get the revision from the database")
+	}
+
+	return revision, nil
+}
+
+// NOTE: Latest revision is returned with content
+func (s revisionService) GetLatest(ctx context.Context, file models.File) (models.Revision, error) {
+	row := getRunner(ctx, s.db).QueryRowContext(ctx, s.getLatestQuery, sql.Named("id", file.Id))
+
+	revision, err := scanRevision(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Revision{}, database.NotFound("revision wasn't found")
+		}
+		return models.Revision{}, database.Internal(err, "failed to get the latest revision from the database")
+	}
+
+	return revision, nil
+}
+
+// NOTE: Revisions are returned without content, newest first
+func (s revisionService) GetAll(ctx context.Context, file models.File) ([]models.Revision, error) {
+	rows, err := getRunner(ctx, s.db).QueryContext(ctx, s.getAllQuery, sql.Named("id", file.Id))
+	if err != nil {
+		return nil, database.Internal(err, "failed to get revisions from the database")
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, database.Internal(err, "failed to get revisions from the database")
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.Internal(err, "failed to get revisions from the database")
+	}
+
+	return revisions, nil
+}
+
+func scanRevision(row interface{ Scan(dest ...any) error }) (models.Revision, error) {
+	var (
+		revision  models.Revision
+		createdAt int64
+	)
+	if err := row.Scan(&revision.Id, &revision.Author, &createdAt, &revision.Content); err != nil {
+		return models.Revision{}, err
+	}
+	revision.CreatedAt = fromTimestamp(createdAt)
+	return revision, nil
+}
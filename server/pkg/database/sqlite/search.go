@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type searchService struct {
+	db *sql.DB
+
+	getAccessibleQuery string
+}
+
+// NOTE: There is no full-text index in this backend, files the user can access
+// are scored one by one, which is good enough for a single instance
+func NewSearchService(db *sql.DB) *searchService {
+	return &searchService{
+		db: db,
+
+		getAccessibleQuery: `WITH RECURSIVE
+			granted_folders (id) AS (
+				SELECT resource_id FROM folder_accesses WHERE receiver = $username
+				UNION
+				SELECT d.id FROM folders d JOIN granted_folders g ON d.parent_id = g.id
+			)
+			SELECT id, name, created_at, updated_at, last_edited_by, owner, content FROM files
+			WHERE owner = $username
+			OR id IN (SELECT resource_id FROM file_accesses WHERE receiver = $username)
+			OR parent_id IN granted_folders`,
+	}
+}
+
+func (s searchService) Search(ctx context.Context, user models.User, query string, limit int) ([]models.SearchResult, error) {
+	terms := database.SearchTerms(query)
+	if len(terms) == 0 {
+		return []models.SearchResult{}, nil
+	}
+
+	rows, err := getRunner(ctx, s.db).QueryContext(ctx, s.getAccessibleQuery, sql.Named("username", user.Username))
+	if err != nil {
+		return nil, database.Internal(err, "failed to search the files")
+	}
+	defer rows.Close()
+
+	results := []models.SearchResult{}
+	for rows.Next() {
+		var (
+			file                 models.File
+			createdAt, updatedAt int64
+			owner, content       string
+		)
+		if err := rows.Scan(&file.Id, &file.Name, &createdAt, &updatedAt, &file.LastEditedBy, &owner, &content); err != nil {
+			return nil, database.Internal(err, "failed to search the files")
+		}
+		file.CreatedAt, file.UpdatedAt = fromTimestamp(createdAt), fromTimestamp(updatedAt)
+
+		score := database.Score(file.Name, content, terms)
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, models.SearchResult{
+			File:     file,
+			Owner:    owner,
+			Score:    score,
+			Snippets: database.Snippets(file.Name, content, terms),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.Internal(err, "failed to search the files")
+	}
+
+	return database.SortSearchResults(results, database.SearchLimit(limit)), nil
+}
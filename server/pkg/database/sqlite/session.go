@@ -0,0 +1,184 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type sessionService struct {
+	db *sql.DB
+
+	createQuery string
+
+	checkQuery   string
+	refreshQuery string
+	getAllQuery  string
+
+	deleteQuery          string
+	deleteAllExceptQuery string
+	deleteAllQuery       string
+	deleteExpiredQuery   string
+}
+
+func NewSessionService(db *sql.DB) *sessionService {
+	return &sessionService{
+		db: db,
+
+		createQuery: `INSERT INTO sessions (id, username, user_agent, ip, created_at, last_seen_at, expires_at) SELECT $id, username, $user_agent, $ip, $created_at, $last_seen_at, $expires_at FROM users WHERE username = $username`,
+
+		checkQuery:   `SELECT u.username, u.password, s.id, s.username, s.user_agent, s.ip, s.created_at, s.last_seen_at, s.expires_at FROM sessions s JOIN users u ON u.username = s.username WHERE s.id = $id AND s.expires_at > $now`,
+		refreshQuery: `UPDATE sessions SET last_seen_at = $last_seen_at, expires_at = $expires_at WHERE id = $id AND expires_at > $now`,
+		getAllQuery:  `SELECT id, username, user_agent, ip, created_at, last_seen_at, expires_at FROM sessions WHERE username = $username AND expires_at > $now ORDER BY last_seen_at DESC`,
+
+		deleteQuery:          `DELETE FROM sessions WHERE id = $id AND username = $username`,
+		deleteAllExceptQuery: `DELETE FROM sessions WHERE username = $username AND id <> $id`,
+		deleteAllQuery:       `DELETE FROM sessions WHERE username = $username`,
+		deleteExpiredQuery:   `DELETE FROM sessions WHERE expires_at <= $now`,
+	}
+}
+
+func (s sessionService) Create(ctx context.Context, session models.Session) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, s.createQuery,
+		sql.Named("id", session.Id),
+		sql.Named("username", session.Username),
+		sql.Named("user_agent", session.UserAgent),
+		sql.Named("ip", session.IP),
+		sql.Named("created_at", toTimestamp(session.CreatedAt)),
+		sql.Named("last_seen_at", toTimestamp(session.LastSeenAt)),
+		sql.Named("expires_at", toTimestamp(session.ExpiresAt)),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to create a session")
+	}
+
+	return nil
+}
+
+func (s sessionService) Check(ctx context.Context, id uuid.UUID) (models.User, models.Session, error) {
+	row := getRunner(ctx, s.db).QueryRowContext(ctx, s.checkQuery,
+		sql.Named("id", id.String()),
+		sql.Named("now", toTimestamp(time.Now())),
+	)
+
+	var user models.User
+	var session models.Session
+	var createdAt, lastSeenAt, expiresAt int64
+	err := row.Scan(&user.Username, &user.Password, &session.Id, &session.Username, &session.UserAgent, &session.IP, &createdAt, &lastSeenAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, models.Session{}, database.NotFound("user wasn't found")
+		}
+		return models.User{}, models.Session{}, database.Internal(err, "failed to check the session")
+	}
+	session.CreatedAt = fromTimestamp(createdAt)
+	session.LastSeenAt = fromTimestamp(lastSeenAt)
+	session.ExpiresAt = fromTimestamp(expiresAt)
+
+	return user, session, nil
+}
+
+func (s sessionService) Refresh(ctx context.Context, session models.Session) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.refreshQuery,
+		sql.Named("id", session.Id),
+		sql.Named("last_seen_at", toTimestamp(session.LastSeenAt)),
+		sql.Named("expires_at", toTimestamp(session.ExpiresAt)),
+		sql.Named("now", toTimestamp(time.Now())),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to refresh the session")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("session wasn't found")
+	}
+
+	return nil
+}
+
+func (s sessionService) GetAll(ctx context.Context, user models.User) ([]models.Session, error) {
+	rows, err := getRunner(ctx, s.db).QueryContext(ctx, s.getAllQuery,
+		sql.Named("username", user.Username),
+		sql.Named("now", toTimestamp(time.Now())),
+	)
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the sessions from the database")
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, database.Internal(err, "failed to get the sessions from the database")
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, database.Internal(err, "failed to get the sessions from the database")
+	}
+
+	return sessions, nil
+}
+
+func scanSession(row scanner) (models.Session, error) {
+	var session models.Session
+	var createdAt, lastSeenAt, expiresAt int64
+	if err := row.Scan(&session.Id, &session.Username, &session.UserAgent, &session.IP, &createdAt, &lastSeenAt, &expiresAt); err != nil {
+		return models.Session{}, err
+	}
+	session.CreatedAt = fromTimestamp(createdAt)
+	session.LastSeenAt = fromTimestamp(lastSeenAt)
+	session.ExpiresAt = fromTimestamp(expiresAt)
+	return session, nil
+}
+
+func (s sessionService) Delete(ctx context.Context, user models.User, id uuid.UUID) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteQuery,
+		sql.Named("id", id.String()),
+		sql.Named("username", user.Username),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to delete the session")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("session wasn't found")
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteAllExcept(ctx context.Context, user models.User, id uuid.UUID) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteAllExceptQuery,
+		sql.Named("id", id.String()),
+		sql.Named("username", user.Username),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to delete the other sessions")
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteAll(ctx context.Context, user models.User) error {
+	if _, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteAllQuery, sql.Named("username", user.Username)); err != nil {
+		return database.Internal(err, "failed to delete all sessions")
+	}
+
+	return nil
+}
+
+func (s sessionService) DeleteExpired(ctx context.Context) (int, error) {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteExpiredQuery, sql.Named("now", toTimestamp(time.Now())))
+	if err != nil {
+		return 0, database.Internal(err, "failed to delete the expired sessions")
+	}
+
+	return int(rowsAffected(result)), nil
+}
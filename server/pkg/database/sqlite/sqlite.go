@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Open opens (or creates) the database at the path and applies pending migrations
+func Open(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: SQLite allows a single writer at a time, transactions
+	// are serialized instead of failing with "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func Repositories(db *sql.DB) database.Repositories {
+	return database.Repositories{
+		Users:      NewUserService(db),
+		Files:      NewFileService(db),
+		Folders:    NewFolderService(db),
+		Revisions:  NewRevisionService(db),
+		Access:     NewAccessService(db),
+		Sessions:   NewSessionService(db),
+		Tokens:     NewTokenService(db),
+		Resets:     NewResetService(db),
+		Search:     NewSearchService(db),
+		Transactor: transactor{db: db},
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	code := sqliteErr.Code()
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// NOTE: Time is stored as the number of nanoseconds since the epoch,
+// so it's compared and sorted the same way regardless of the time zone
+func toTimestamp(t time.Time) int64 {
+	return t.UnixNano()
+}
+
+func fromTimestamp(ts int64) time.Time {
+	return time.Unix(0, ts).In(time.UTC)
+}
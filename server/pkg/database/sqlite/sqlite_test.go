@@ -0,0 +1,30 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/databasetest"
+	"github.com/SergeyCherepiuk/docs/pkg/database/sqlite"
+)
+
+func TestConformance(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "docs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	databasetest.Run(t, sqlite.Repositories(db))
+}
+
+func TestMigrationsAreAppliedOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.db")
+	for i := 0; i < 2; i++ {
+		db, err := sqlite.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open the database for the %d time: %v", i+1, err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+type tokenService struct {
+	db *sql.DB
+
+	createQuery string
+
+	checkQuery  string
+	getAllQuery string
+
+	revokeQuery string
+}
+
+// NOTE: Scopes are stored as a space-separated list
+func NewTokenService(db *sql.DB) *tokenService {
+	return &tokenService{
+		db: db,
+
+		createQuery: `INSERT INTO tokens (id, username, name, scopes, hash, created_at, expires_at)
+			SELECT $id, username, $name, $scopes, $hash, $created_at, $expires_at FROM users WHERE username = $username`,
+
+		checkQuery: `SELECT u.username, u.password, t.id, t.username, t.name, t.scopes, t.hash, t.created_at, t.expires_at
+			FROM tokens t JOIN users u ON u.username = t.username WHERE t.hash = $hash AND t.expires_at > $now`,
+		getAllQuery: `SELECT id, username, name, scopes, hash, created_at, expires_at FROM tokens WHERE username = $username ORDER BY created_at DESC`,
+
+		revokeQuery: `DELETE FROM tokens WHERE id = $id AND username = $username`,
+	}
+}
+
+func (s tokenService) Create(ctx context.Context, token models.Token) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.createQuery,
+		sql.Named("id", token.Id),
+		sql.Named("username", token.Username),
+		sql.Named("name", token.Name),
+		sql.Named("scopes", strings.Join(token.Scopes, " ")),
+		sql.Named("hash", token.Hash),
+		sql.Named("created_at", toTimestamp(token.CreatedAt)),
+		sql.Named("expires_at", toTimestamp(token.ExpiresAt)),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return database.Conflict("token with this id already exists")
+		}
+		return database.Internal(err, "failed to create a token")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s tokenService) Check(ctx context.Context, hash string) (models.User, models.Token, error) {
+	var (
+		user                 models.User
+		token                models.Token
+		scopes               string
+		createdAt, expiresAt int64
+	)
+	err := getRunner(ctx, s.db).QueryRowContext(ctx, s.checkQuery,
+		sql.Named("hash", hash),
+		sql.Named("now", toTimestamp(time.Now())),
+	).Scan(&user.Username, &user.Password, &token.Id, &token.Username, &token.Name, &scopes, &token.Hash, &createdAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, models.Token{}, database.NotFound("token wasn't found")
+		}
+		return models.User{}, models.Token{}, database.Internal(err, "failed to check the token")
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	token.CreatedAt, token.ExpiresAt = fromTimestamp(createdAt), fromTimestamp(expiresAt)
+	return user, token, nil
+}
+
+func (s tokenService) GetAll(ctx context.Context, user models.User) ([]models.Token, error) {
+	rows, err := getRunner(ctx, s.db).QueryContext(ctx, s.getAllQuery, sql.Named("username", user.Username))
+	if err != nil {
+		return nil, database.Internal(err, "failed to get the tokens from the database")
+	}
+	defer rows.Close()
+
+	tokens := []models.Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, database.Internal(err, "failed to get the tokens from the database")
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, database.Internal(err, "failed to get the tokens from the database")
+	}
+
+	return tokens, nil
+}
+
+func scanToken(row scanner) (models.Token, error) {
+	var (
+		token                models.Token
+		scopes               string
+		createdAt, expiresAt int64
+	)
+	if err := row.Scan(&token.Id, &token.Username, &token.Name, &scopes, &token.Hash, &createdAt, &expiresAt); err != nil {
+		return models.Token{}, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	token.CreatedAt, token.ExpiresAt = fromTimestamp(createdAt), fromTimestamp(expiresAt)
+	return token, nil
+}
+
+func (s tokenService) Revoke(ctx context.Context, user models.User, id uuid.UUID) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.revokeQuery,
+		sql.Named("id", id.String()),
+		sql.Named("username", user.Username),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to revoke the token")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("token wasn't found")
+	}
+
+	return nil
+}
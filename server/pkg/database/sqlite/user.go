@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+type userService struct {
+	db *sql.DB
+
+	createQuery string
+
+	getByUsernameQuery string
+	getByEmailQuery    string
+
+	updateUsernameQuery string
+	updatePasswordQuery string
+	updateEmailQuery    string
+
+	updateTwoFactorQuery string
+	useTOTPStepQuery     string
+	useRecoveryCodeQuery string
+
+	deleteQuery string
+}
+
+func NewUserService(db *sql.DB) *userService {
+	return &userService{
+		db: db,
+
+		createQuery: `INSERT INTO users (username, password, email) VALUES ($username, $password, $email)`,
+
+		getByUsernameQuery: `SELECT username, password, email, totp_secret, totp_enabled, totp_last_step, recovery_codes FROM users WHERE username = $username`,
+		getByEmailQuery:    `SELECT username, password, email, totp_secret, totp_enabled, totp_last_step, recovery_codes FROM users WHERE email = $email AND email <> ''`,
+
+		// NOTE: Owned files, folders, sessions and received accesses follow the username (ON UPDATE CASCADE)
+		updateUsernameQuery: `UPDATE users SET username = $new_username WHERE username = $username`,
+		updatePasswordQuery: `UPDATE users SET password = $new_password WHERE username = $username`,
+		updateEmailQuery:    `UPDATE users SET email = $new_email WHERE username = $username`,
+
+		// NOTE: Recovery codes are space separated, the padding makes every code surrounded by spaces
+		updateTwoFactorQuery: `UPDATE users SET totp_secret = $secret, totp_enabled = $enabled, recovery_codes = $recovery_codes WHERE username = $username`,
+		useTOTPStepQuery:     `UPDATE users SET totp_last_step = $step WHERE username = $username AND totp_last_step < $step`,
+		useRecoveryCodeQuery: `UPDATE users SET recovery_codes = trim(replace(' ' || recovery_codes || ' ', ' ' || $hash || ' ', ' '))
+			WHERE username = $username AND instr(' ' || recovery_codes || ' ', ' ' || $hash || ' ') > 0`,
+
+		// NOTE: Owned files, folders, their revisions and accesses are deleted by the foreign keys
+		deleteQuery: `DELETE FROM users WHERE username = $username`,
+	}
+}
+
+func (s userService) Create(ctx context.Context, user models.User) error {
+	_, err := getRunner(ctx, s.db).ExecContext(ctx, s.createQuery,
+		sql.Named("username", user.Username),
+		sql.Named("password", user.Password),
+		sql.Named("email", user.Email),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			if strings.Contains(err.Error(), "users.email") {
+				return database.Conflict("email already taken")
+			}
+			return database.Conflict("username already taken")
+		} else {
+			return database.Internal(err, "failed to store user in the database")
+		}
+	}
+	return nil
+}
+
+func (s userService) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	user, err := scanUser(getRunner(ctx, s.db).QueryRowContext(ctx, s.getByUsernameQuery, sql.Named("username", username)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, database.NotFound("user wasn't found")
+		}
+		return models.User{}, database.Internal(err, "failed to get the user from the database")
+	}
+
+	return user, nil
+}
+
+func (s userService) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	user, err := scanUser(getRunner(ctx, s.db).QueryRowContext(ctx, s.getByEmailQuery, sql.Named("email", email)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, database.NotFound("user wasn't found")
+		}
+		return models.User{}, database.Internal(err, "failed to get the user from the database")
+	}
+
+	return user, nil
+}
+
+func scanUser(row scanner) (models.User, error) {
+	var user models.User
+	var recoveryCodes string
+	if err := row.Scan(&user.Username, &user.Password, &user.Email, &user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep, &recoveryCodes); err != nil {
+		return models.User{}, err
+	}
+	user.RecoveryCodes = strings.Fields(recoveryCodes)
+	return user, nil
+}
+
+func (s userService) UpdateUsername(ctx context.Context, user models.User, newUsername string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateUsernameQuery,
+		sql.Named("username", user.Username),
+		sql.Named("new_username", newUsername),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return database.Conflict("username already taken")
+		} else {
+			return database.Internal(err, "failed to update user's username")
+		}
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UpdatePassword(ctx context.Context, user models.User, newPassword string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updatePasswordQuery,
+		sql.Named("username", user.Username),
+		sql.Named("new_password", newPassword),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update user's password")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UpdateEmail(ctx context.Context, user models.User, newEmail string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateEmailQuery,
+		sql.Named("username", user.Username),
+		sql.Named("new_email", newEmail),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return database.Conflict("email already taken")
+		}
+		return database.Internal(err, "failed to update user's email")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UpdateTwoFactor(ctx context.Context, user models.User, secret string, enabled bool, recoveryCodes []string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.updateTwoFactorQuery,
+		sql.Named("username", user.Username),
+		sql.Named("secret", secret),
+		sql.Named("enabled", enabled),
+		sql.Named("recovery_codes", strings.Join(recoveryCodes, " ")),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to update user's two-factor authentication")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("user wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) UseTOTPStep(ctx context.Context, user models.User, step int64) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.useTOTPStepQuery,
+		sql.Named("username", user.Username),
+		sql.Named("step", step),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to use the code")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("code was already used")
+	}
+
+	return nil
+}
+
+func (s userService) UseRecoveryCode(ctx context.Context, user models.User, hash string) error {
+	result, err := getRunner(ctx, s.db).ExecContext(ctx, s.useRecoveryCodeQuery,
+		sql.Named("username", user.Username),
+		sql.Named("hash", hash),
+	)
+	if err != nil {
+		return database.Internal(err, "failed to use the recovery code")
+	}
+
+	if rowsAffected(result) <= 0 {
+		return database.NotFound("recovery code wasn't found")
+	}
+
+	return nil
+}
+
+func (s userService) Delete(ctx context.Context, user models.User) error {
+	if _, err := getRunner(ctx, s.db).ExecContext(ctx, s.deleteQuery, sql.Named("username", user.Username)); err != nil {
+		return database.Internal(err, "failed to delete the user")
+	}
+	return nil
+}
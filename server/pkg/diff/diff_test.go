@@ -0,0 +1,135 @@
+package diff_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/SergeyCherepiuk/docs/pkg/diff"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", " a, b"},
+		{"insert", "a\nc", "a\nb\nc", " a,+b, c"},
+		{"delete", "a\nb\nc", "a\nc", " a,-b, c"},
+		{"replace", "a\nb\nc", "a\nx\nc", " a,-b,+x, c"},
+		{"from empty", "", "a\nb", "+a,+b"},
+		{"to empty", "a\nb", "", "-a,-b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := format(diff.Lines(test.a, test.b, 0)); actual != test.expected {
+				t.Errorf(`expected: "%s", actual: "%s"`, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"ascii", "the quick brown fox", "the slow brown dog", "the [-quick-]{+slow+} brown [-fox-]{+dog+}"},
+		{"multibyte before space", "voilà tout", "voila tout", "[-voilà-]{+voila+} tout"},
+		{"multibyte inside word", "ąb", "ąc", "[-ąb-]{+ąc+}"},
+		{"non-breaking space", "jeden\u00a0dwa", "jeden\u00a0trzy", "jeden\u00a0[-dwa-]{+trzy+}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := diff.Inline(diff.Words(test.a, test.b, 0)); actual != test.expected {
+				t.Errorf(`expected: "%s", actual: "%s"`, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n"
+
+	expected := strings.Join([]string{
+		"--- a",
+		"+++ b",
+		"@@ -2,3 +2,3 @@",
+		" 2",
+		"-3",
+		"+three",
+		" 4",
+		"@@ -10 +10,2 @@",
+		" 10",
+		"+11",
+		"",
+	}, "\n")
+
+	actual := diff.Unified("a", "b", diff.Hunks(diff.Lines(a, b, 0), 1))
+	if actual != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestLinesLimit(t *testing.T) {
+	a, b := "a\nb\nc\nd", "a\nx\nc\ny"
+
+	if actual, expected := format(diff.Lines(a, b, 4)), " a,-b,+x, c,-d,+y"; actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+	if actual, expected := format(diff.Lines(a, b, 3)), "-a,-b,-c,-d,+a,+x,+c,+y"; actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+}
+
+// NOTE: Equal and deleted lines give back the old text, equal and inserted give back the new one
+func TestLinesReconstructBothTexts(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		a, b := randomLines(r), randomLines(r)
+
+		var old, new []string
+		for _, edit := range diff.Lines(a, b, r.Intn(10)) {
+			if edit.Op != diff.Insert {
+				old = append(old, edit.Text)
+			}
+			if edit.Op != diff.Delete {
+				new = append(new, edit.Text)
+			}
+		}
+		return strings.Join(old, "\n") == a && strings.Join(new, "\n") == b
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func randomLines(r *rand.Rand) string {
+	lines := make([]string, r.Intn(15))
+	for i := range lines {
+		lines[i] = string(rune('a' + r.Intn(4)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func format(edits []diff.Edit) string {
+	var parts []string
+	for _, edit := range edits {
+		switch edit.Op {
+		case diff.Equal:
+			parts = append(parts, " "+edit.Text)
+		case diff.Insert:
+			parts = append(parts, "+"+edit.Text)
+		case diff.Delete:
+			parts = append(parts, "-"+edit.Text)
+		}
+	}
+	return strings.Join(parts, ",")
+}
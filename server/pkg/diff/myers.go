@@ -0,0 +1,134 @@
+package diff
+
+import "encoding/json"
+
+type Operation int
+
+const (
+	Equal Operation = iota
+	Insert
+	Delete
+)
+
+func (o Operation) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+func (o Operation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.String())
+}
+
+type Edit struct {
+	Op   Operation `json:"op"`
+	Text string    `json:"text"`
+}
+
+// Myers finds the shortest edit script that turns a into b
+// (E. Myers, "An O(ND) Difference Algorithm and Its Variations").
+// If the texts differ by more than limit edits, a is replaced with b as a whole,
+// limit <= 0 means no limit
+func Myers(a, b []string, limit int) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if limit > 0 && limit < max {
+		max = limit
+	}
+	offset := max + 1
+
+	v := make([]int, 2*max+3)
+
+	// NOTE: Only the 2d+1 diagonals reached on step d are kept, so the trace takes O(D^2), not O((N+M)D)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+
+		diagonals := make([]int, 2*d+1)
+		copy(diagonals, v[offset-d:offset+d+1])
+		trace = append(trace, diagonals)
+	}
+
+	return replace(a, b)
+}
+
+// NOTE: trace[d] holds the furthest x on the diagonals from -d to d after step d
+func backtrack(a, b []string, trace [][]int) []Edit {
+	x, y := len(a), len(b)
+	var reversed []Edit
+
+	for d := len(trace); d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, Edit{Op: Equal, Text: a[x]})
+		}
+
+		if x == prevX {
+			reversed = append(reversed, Edit{Op: Insert, Text: b[prevY]})
+		} else {
+			reversed = append(reversed, Edit{Op: Delete, Text: a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, Edit{Op: Equal, Text: a[x]})
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, edit := range reversed {
+		edits[len(reversed)-1-i] = edit
+	}
+	return edits
+}
+
+func replace(a, b []string) []Edit {
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, text := range a {
+		edits = append(edits, Edit{Op: Delete, Text: text})
+	}
+	for _, text := range b {
+		edits = append(edits, Edit{Op: Insert, Text: text})
+	}
+	return edits
+}
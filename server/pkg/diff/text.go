@@ -0,0 +1,169 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// NOTE: Whitespace runs are tokens too, so joining the tokens gives back the text
+func splitWords(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		if i > 0 && unicode.IsSpace(r) != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// Lines compares texts line by line, every edit holds a single line.
+// The limit is passed to Myers
+func Lines(a, b string, limit int) []Edit {
+	return Myers(splitLines(a), splitLines(b), limit)
+}
+
+// Words compares texts word by word, adjacent edits of the same kind are merged.
+// The limit is passed to Myers
+func Words(a, b string, limit int) []Edit {
+	var merged []Edit
+	for _, edit := range Myers(splitWords(a), splitWords(b), limit) {
+		if last := len(merged) - 1; last >= 0 && merged[last].Op == edit.Op {
+			merged[last].Text += edit.Text
+			continue
+		}
+		merged = append(merged, edit)
+	}
+	return merged
+}
+
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Edits    []Edit `json:"edits"`
+}
+
+// Hunks groups line edits into hunks with the given number of
+// unchanged lines around the changes. Line numbers start at 1.
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := max(0, i-context)
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+
+			equal := end
+			for equal < len(edits) && edits[equal].Op == Equal {
+				equal++
+			}
+			if equal == len(edits) || equal-end > 2*context {
+				end = min(equal, end+context)
+				break
+			}
+			end = equal
+		}
+
+		oldLine, newLine := 1, 1
+		for _, edit := range edits[:start] {
+			if edit.Op != Insert {
+				oldLine++
+			}
+			if edit.Op != Delete {
+				newLine++
+			}
+		}
+
+		hunk := Hunk{OldStart: oldLine, NewStart: newLine, Edits: edits[start:end]}
+		for _, edit := range hunk.Edits {
+			if edit.Op != Insert {
+				hunk.OldLines++
+			}
+			if edit.Op != Delete {
+				hunk.NewLines++
+			}
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+
+	return hunks
+}
+
+// Unified formats the hunks in the unified diff format
+func Unified(from, to string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", from, to)
+	for _, hunk := range hunks {
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n", hunkRange(hunk.OldStart, hunk.OldLines), hunkRange(hunk.NewStart, hunk.NewLines))
+		for _, edit := range hunk.Edits {
+			switch edit.Op {
+			case Equal:
+				builder.WriteString(" ")
+			case Insert:
+				builder.WriteString("+")
+			case Delete:
+				builder.WriteString("-")
+			}
+			builder.WriteString(edit.Text)
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+// NOTE: Empty range starts one line before, as in GNU diff
+func hunkRange(start, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, lines)
+	}
+}
+
+// Inline formats word edits the way "git diff --word-diff=plain" does
+func Inline(edits []Edit) string {
+	var builder strings.Builder
+	for _, edit := range edits {
+		switch edit.Op {
+		case Equal:
+			builder.WriteString(edit.Text)
+		case Insert:
+			fmt.Fprintf(&builder, "{+%s+}", edit.Text)
+		case Delete:
+			fmt.Fprintf(&builder, "[-%s-]", edit.Text)
+		}
+	}
+	return builder.String()
+}
@@ -2,22 +2,52 @@ package broadcast
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/SergeyCherepiuk/docs/pkg/crdt"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/http/middleware"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/net/websocket"
 )
 
-var (
-	messageHandlers = map[string]func(*websocket.Conn, []byte) error{
-		"pointer":   handlePointerMessage,
-		"content":   handleContentMessage,
-		"selection": handleSelectionMessage,
-		"user":      handleUserMessage,
+// NOTE: Handler returns the payload to be broadcasted
+// to the other connections of the room, or nil if there is none
+var messageHandlers = map[string]func(*room, *websocket.Conn, []byte) ([]byte, error){
+	"pointer":   handlePointerMessage,
+	"content":   handleContentMessage,
+	"selection": handleSelectionMessage,
+	"user":      handleUserMessage,
+}
+
+// NOTE: Set by the router, same as the Store. Browsers send the cookies with the handshake
+// of any page, so sockets opened by the pages of other origins are rejected
+var AllowedOrigins []string
+
+var ErrOriginNotAllowed = errors.New("origin not allowed")
+
+// NOTE: Clients other than browsers (e.g. with API tokens) may not send the origin at all,
+// a cross-site page can't leave it out
+func checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		return nil
 	}
-	connections     = make(map[*websocket.Conn]User)
-	documentContent = []byte("")
-)
+
+	for _, allowed := range AllowedOrigins {
+		if strings.EqualFold(origin.Scheme+"://"+origin.Host, strings.TrimSuffix(allowed, "/")) {
+			config.Origin = origin
+			return nil
+		}
+	}
+	return ErrOriginNotAllowed
+}
 
 type Message struct {
 	MessageType string          `json:"messageType"`
@@ -47,12 +77,33 @@ type Selection struct {
 }
 
 func Connect(c echo.Context) error {
-	websocket.Server{Handler: func(wsc *websocket.Conn) {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	level, ok := c.Get("access").(string)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Access level wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	// NOTE: API token without the write scope can only watch the file
+	readOnly := level == models.RAcess || !middleware.HasScope(c, models.ScopeFilesWrite)
+
+	websocket.Server{Handshake: checkOrigin, Handler: func(wsc *websocket.Conn) {
 		defer wsc.Close()
-		defer delete(connections, wsc)
-		// TODO: Send disconnect message
 
-		// TODO: Send current UI state back
+		room, err := rooms.join(id.String(), wsc, User{ID: user.Username})
+		if err != nil {
+			return
+		}
+		defer rooms.leave(room, wsc)
+		// TODO: Send disconnect message
 
 		for {
 			var jsonMessage []byte
@@ -65,20 +116,41 @@ func Connect(c echo.Context) error {
 				break
 			}
 
-			if handler, ok := messageHandlers[message.MessageType]; ok {
-				handler(wsc, []byte(message.RawMessage)) // NOTE: Error is ignored
+			if message.MessageType == "content" && readOnly {
+				continue
 			}
 
-			if message.MessageType == "user" {
+			handler, ok := messageHandlers[message.MessageType]
+			if !ok {
 				continue
 			}
 
-			for conn := range connections {
-				if conn != wsc {
-					websocket.Message.Send(conn, string(jsonMessage))
-				}
+			rawMessage, err := handler(room, wsc, []byte(message.RawMessage))
+			if errors.Is(err, crdt.ErrTooManyPending) {
+				break // NOTE: Client keeps sending operations that depend on the ones it never sent
+			}
+			if err != nil || rawMessage == nil {
+				continue // NOTE: Error is ignored
+			}
+
+			if outgoing, err := newMessage(message.MessageType, json.RawMessage(rawMessage)); err == nil {
+				room.broadcast(wsc, outgoing)
 			}
 		}
 	}}.ServeHTTP(c.Response(), c.Request())
 	return c.NoContent(http.StatusSwitchingProtocols)
 }
+
+func newMessage(messageType string, rawMessage any) (string, error) {
+	encoded, err := json.Marshal(rawMessage)
+	if err != nil {
+		return "", err
+	}
+
+	message, err := json.Marshal(Message{MessageType: messageType, RawMessage: encoded})
+	if err != nil {
+		return "", err
+	}
+
+	return string(message), nil
+}
@@ -0,0 +1,417 @@
+package broadcast_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/http/broadcast"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+type memoryStore struct {
+	mu       sync.Mutex
+	contents map[string]models.Content
+	blocked  map[string]chan struct{}
+	deleted  map[string]bool
+}
+
+// NOTE: Loading a blocked file waits until it's unblocked, as if the database was slow
+func (s *memoryStore) Load(fileId string) (models.Content, error) {
+	s.mu.Lock()
+	unblocked := s.blocked[fileId]
+	s.mu.Unlock()
+
+	if unblocked != nil {
+		<-unblocked
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.contents[fileId], nil
+}
+
+func (s *memoryStore) block(fileId string) (unblock func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unblocked := make(chan struct{})
+	s.blocked[fileId] = unblocked
+	return func() { close(unblocked) }
+}
+
+// NOTE: Saving a deleted file fails, as if the file was deleted while being edited
+func (s *memoryStore) delete(fileId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted[fileId] = true
+}
+
+func (s *memoryStore) Save(fileId string, content models.Content, author string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deleted[fileId] {
+		return database.NotFound("file not found")
+	}
+	s.contents[fileId] = content
+	return nil
+}
+
+var store = &memoryStore{
+	contents: make(map[string]models.Content),
+	blocked:  make(map[string]chan struct{}),
+	deleted:  make(map[string]bool),
+}
+
+func init() {
+	broadcast.Store = store
+}
+
+// NOTE: Stands in for RequireSession and RequireAtLeastRAccess middlewares
+func fakeSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Set("user", models.User{Username: c.QueryParam("username")})
+		c.Set("access", c.QueryParam("level"))
+		return next(c)
+	}
+}
+
+func newServer() *httptest.Server {
+	e := echo.New()
+	e.GET("/files/:id/live", broadcast.Connect, fakeSession)
+
+	server := httptest.NewServer(e)
+	broadcast.AllowedOrigins = []string{server.URL}
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server, fileId, username, level string) *websocket.Conn {
+	wsc, _ := dialWithDocument(t, server, fileId, username, level)
+	return wsc
+}
+
+func dialWithDocument(t *testing.T, server *httptest.Server, fileId, username, level string) (*websocket.Conn, string) {
+	url := strings.Replace(server.URL, "http", "ws", 1) + "/files/" + fileId + "/live?username=" + username + "&level=" + level
+	wsc, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { wsc.Close() })
+
+	document, ok := receive(wsc, time.Second)
+	if !ok {
+		t.Fatalf("expected the document to be sent on connect")
+	}
+	return wsc, document
+}
+
+func send(t *testing.T, wsc *websocket.Conn, message string) {
+	if err := websocket.Message.Send(wsc, message); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+}
+
+func receive(wsc *websocket.Conn, timeout time.Duration) (string, bool) {
+	wsc.SetReadDeadline(time.Now().Add(timeout))
+	var message string
+	if err := websocket.Message.Receive(wsc, &message); err != nil {
+		return "", false
+	}
+	return message, true
+}
+
+func TestRoomsAreIsolated(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	fileA, fileB := uuid.NewString(), uuid.NewString()
+	a1, a2 := dial(t, server, fileA, "alice", models.RWAccess), dial(t, server, fileA, "bob", models.RWAccess)
+	b1 := dial(t, server, fileB, "carol", models.RWAccess)
+
+	for _, wsc := range []*websocket.Conn{a1, a2, b1} {
+		send(t, wsc, `{"messageType":"user","rawMessage":{"id":"someone"}}`)
+	}
+	time.Sleep(50 * time.Millisecond) // NOTE: Letting the server register all connections
+
+	send(t, a1, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"insert":"hello"}]}}`)
+	if actual, ok := receive(a1, time.Second); !ok || actual != `{"messageType":"ack","rawMessage":{"revision":1}}` {
+		t.Errorf(`expected an acknowledgement, actual: "%s"`, actual)
+	}
+
+	expected := `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"insert":"hello"}]}}`
+
+	if actual, ok := receive(a2, time.Second); !ok || actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+
+	if actual, ok := receive(b1, 200*time.Millisecond); ok {
+		t.Errorf(`expected no message in another room, actual: "%s"`, actual)
+	}
+}
+
+func TestReaderCannotSendContent(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	reader, writer := dial(t, server, file, "alice", models.RAcess), dial(t, server, file, "bob", models.RWAccess)
+	time.Sleep(50 * time.Millisecond) // NOTE: Letting the server register all connections
+
+	send(t, reader, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"insert":"hello"}]}}`)
+	if actual, ok := receive(writer, 200*time.Millisecond); ok {
+		t.Errorf(`expected content from reader to be dropped, actual: "%s"`, actual)
+	}
+
+	send(t, reader, `{"messageType":"selection","rawMessage":{"id":"bob","selection":{"start":1,"end":2}}}`)
+	actual, ok := receive(writer, time.Second)
+	if !ok {
+		t.Fatalf("expected selection from reader to be broadcasted")
+	}
+
+	var message struct {
+		RawMessage broadcast.User `json:"rawMessage"`
+	}
+	if err := json.Unmarshal([]byte(actual), &message); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if message.RawMessage.ID != "alice" {
+		t.Errorf(`expected: "%s", actual: "%s"`, "alice", message.RawMessage.ID)
+	}
+	if message.RawMessage.Selection.End != 2 {
+		t.Errorf(`expected: %d, actual: %d`, 2, message.RawMessage.Selection.End)
+	}
+}
+
+func TestConcurrentOperationsAreTransformed(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	a, b := dial(t, server, file, "alice", models.RWAccess), dial(t, server, file, "bob", models.RWAccess)
+	time.Sleep(50 * time.Millisecond) // NOTE: Letting the server register all connections
+
+	send(t, a, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"insert":"abc"}]}}`)
+	receive(a, time.Second) // NOTE: Acknowledgement
+	receive(b, time.Second) // NOTE: Operation of alice
+
+	// NOTE: Both edit revision 1 ("abc") without seeing each other's change
+	send(t, a, `{"messageType":"content","rawMessage":{"revision":1,"operation":[{"insert":"X"},{"retain":3}]}}`)
+	receive(a, time.Second)
+	send(t, b, `{"messageType":"content","rawMessage":{"revision":1,"operation":[{"retain":3},{"insert":"Y"}]}}`)
+
+	expected := `{"messageType":"content","rawMessage":{"revision":2,"operation":[{"retain":4},{"insert":"Y"}]}}`
+	if actual, ok := receive(a, time.Second); !ok || actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+
+	_, actual := dialWithDocument(t, server, file, "carol", models.RAcess)
+	expected = `{"messageType":"document","rawMessage":{"revision":3,"content":"XabcY"}}`
+	if actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+}
+
+func TestCRDTEngineMergesDeltas(t *testing.T) {
+	t.Setenv("DOCUMENT_ENGINE", broadcast.CRDTEngine)
+
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	a, b := dial(t, server, file, "alice", models.RWAccess), dial(t, server, file, "bob", models.RWAccess)
+	time.Sleep(50 * time.Millisecond) // NOTE: Letting the server register all connections
+
+	delta := `{"delta":[{"type":"insert","id":{"clock":1,"site":"a"},"origin":{"clock":0,"site":""},"value":"h"},{"type":"insert","id":{"clock":2,"site":"a"},"origin":{"clock":1,"site":"a"},"value":"i"}]}`
+	send(t, a, `{"messageType":"content","rawMessage":`+delta+`}`)
+
+	expected := `{"messageType":"content","rawMessage":` + delta + `}`
+	if actual, ok := receive(b, time.Second); !ok || actual != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, actual)
+	}
+
+	if actual, _ := broadcast.Content(file); actual != "hi" {
+		t.Errorf(`expected: "%s", actual: "%s"`, "hi", actual)
+	}
+}
+
+func TestContentIsFlushedWhenLastClientLeaves(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	store.Save(file, models.Content{Text: "abc"}, "alice")
+
+	wsc, document := dialWithDocument(t, server, file, "alice", models.RWAccess)
+	expected := `{"messageType":"document","rawMessage":{"revision":0,"content":"abc"}}`
+	if document != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, document)
+	}
+
+	send(t, wsc, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"retain":3},{"insert":"d"}]}}`)
+	receive(wsc, time.Second) // NOTE: Acknowledgement
+	wsc.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if content, _ := store.Load(file); content.Text == "abcd" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	content, _ := store.Load(file)
+	t.Errorf(`expected: "%s", actual: "%s"`, "abcd", content.Text)
+}
+
+// NOTE: The flush is postponed by every change, the content
+// is saved once there were no changes for the flush delay
+func TestFlushIsDebounced(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	store.Save(file, models.Content{Text: "abc"}, "alice")
+
+	wsc := dial(t, server, file, "alice", models.RWAccess)
+
+	send(t, wsc, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"retain":3},{"insert":"d"}]}}`)
+	receive(wsc, time.Second) // NOTE: Acknowledgement
+	time.Sleep(1200 * time.Millisecond)
+	send(t, wsc, `{"messageType":"content","rawMessage":{"revision":1,"operation":[{"retain":4},{"insert":"e"}]}}`)
+	receive(wsc, time.Second)
+
+	time.Sleep(1300 * time.Millisecond)
+	if content, _ := store.Load(file); content.Text != "abc" {
+		t.Errorf(`expected the flush to be postponed, got "%s"`, content.Text)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, _ := store.Load(file); content.Text == "abcde" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	content, _ := store.Load(file)
+	t.Errorf(`expected: "%s", actual: "%s"`, "abcde", content.Text)
+}
+
+// NOTE: Bob doesn't read anything, once the socket's buffers are full his
+// messages are queued and alice keeps receiving the acknowledgements
+func TestSlowClientDoesNotBlockRoom(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	a, _ := dial(t, server, file, "alice", models.RWAccess), dial(t, server, file, "bob", models.RWAccess)
+	time.Sleep(50 * time.Millisecond) // NOTE: Letting the server register all connections
+
+	text := strings.Repeat("a", 64*1024)
+	insert := fmt.Sprintf(`{"insert":"%s"}`, text)
+	remove := fmt.Sprintf(`{"delete":%d}`, len(text))
+	for revision := 0; revision < 400; revision++ {
+		operation := insert
+		if revision%2 == 1 {
+			operation = remove
+		}
+		send(t, a, fmt.Sprintf(`{"messageType":"content","rawMessage":{"revision":%d,"operation":[%s]}}`, revision, operation))
+		if _, ok := receive(a, time.Second); !ok {
+			t.Fatalf("expected the acknowledgement of the revision %d", revision)
+		}
+	}
+}
+
+// NOTE: Once the file is deleted the room must be dropped instead of retrying the flush forever
+func TestRoomIsDroppedWhenFileIsDeleted(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	file := uuid.NewString()
+	store.Save(file, models.Content{Text: "abc"}, "alice")
+
+	wsc := dial(t, server, file, "alice", models.RWAccess)
+	store.delete(file)
+
+	send(t, wsc, `{"messageType":"content","rawMessage":{"revision":0,"operation":[{"retain":3},{"insert":"d"}]}}`)
+	receive(wsc, time.Second) // NOTE: Acknowledgement
+
+	if message, ok := receive(wsc, 4*time.Second); ok {
+		t.Fatalf(`expected the connection to be closed, got "%s"`, message)
+	}
+
+	_, document := dialWithDocument(t, server, file, "alice", models.RWAccess)
+	expected := `{"messageType":"document","rawMessage":{"revision":0,"content":"abc"}}`
+	if document != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, document)
+	}
+}
+
+func TestSlowLoadDoesNotBlockOtherRooms(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	slow, fast := uuid.NewString(), uuid.NewString()
+	store.Save(slow, models.Content{Text: "slow"}, "alice")
+	store.Save(fast, models.Content{Text: "fast"}, "alice")
+
+	unblock := sync.OnceFunc(store.block(slow))
+	defer unblock()
+
+	joined := make(chan string, 1)
+	go func() {
+		url := strings.Replace(server.URL, "http", "ws", 1) + "/files/" + slow + "/live?username=alice&level=" + models.RWAccess
+		wsc, err := websocket.Dial(url, "", server.URL)
+		if err != nil {
+			joined <- ""
+			return
+		}
+		defer wsc.Close()
+
+		document, _ := receive(wsc, 2*time.Second)
+		joined <- document
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	_, document := dialWithDocument(t, server, fast, "bob", models.RWAccess)
+	if expected := `{"messageType":"document","rawMessage":{"revision":0,"content":"fast"}}`; document != expected {
+		t.Errorf(`expected: "%s", actual: "%s"`, expected, document)
+	}
+
+	unblock()
+	if expected := `{"messageType":"document","rawMessage":{"revision":0,"content":"slow"}}`; <-joined != expected {
+		t.Errorf("expected the slow room to be joined once loaded")
+	}
+}
+
+func TestReplaceWithoutRoomIsSaved(t *testing.T) {
+	file := uuid.NewString()
+	store.Save(file, models.Content{Text: "old"}, "alice")
+
+	if err := broadcast.Replace(file, "new", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := store.Load(file); content.Text != "new" {
+		t.Errorf(`expected: "%s", actual: "%s"`, "new", content.Text)
+	}
+	if _, ok := broadcast.Content(file); ok {
+		t.Errorf("expected no room to be left open")
+	}
+}
+
+func TestCrossOriginIsRejected(t *testing.T) {
+	server := newServer()
+	defer server.Close()
+
+	url := strings.Replace(server.URL, "http", "ws", 1) + "/files/" + uuid.NewString() + "/live?username=alice&level=" + models.RWAccess
+	if wsc, err := websocket.Dial(url, "", "http://evil.example"); err == nil {
+		wsc.Close()
+		t.Errorf("expected the socket of another origin to be rejected")
+	}
+}
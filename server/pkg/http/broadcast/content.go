@@ -4,7 +4,8 @@ import (
 	"golang.org/x/net/websocket"
 )
 
-func handleContentMessage(wsc *websocket.Conn, message []byte) error {
-	documentContent = message
-	return nil
+// NOTE: Content is broadcasted by the room itself,
+// so every client receives changes in the order they were applied
+func handleContentMessage(r *room, wsc *websocket.Conn, message []byte) ([]byte, error) {
+	return nil, r.applyContent(wsc, message)
 }
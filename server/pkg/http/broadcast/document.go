@@ -0,0 +1,188 @@
+package broadcast
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/SergeyCherepiuk/docs/pkg/crdt"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/ot"
+)
+
+// document is the canonical content of a room. It's accessed
+// only under the room's lock, so it doesn't need its own.
+type document interface {
+	Content() string
+
+	// Message sent to a connection when it joins the room
+	snapshot() any
+
+	// Applies "content" message and returns the acknowledgement for the sender
+	// (nil if there is none) and the message to be sent to the other connections.
+	// The message is sent even with an error, if it's set
+	apply(message []byte) (ack any, outgoing any, err error)
+
+	// Replaces the whole content and returns the message for all connections
+	replace(text string) (outgoing any, err error)
+
+	// Representation of the document to be persisted
+	content() (models.Content, error)
+}
+
+const (
+	OTEngine   = "ot"
+	CRDTEngine = "crdt"
+)
+
+// NOTE: Site of the changes made by the server itself (e.g. content replaced through REST)
+const serverSite = "server"
+
+func newDocument(content models.Content) (document, error) {
+	switch os.Getenv("DOCUMENT_ENGINE") {
+	case CRDTEngine:
+		return newCRDTDocument(content)
+	default:
+		return otDocument{ot.NewDocument(content.Text)}, nil
+	}
+}
+
+type otDocument struct {
+	*ot.Document
+}
+
+// NOTE: Revision is the revision of the document the operation is based on
+type ContentMessage struct {
+	Revision  int          `json:"revision"`
+	Operation ot.Operation `json:"operation"`
+}
+
+type DocumentMessage struct {
+	Revision int    `json:"revision"`
+	Content  string `json:"content"`
+}
+
+type AckMessage struct {
+	Revision int `json:"revision"`
+}
+
+func (d otDocument) snapshot() any {
+	return DocumentMessage{Revision: d.Revision(), Content: d.Content()}
+}
+
+func (d otDocument) apply(message []byte) (any, any, error) {
+	var m ContentMessage
+	if err := json.Unmarshal(message, &m); err != nil {
+		return nil, nil, err
+	}
+
+	base := d.Revision()
+	operation, err := d.Apply(m.Revision, m.Operation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return AckMessage{Revision: d.Revision()}, ContentMessage{Revision: base, Operation: operation}, nil
+}
+
+func (d otDocument) replace(text string) (any, error) {
+	base := d.Revision()
+	operation := ot.Operation{}.Delete(len([]rune(d.Content()))).Insert(text)
+	operation, err := d.Apply(base, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	return ContentMessage{Revision: base, Operation: operation}, nil
+}
+
+func (d otDocument) content() (models.Content, error) {
+	return models.Content{Text: d.Content()}, nil
+}
+
+type crdtDocument struct {
+	*crdt.Document
+}
+
+// NOTE: State is ignored if it doesn't match the text, which happens
+// when the text was updated while the other engine was in use
+func newCRDTDocument(content models.Content) (document, error) {
+	if content.State != "" {
+		var state []crdt.Element
+		if err := json.Unmarshal([]byte(content.State), &state); err != nil {
+			return nil, err
+		}
+
+		d, err := crdt.FromState(state)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Text() == content.Text {
+			return crdtDocument{d}, nil
+		}
+	}
+
+	d := crdt.NewDocument()
+	if _, err := d.Insert(serverSite, 0, content.Text); err != nil {
+		return nil, err
+	}
+	return crdtDocument{d}, nil
+}
+
+type CRDTContentMessage struct {
+	Delta crdt.Delta `json:"delta"`
+}
+
+type CRDTDocumentMessage struct {
+	State   []crdt.Element `json:"state"`
+	Content string         `json:"content"`
+}
+
+func (d crdtDocument) Content() string {
+	return d.Text()
+}
+
+func (d crdtDocument) snapshot() any {
+	return CRDTDocumentMessage{State: d.State(), Content: d.Text()}
+}
+
+// NOTE: Deltas commute, so they are relayed as they are, without acknowledgement
+func (d crdtDocument) apply(message []byte) (any, any, error) {
+	var m CRDTContentMessage
+	if err := json.Unmarshal(message, &m); err != nil {
+		return nil, nil, err
+	}
+
+	// NOTE: Delta that overflowed the pending operations is still relayed,
+	// the operations that were merged must reach the other replicas
+	err := d.Merge(m.Delta)
+	if err != nil && !errors.Is(err, crdt.ErrTooManyPending) {
+		return nil, nil, err
+	}
+
+	return nil, m, err
+}
+
+func (d crdtDocument) replace(text string) (any, error) {
+	deleted, err := d.Delete(0, len([]rune(d.Text())))
+	if err != nil {
+		return nil, err
+	}
+
+	inserted, err := d.Insert(serverSite, 0, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return CRDTContentMessage{Delta: append(deleted, inserted...)}, nil
+}
+
+func (d crdtDocument) content() (models.Content, error) {
+	state, err := json.Marshal(d.State())
+	if err != nil {
+		return models.Content{}, err
+	}
+
+	return models.Content{Text: d.Text(), State: string(state)}, nil
+}
@@ -0,0 +1,419 @@
+package broadcast
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"golang.org/x/net/websocket"
+)
+
+// NOTE: Content is flushed to the store once there were no changes for flushDelay,
+// but no later than maxFlushDelay after the first unsaved change, so that
+// continuous typing is saved too. It's also flushed when the last connection leaves the room
+const (
+	flushDelay    = 2 * time.Second
+	maxFlushDelay = 10 * time.Second
+)
+
+// NOTE: Failed flushes are retried with the delay doubled every time, up to maxRetryDelay.
+// After maxFlushFailures in a row, or once the file is deleted, the room is dropped
+// along with the unsaved changes and its connections are closed
+const (
+	maxRetryDelay    = time.Minute
+	maxFlushFailures = 5
+)
+
+// NOTE: Messages are queued under the room's lock, so every client receives them in the order
+// they were applied, and written by a goroutine per connection, so a slow client doesn't block
+// the room. A client that falls behind by outboundSize messages or doesn't take a message
+// within writeTimeout is disconnected
+const (
+	outboundSize = 256
+	writeTimeout = 10 * time.Second
+)
+
+type connection struct {
+	wsc       *websocket.Conn
+	user      User
+	outbound  chan string
+	closeOnce sync.Once
+}
+
+func newConnection(wsc *websocket.Conn, user User) *connection {
+	c := &connection{wsc: wsc, user: user, outbound: make(chan string, outboundSize)}
+	go c.write()
+	return c
+}
+
+// NOTE: Outbound is drained after a failure, until it's closed by leaving the room
+func (c *connection) write() {
+	for message := range c.outbound {
+		c.wsc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := websocket.Message.Send(c.wsc, message); err != nil {
+			c.close()
+		}
+	}
+}
+
+// NOTE: Must be called with the room's lock held (read lock is enough)
+func (c *connection) enqueue(message string) {
+	select {
+	case c.outbound <- message:
+	default:
+		c.close()
+	}
+}
+
+// NOTE: Closing may wait for the message being written, so it doesn't hold up the caller
+func (c *connection) close() {
+	c.closeOnce.Do(func() { go c.wsc.Close() })
+}
+
+type room struct {
+	mu          sync.RWMutex
+	id          string
+	connections map[*websocket.Conn]*connection
+	document    document
+
+	// NOTE: The room is put into the registry before its content is loaded, so the store
+	// isn't called under the registry's lock. Loaded is closed once the document is set
+	loaded  chan struct{}
+	loadErr error
+
+	// NOTE: Connections and the replaces in progress, guarded by the registry's lock
+	refs int
+
+	// NOTE: Changes flushed together are attributed to the last editor
+	dirty      bool
+	dirtySince time.Time
+	failures   int
+	lastEditor string
+	flushTimer *time.Timer
+	flushMu    sync.Mutex
+}
+
+func newRoom(id string) *room {
+	return &room{
+		id:          id,
+		connections: make(map[*websocket.Conn]*connection),
+		loaded:      make(chan struct{}),
+	}
+}
+
+func (r *room) load() {
+	defer close(r.loaded)
+
+	content, err := Store.Load(r.id)
+	if err != nil {
+		r.loadErr = err
+		return
+	}
+	r.document, r.loadErr = newDocument(content)
+}
+
+func (r *room) updateUser(wsc *websocket.Conn, update func(*User)) (User, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.connections[wsc]
+	if !ok {
+		return User{}, false
+	}
+
+	update(&c.user)
+	return c.user, true
+}
+
+func (r *room) applyContent(wsc *websocket.Conn, message []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ack, outgoing, err := r.document.apply(message)
+	if err != nil && outgoing == nil {
+		return err
+	}
+	sender := r.connections[wsc]
+	r.markDirty(sender.user.ID)
+
+	if ack != nil {
+		if message, err := newMessage("ack", ack); err == nil {
+			sender.enqueue(message)
+		}
+	}
+
+	if sendErr := r.send(wsc, outgoing); sendErr != nil {
+		return sendErr
+	}
+	return err
+}
+
+func (r *room) replaceContent(text, author string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outgoing, err := r.document.replace(text)
+	if err != nil {
+		return err
+	}
+	r.markDirty(author)
+
+	return r.send(nil, outgoing)
+}
+
+// NOTE: Must be called with the lock held
+func (r *room) send(sender *websocket.Conn, outgoing any) error {
+	content, err := newMessage("content", outgoing)
+	if err != nil {
+		return err
+	}
+
+	for wsc, c := range r.connections {
+		if wsc != sender {
+			c.enqueue(content)
+		}
+	}
+	return nil
+}
+
+func (r *room) content() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.document.Content()
+}
+
+// NOTE: Must be called with the lock held
+func (r *room) markDirty(editor string) {
+	if !r.dirty {
+		r.dirtySince = time.Now()
+	}
+	r.dirty = true
+	r.lastEditor = editor
+
+	delay := min(flushDelay, maxFlushDelay-time.Since(r.dirtySince))
+	if r.failures > 0 {
+		delay = min(flushDelay<<r.failures, maxRetryDelay)
+	}
+	if r.flushTimer != nil {
+		r.flushTimer.Reset(delay)
+		return
+	}
+	r.flushTimer = time.AfterFunc(delay, func() {
+		if err := r.flush(); err != nil {
+			log.Printf("failed to flush the content of the file %s: %v", r.id, err)
+			return
+		}
+		rooms.evictIfIdle(r)
+	})
+}
+
+func (r *room) flush() error {
+	// NOTE: Saves are serialized, so an older content never overwrites a newer one
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.Lock()
+	if r.flushTimer != nil {
+		r.flushTimer.Stop()
+		r.flushTimer = nil
+	}
+	if !r.dirty {
+		r.mu.Unlock()
+		return nil
+	}
+	content, err := r.document.content()
+	editor := r.lastEditor
+	r.dirty = false
+	r.mu.Unlock()
+
+	if err == nil {
+		err = Store.Save(r.id, content, editor)
+	}
+
+	r.mu.Lock()
+	if err == nil {
+		r.failures = 0
+		r.mu.Unlock()
+		return nil
+	}
+	r.failures++
+	drop := errors.Is(err, database.ErrNotFound) || r.failures >= maxFlushFailures
+	if !drop && !r.dirty {
+		r.markDirty(editor)
+	}
+	r.mu.Unlock()
+
+	if drop {
+		rooms.drop(r)
+	}
+	return err
+}
+
+func (r *room) broadcast(sender *websocket.Conn, message string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for wsc, c := range r.connections {
+		if wsc != sender {
+			c.enqueue(message)
+		}
+	}
+}
+
+type roomRegistry struct {
+	mu    sync.Mutex
+	rooms map[string]*room
+}
+
+var rooms = roomRegistry{rooms: make(map[string]*room)}
+
+// NOTE: Content is loaded by the first one to acquire the room, the others wait for it.
+// Registry's lock isn't held meanwhile, so the other rooms aren't blocked by the store
+func (rr *roomRegistry) acquire(id string) (*room, error) {
+	rr.mu.Lock()
+	r, ok := rr.rooms[id]
+	if !ok {
+		r = newRoom(id)
+		rr.rooms[id] = r
+	}
+	r.refs++
+	rr.mu.Unlock()
+
+	if !ok {
+		r.load()
+	}
+	<-r.loaded
+
+	if r.loadErr != nil {
+		rr.release(r)
+		return nil, r.loadErr
+	}
+	return r, nil
+}
+
+// NOTE: Room stays in the registry until its content is flushed,
+// so a client joining in the meantime doesn't load a stale content
+func (rr *roomRegistry) release(r *room) error {
+	rr.mu.Lock()
+	r.refs--
+	idle := r.refs == 0
+	rr.mu.Unlock()
+
+	if !idle {
+		return nil
+	}
+
+	if err := r.flush(); err != nil {
+		return err
+	}
+	rr.evictIfIdle(r)
+	return nil
+}
+
+// NOTE: Current state of the document is queued for the joined connection
+// under the room's lock, so no change is missed in between
+func (rr *roomRegistry) join(id string, wsc *websocket.Conn, user User) (*room, error) {
+	r, err := rr.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := newConnection(wsc, user)
+	r.connections[wsc] = c
+	if message, err := newMessage("document", r.document.snapshot()); err == nil {
+		c.enqueue(message)
+	}
+
+	return r, nil
+}
+
+func (rr *roomRegistry) leave(r *room, wsc *websocket.Conn) {
+	r.mu.Lock()
+	if c, ok := r.connections[wsc]; ok {
+		delete(r.connections, wsc)
+		close(c.outbound)
+	}
+	r.mu.Unlock()
+
+	if err := rr.release(r); err != nil {
+		log.Printf("failed to flush the content of the file %s: %v", r.id, err)
+	}
+}
+
+// NOTE: Connections are closed, so the clients reconnect and load the content from the store
+func (rr *roomRegistry) drop(r *room) {
+	rr.mu.Lock()
+	if rr.rooms[r.id] == r {
+		delete(rr.rooms, r.id)
+	}
+	rr.mu.Unlock()
+
+	r.mu.Lock()
+	if r.flushTimer != nil {
+		r.flushTimer.Stop()
+		r.flushTimer = nil
+	}
+	r.dirty = false
+	for _, c := range r.connections {
+		c.close()
+	}
+	r.mu.Unlock()
+}
+
+func (rr *roomRegistry) evictIfIdle(r *room) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.refs == 0 && !r.dirty && rr.rooms[r.id] == r {
+		delete(rr.rooms, r.id)
+	}
+}
+
+// NOTE: A room that is still being loaded is waited for
+func (rr *roomRegistry) get(id string) (*room, bool) {
+	rr.mu.Lock()
+	r, ok := rr.rooms[id]
+	rr.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	<-r.loaded
+	return r, r.loadErr == nil
+}
+
+// Content returns the live content of the file,
+// if somebody is editing it at the moment
+func Content(fileId string) (string, bool) {
+	r, ok := rooms.get(fileId)
+	if !ok {
+		return "", false
+	}
+	return r.content(), true
+}
+
+// Replace replaces the content of the file on behalf of the author. If somebody
+// is editing the file at the moment, the change goes through the room and
+// reaches the store with the next flush, otherwise it's saved right away.
+func Replace(fileId, text, author string) error {
+	// NOTE: The change always goes through the room, so a client
+	// joining in the meantime can't load the content it replaces
+	r, err := rooms.acquire(fileId)
+	if err != nil {
+		return err
+	}
+
+	err = r.replaceContent(text, author)
+	if releaseErr := rooms.release(r); err == nil {
+		err = releaseErr
+	}
+	return err
+}
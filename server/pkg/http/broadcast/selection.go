@@ -7,18 +7,17 @@ import (
 	"golang.org/x/net/websocket"
 )
 
-func handleSelectionMessage(wsc *websocket.Conn, message []byte) error {
+func handleSelectionMessage(r *room, wsc *websocket.Conn, message []byte) ([]byte, error) {
 	var u User
 	if err := json.Unmarshal(message, &u); err != nil {
-		return err
+		return nil, err
 	}
 
-	user, ok := connections[wsc]
+	user, ok := r.updateUser(wsc, func(user *User) { user.Selection = u.Selection })
 	if !ok {
-		return fmt.Errorf("connection wasn't found")
+		return nil, fmt.Errorf("connection wasn't found")
 	}
 
-	user.Selection = u.Selection
-	connections[wsc] = user
-	return nil
+	// NOTE: Sending the user stored in the room, so the identity always comes from the session
+	return json.Marshal(user)
 }
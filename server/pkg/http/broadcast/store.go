@@ -0,0 +1,83 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+// ContentStore is where the content of the rooms is loaded from
+// when the first client joins and flushed to while they edit.
+// Saves become the revisions of the file made by the author.
+type ContentStore interface {
+	Load(fileId string) (models.Content, error)
+	Save(fileId string, content models.Content, author string) error
+}
+
+// NOTE: Set by the router before any room is opened
+var Store ContentStore
+
+// NOTE: Content is saved on every flush, but a new revision is made only if the content differs
+// from the latest revision and either revisionInterval passed since it or at least revisionEditSize
+// characters were changed, otherwise every few seconds of typing would be a revision
+const (
+	revisionInterval = 5 * time.Minute
+	revisionEditSize = 200
+)
+
+type RepositoryStore struct {
+	Files      database.FileRepository
+	Revisions  database.RevisionRepository
+	Transactor database.Transactor
+}
+
+func (s RepositoryStore) Load(fileId string) (models.Content, error) {
+	return s.Files.GetContent(context.Background(), models.File{Id: fileId})
+}
+
+func (s RepositoryStore) Save(fileId string, content models.Content, author string) error {
+	file := models.File{Id: fileId}
+	return s.Transactor.InTransaction(context.Background(), func(ctx context.Context) error {
+		if err := s.Files.UpdateContent(ctx, file, content, models.User{Username: author}); err != nil {
+			return err
+		}
+
+		latest, err := s.Revisions.GetLatest(ctx, file)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if err == nil && !revisionDue(latest, content.Text) {
+			return nil
+		}
+
+		return s.Revisions.Create(ctx, file, models.NewRevision(author, content.Text))
+	})
+}
+
+func revisionDue(latest models.Revision, text string) bool {
+	if latest.Content == text {
+		return false
+	}
+	return time.Since(latest.CreatedAt) >= revisionInterval || editSize(latest.Content, text) >= revisionEditSize
+}
+
+// NOTE: Number of characters between the common prefix and the common suffix of the texts,
+// which is the size of a single edit and a rough estimate of several ones
+func editSize(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prefix := 0
+	for prefix < len(ra) && prefix < len(rb) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(ra)-prefix && suffix < len(rb)-prefix && ra[len(ra)-1-suffix] == rb[len(rb)-1-suffix] {
+		suffix++
+	}
+
+	return max(len(ra), len(rb)) - prefix - suffix
+}
@@ -0,0 +1,55 @@
+package broadcast_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/memory"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/http/broadcast"
+	"github.com/google/uuid"
+)
+
+// NOTE: Flushes of small edits follow each other within seconds, only the first
+// one and a large edit make a revision, the content is saved every time
+func TestSaveThrottlesRevisions(t *testing.T) {
+	repos := memory.Repositories()
+	repositoryStore := broadcast.RepositoryStore{Files: repos.Files, Revisions: repos.Revisions, Transactor: repos.Transactor}
+
+	ctx := context.Background()
+	owner := models.User{Username: "alice", Password: "password"}
+	if err := repos.Users.Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	file := models.File{Id: uuid.NewString(), Name: "notes"}
+	if err := repos.Files.Create(ctx, file, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	large := "abc" + strings.Repeat("d", 500)
+	saves := []struct {
+		text      string
+		revisions int
+	}{
+		{"a", 1},
+		{"ab", 1},
+		{"abc", 1},
+		{large, 2},
+		{large, 2},
+	}
+
+	for _, save := range saves {
+		if err := repositoryStore.Save(file.Id, models.Content{Text: save.text}, owner.Username); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+
+		content, _ := repositoryStore.Load(file.Id)
+		if content.Text != save.text {
+			t.Errorf(`expected: "%s", actual: "%s"`, save.text, content.Text)
+		}
+		if revisions, _ := repos.Revisions.GetAll(ctx, file); len(revisions) != save.revisions {
+			t.Errorf("expected %d revisions after saving %d characters, got %d", save.revisions, len(save.text), len(revisions))
+		}
+	}
+}
@@ -2,16 +2,25 @@ package broadcast
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"golang.org/x/net/websocket"
 )
 
-func handleUserMessage(wsc *websocket.Conn, message []byte) error {
+func handleUserMessage(r *room, wsc *websocket.Conn, message []byte) ([]byte, error) {
 	var user User
 	if err := json.Unmarshal(message, &user); err != nil {
-		return err
+		return nil, err
 	}
 
-	connections[wsc] = user
-	return nil
+	// NOTE: Client can't choose its own id, it's bound to the session on connect
+	_, ok := r.updateUser(wsc, func(u *User) {
+		user.ID = u.ID
+		*u = user
+	})
+	if !ok {
+		return nil, fmt.Errorf("connection wasn't found")
+	}
+
+	return nil, nil
 }
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
+	"github.com/labstack/echo/v4"
+)
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var kinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{database.ErrNotFound, nethttp.StatusNotFound, "not_found"},
+	{database.ErrConflict, nethttp.StatusConflict, "conflict"},
+	{database.ErrForbidden, nethttp.StatusForbidden, "forbidden"},
+	{database.ErrValidation, nethttp.StatusBadRequest, "validation"},
+	{database.ErrInternal, nethttp.StatusInternalServerError, "internal"},
+}
+
+// ErrorHandler writes every error returned by the handlers and middleware
+// as a JSON body with a machine-readable code, e.g.
+// {"code": "not_found", "message": "File wasn't found"}
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status, body := toResponse(err)
+	if status >= nethttp.StatusInternalServerError {
+		// NOTE: The message of database.Error is meant for the user, the cause is what's worth logging
+		if cause := errors.Unwrap(err); cause != nil {
+			c.Logger().Errorf("%v: %+v", err, cause)
+		} else {
+			c.Logger().Error(err)
+		}
+	}
+
+	if c.Request().Method == nethttp.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = c.JSON(status, body)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func toResponse(err error) (int, errorBody) {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message, ok := httpErr.Message.(string)
+		if !ok {
+			message = fmt.Sprint(httpErr.Message)
+		}
+		return httpErr.Code, errorBody{Code: statusCode(httpErr.Code), Message: message}
+	}
+
+	var dbErr *database.Error
+	if errors.As(err, &dbErr) {
+		for _, k := range kinds {
+			if errors.Is(dbErr, k.kind) {
+				return k.status, errorBody{Code: k.code, Message: internal.ToSentence(dbErr.Message)}
+			}
+		}
+	}
+
+	// NOTE: Unknown errors may carry details that shouldn't leak to the client
+	return nethttp.StatusInternalServerError, errorBody{Code: "internal", Message: "Internal server error"}
+}
+
+// NOTE: Codes of the errors that are created with echo.NewHTTPError are derived from the status,
+// so that "Invalid request body" (400) is "bad_request" and an unknown route (404) is "not_found"
+func statusCode(status int) string {
+	for _, k := range kinds {
+		if k.status == status && k.kind != database.ErrValidation {
+			return k.code
+		}
+	}
+	return strings.ReplaceAll(strings.ToLower(nethttp.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	dochttp "github.com/SergeyCherepiuk/docs/pkg/http"
+	"github.com/labstack/echo/v4"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+	}{
+		{"not found", database.NotFound("file wasn't found"), http.StatusNotFound, "not_found", "File wasn't found"},
+		{"conflict", database.Conflict("username already taken"), http.StatusConflict, "conflict", "Username already taken"},
+		{"forbidden", database.Forbidden("access wasn't found"), http.StatusForbidden, "forbidden", "Access wasn't found"},
+		{"validation", database.Validation("unknown sort key: %s", "size"), http.StatusBadRequest, "validation", "Unknown sort key: size"},
+		{"internal", database.Internal(errors.New("connection refused"), "failed to get the file"), http.StatusInternalServerError, "internal", "Failed to get the file"},
+		{"http error", echo.NewHTTPError(http.StatusBadRequest, "Invalid request body"), http.StatusBadRequest, "bad_request", "Invalid request body"},
+		{"unauthorized", echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found"), http.StatusUnauthorized, "unauthorized", "User wasn't found"},
+		{"unknown", errors.New("connection refused"), http.StatusInternalServerError, "internal", "Internal server error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = dochttp.ErrorHandler
+			e.GET("/", func(c echo.Context) error { return test.err })
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, rec.Code)
+			}
+
+			var body struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("failed to decode the body: %v", err)
+			}
+			if body.Code != test.code || body.Message != test.message {
+				t.Errorf("expected %s %q, got %s %q", test.code, test.message, body.Code, body.Message)
+			}
+		})
+	}
+}
+
+func TestErrorHandlerLogsCause(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = dochttp.ErrorHandler
+	e.GET("/", func(c echo.Context) error {
+		return database.Internal(errors.New("connection refused"), "failed to get the file")
+	})
+
+	var logs bytes.Buffer
+	e.Logger.SetOutput(&logs)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !strings.Contains(logs.String(), "connection refused") {
+		t.Errorf("expected the cause to be logged, got %q", logs.String())
+	}
+	if strings.Contains(rec.Body.String(), "connection refused") {
+		t.Errorf("expected the cause not to leak, got %q", rec.Body.String())
+	}
+}
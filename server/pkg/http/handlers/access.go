@@ -2,16 +2,21 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
-	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-type AccessHandler struct{}
+type AccessHandler struct {
+	Users   database.UserRepository
+	Files   database.FileRepository
+	Folders database.FolderRepository
+	Access  database.AccessRepository
+}
 
 func (h AccessHandler) Grant(c echo.Context) error {
 	user, ok := c.Get("user").(models.User)
@@ -25,12 +30,10 @@ func (h AccessHandler) Grant(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, internal.ToSentence(err.Error()))
+		return err
 	}
 
 	type RequestBody struct {
@@ -45,7 +48,7 @@ func (h AccessHandler) Grant(c echo.Context) error {
 
 	// TODO: Validation
 
-	receiver, err := neo4j.UserService.GetByUsername(ctx, sess, body.Receiver)
+	receiver, err := h.Users.GetByUsername(ctx, body.Receiver)
 	if err != nil {
 		return err
 	}
@@ -56,15 +59,16 @@ func (h AccessHandler) Grant(c echo.Context) error {
 		Level:    body.Level,
 	}
 
-	prevAccess, prevAccessErr := neo4j.AccessService.Get(ctx, sess, file, receiver)
-	if prevAccessErr != nil {
-		err = neo4j.AccessService.Grant(ctx, sess, file, access)
-	} else {
-		err = neo4j.AccessService.UpdateLevel(ctx, sess, file, prevAccess, access.Level)
+	prevAccess, err := h.Access.Get(ctx, file, receiver)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		err = h.Access.Grant(ctx, file, access)
+	case err == nil:
+		err = h.Access.UpdateLevel(ctx, file, prevAccess, access.Level)
 	}
 
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+		return err
 	}
 
 	return c.NoContent(http.StatusCreated)
@@ -77,20 +81,27 @@ func (h AccessHandler) GetAccesses(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	accesses, err := neo4j.AccessService.GetAccesses(ctx, sess, file)
+	page, err := getPageRequest(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	return c.JSON(http.StatusOK, accesses)
+	accesses, err := h.Access.GetAccesses(ctx, file, page)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Accesses []models.Access `json:"accesses"`
+		Next     string          `json:"next,omitempty"`
+	}{accesses.Items, accesses.Next}
+	return c.JSON(http.StatusOK, response)
 }
 
 func (h AccessHandler) Revoke(c echo.Context) error {
@@ -100,27 +111,143 @@ func (h AccessHandler) Revoke(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	username := c.Param("username")
+	user, err := h.Users.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	access, err := h.Access.Get(ctx, file, user)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Access.Revoke(ctx, file, access); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// NOTE: Access granted to a folder is inherited by everything it contains
+func (h AccessHandler) GrantForFolder(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	type RequestBody struct {
+		Receiver string `json:"receiver"`
+		Level    string `json:"level"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// TODO: Validation
+
+	receiver, err := h.Users.GetByUsername(ctx, body.Receiver)
+	if err != nil {
+		return err
+	}
+
+	access := models.Access{
+		Granter:  user.Username,
+		Receiver: receiver.Username,
+		Level:    body.Level,
+	}
+
+	prevAccess, err := h.Access.GetForFolder(ctx, folder, receiver)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		err = h.Access.GrantForFolder(ctx, folder, access)
+	case err == nil:
+		err = h.Access.UpdateLevelForFolder(ctx, folder, prevAccess, access.Level)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusCreated)
+}
+
+func (h AccessHandler) GetAccessesForFolder(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	page, err := getPageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	accesses, err := h.Access.GetAccessesForFolder(ctx, folder, page)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Accesses []models.Access `json:"accesses"`
+		Next     string          `json:"next,omitempty"`
+	}{accesses.Items, accesses.Next}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h AccessHandler) RevokeForFolder(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
 	username := c.Param("username")
-	user, err := neo4j.UserService.GetByUsername(ctx, sess, username)
+	user, err := h.Users.GetByUsername(ctx, username)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	access, err := neo4j.AccessService.Get(ctx, sess, file, user)
+	access, err := h.Access.GetForFolder(ctx, folder, user)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	if err := neo4j.AccessService.Revoke(ctx, sess, file, access); err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+	if err := h.Access.RevokeForFolder(ctx, folder, access); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusOK)
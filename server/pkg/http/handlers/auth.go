@@ -2,22 +2,31 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
-	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	Users      database.UserRepository
+	Sessions   database.SessionRepository
+	Policy     models.SessionPolicy
+	Pending    *PendingLogins
+	Transactor database.Transactor
+}
 
+// NOTE: Email is optional, without it the password can't be reset
 func (h AuthHandler) SignUp(c echo.Context) error {
 	type RequestBody struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		Email    string `json:"email"`
 	}
 
 	var body RequestBody
@@ -27,36 +36,37 @@ func (h AuthHandler) SignUp(c echo.Context) error {
 
 	// TODO: Validation
 
+	if body.Email != "" {
+		email, err := models.NormalizeEmail(body.Email)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid email")
+		}
+		body.Email = email
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash the password")
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
-
-	tx, err := sess.BeginTransaction(ctx)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start a database transaction")
-	}
 
 	user := models.User{
 		Username: body.Username,
 		Password: string(hashedPassword),
+		Email:    body.Email,
 	}
-	if err := neo4j.UserService.Create(ctx, tx, user); err != nil {
-		tx.Rollback(ctx)
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
-	}
-
-	session := models.NewWeekSession(user.Username)
-	if err := neo4j.SessionService.Create(ctx, tx, session); err != nil {
-		tx.Rollback(ctx)
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	session := h.newSession(c, user)
+	err = h.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+		if err := h.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		return h.Sessions.Create(ctx, session)
+	})
+	if err != nil {
+		return err
 	}
 
-	tx.Commit(ctx)
 	c.SetCookie(&http.Cookie{
 		Name:     "session",
 		Value:    session.Id,
@@ -78,21 +88,74 @@ func (h AuthHandler) Login(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	user, err := neo4j.UserService.GetByUsername(ctx, sess, body.Username)
+	user, err := h.Users.GetByUsername(ctx, body.Username)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Wrong password")
 	}
 
-	session := models.NewWeekSession(user.Username)
-	if err := neo4j.SessionService.Create(ctx, sess, session); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	// NOTE: With two-factor authentication the session is only created once the code is verified
+	if user.TOTPEnabled {
+		token, expiresAt, err := h.Pending.Add(user.Username)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to start the login")
+		}
+
+		response := struct {
+			TwoFactorRequired bool      `json:"twoFactorRequired"`
+			PendingToken      string    `json:"pendingToken"`
+			ExpiresAt         time.Time `json:"expiresAt"`
+		}{true, token, expiresAt}
+		return c.JSON(http.StatusOK, response)
+	}
+
+	return h.startSession(c, ctx, user)
+}
+
+// VerifyLogin is the second step of the login with two-factor authentication,
+// the code is either the one of the authenticator app or a recovery code
+func (h AuthHandler) VerifyLogin(c echo.Context) error {
+	type RequestBody struct {
+		PendingToken string `json:"pendingToken"`
+		Code         string `json:"code"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	username, err := h.Pending.Attempt(body.PendingToken)
+	if errors.Is(err, ErrLockedOut) {
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many wrong codes, try again later")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Login expired, log in again")
+	}
+
+	ctx := context.Background()
+
+	user, err := h.Users.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSecondFactor(ctx, h.Users, user, body.Code); err != nil {
+		return err
+	}
+	h.Pending.Complete(body.PendingToken)
+
+	return h.startSession(c, ctx, user)
+}
+
+func (h AuthHandler) startSession(c echo.Context, ctx context.Context, user models.User) error {
+	session := h.newSession(c, user)
+	if err := h.Sessions.Create(ctx, session); err != nil {
+		return err
 	}
 
 	c.SetCookie(&http.Cookie{
@@ -111,11 +174,12 @@ func (h AuthHandler) LogOut(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	if err := neo4j.SessionService.DeleteAll(ctx, sess, user); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	// NOTE: Only the current session is deleted, the other devices stay logged in
+	if id, ok := c.Get("session").(uuid.UUID); ok {
+		if err := h.Sessions.Delete(ctx, user, id); err != nil {
+			return err
+		}
 	}
 
 	c.SetCookie(&http.Cookie{
@@ -127,3 +191,11 @@ func (h AuthHandler) LogOut(c echo.Context) error {
 	})
 	return c.NoContent(http.StatusOK)
 }
+
+// NOTE: The device the session is created from, so that the user can tell their sessions apart
+func (h AuthHandler) newSession(c echo.Context, user models.User) models.Session {
+	session := h.Policy.NewSession(user.Username)
+	session.UserAgent = c.Request().UserAgent()
+	session.IP = c.RealIP()
+	return session
+}
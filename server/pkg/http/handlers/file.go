@@ -4,14 +4,19 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
-	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
+	"github.com/SergeyCherepiuk/docs/pkg/http/broadcast"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-type FileHandler struct{}
+type FileHandler struct {
+	Users      database.UserRepository
+	Files      database.FileRepository
+	Folders    database.FolderRepository
+	Transactor database.Transactor
+}
 
 func (h FileHandler) Create(c echo.Context) error {
 	user, ok := c.Get("user").(models.User)
@@ -20,24 +25,44 @@ func (h FileHandler) Create(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	user, err := neo4j.UserService.GetByUsername(ctx, sess, user.Username)
+	user, err := h.Users.GetByUsername(ctx, user.Username)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, internal.ToSentence(err.Error()))
+		return err
+	}
+
+	type RequestBody struct {
+		Name     string `json:"name"`
+		FolderId string `json:"folderId"`
 	}
 
-	var file models.File
-	if err := c.Bind(&file); err != nil {
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
 	}
-	file.Id = uuid.NewString()
 
 	// TODO: Validation
 
-	if err := neo4j.FileService.Create(ctx, sess, file, user); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	folder, err := getOwnedFolder(ctx, h.Folders, user, body.FolderId)
+	if err != nil {
+		return err
+	}
+
+	file := models.File{
+		Id:   uuid.NewString(),
+		Name: body.Name,
+	}
+	err = h.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+		if err := h.Files.Create(ctx, file, user); err != nil {
+			return err
+		}
+		if folder != nil {
+			return h.Folders.MoveFile(ctx, file, folder)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusCreated)
@@ -50,26 +75,59 @@ func (h FileHandler) Get(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	user, err := neo4j.FileService.GetOwner(ctx, sess, file)
+	user, err := h.Files.GetOwner(ctx, file)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
+	}
+
+	content, err := getContent(ctx, h.Files, file)
+	if err != nil {
+		return err
 	}
 
 	response := struct {
-		File  models.File `json:"file"`
-		Owner models.User `json:"owner"`
-	}{file, user}
+		File    models.File `json:"file"`
+		Owner   models.User `json:"owner"`
+		Content string      `json:"content"`
+	}{file, user, content.Text}
 	return c.JSON(http.StatusOK, response)
 }
 
+func (h FileHandler) GetContent(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	content, err := getContent(ctx, h.Files, file)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, content)
+}
+
+// NOTE: Content that is being edited live is newer than the stored one
+func getContent(ctx context.Context, files database.FileRepository, file models.File) (models.Content, error) {
+	if text, ok := broadcast.Content(file.Id); ok {
+		return models.Content{Text: text}, nil
+	}
+	return files.GetContent(ctx, file)
+}
+
 func (h FileHandler) GetAll(c echo.Context) error {
 	user, ok := c.Get("user").(models.User)
 	if !ok {
@@ -77,18 +135,99 @@ func (h FileHandler) GetAll(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	files, err := neo4j.FileService.GetAllForOwner(ctx, sess, user)
+	switch c.QueryParam("scope") {
+	case "", "owned":
+	case "shared":
+		return h.getAllShared(c, ctx, user)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown scope")
+	}
+
+	if c.QueryParam("view") == "tree" {
+		tree, err := h.Folders.GetTree(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		response := struct {
+			Owner models.User       `json:"owner"`
+			Tree  models.FolderTree `json:"tree"`
+		}{user, tree}
+		return c.JSON(http.StatusOK, response)
+	}
+
+	if c.QueryParam("view") == "folder" {
+		folder, err := getOwnedFolder(ctx, h.Folders, user, c.QueryParam("folder"))
+		if err != nil {
+			return err
+		}
+
+		var folders []models.Folder
+		var files []models.File
+		if folder != nil {
+			folders, files, err = h.Folders.GetChildren(ctx, *folder)
+		} else {
+			folders, files, err = h.Folders.GetRootChildren(ctx, user)
+		}
+		if err != nil {
+			return err
+		}
+
+		response := struct {
+			Owner   models.User     `json:"owner"`
+			Folder  *models.Folder  `json:"folder,omitempty"`
+			Folders []models.Folder `json:"folders"`
+			Files   []models.File   `json:"files"`
+		}{user, folder, folders, files}
+		return c.JSON(http.StatusOK, response)
+	}
+
+	page, err := getPageRequest(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, internal.ToSentence(err.Error()))
+		return err
+	}
+
+	filter, err := getFileFilter(c)
+	if err != nil {
+		return err
+	}
+
+	files, err := h.Files.GetAllForOwner(ctx, user, filter, page)
+	if err != nil {
+		return err
 	}
 
 	response := struct {
 		Owner models.User   `json:"owner"`
 		Files []models.File `json:"files"`
-	}{user, files}
+		Next  string        `json:"next,omitempty"`
+	}{user, files.Items, files.Next}
+	return c.JSON(http.StatusOK, response)
+}
+
+// NOTE: Files can be filtered by "level" and sorted by "sort" ("name" or "granted") query parameters
+func (h FileHandler) getAllShared(c echo.Context, ctx context.Context, user models.User) error {
+	level := c.QueryParam("level")
+	if level != "" && level != models.RAcess && level != models.RWAccess {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown access level")
+	}
+
+	page, err := getPageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	files, err := h.Files.GetAllSharedWith(ctx, user, level, page)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		User  models.User         `json:"user"`
+		Files []models.SharedFile `json:"files"`
+		Next  string              `json:"next,omitempty"`
+	}{user, files.Items, files.Next}
 	return c.JSON(http.StatusOK, response)
 }
 
@@ -101,18 +240,21 @@ func (u fileUpdates) HasName() bool {
 }
 
 func (h FileHandler) Update(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
 	var updates fileUpdates
@@ -123,8 +265,8 @@ func (h FileHandler) Update(c echo.Context) error {
 	if updates.HasName() {
 		// TODO: Validation
 
-		if err := neo4j.FileService.UpdateName(ctx, sess, file, updates.NewName); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+		if err := h.Files.UpdateName(ctx, file, updates.NewName, user); err != nil {
+			return err
 		}
 		return c.NoContent(http.StatusOK)
 	}
@@ -132,6 +274,76 @@ func (h FileHandler) Update(c echo.Context) error {
 	return c.NoContent(http.StatusBadRequest)
 }
 
+func (h FileHandler) UpdateContent(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var content models.Content
+	if err := c.Bind(&content); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := broadcast.Replace(file.Id, content.Text, user.Username); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// NOTE: Empty folder id moves the file to the root
+func (h FileHandler) Move(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	type RequestBody struct {
+		FolderId string `json:"folderId"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	folder, err := getOwnedFolder(ctx, h.Folders, user, body.FolderId)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Folders.MoveFile(ctx, file, folder); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 func (h FileHandler) Delete(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -139,16 +351,14 @@ func (h FileHandler) Delete(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
+	file, err := h.Files.GetById(ctx, id)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
 	}
 
-	if err := neo4j.FileService.Delete(ctx, sess, file); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	if err := h.Files.Delete(ctx, file); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusOK)
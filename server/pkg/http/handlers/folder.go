@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type FolderHandler struct {
+	Folders database.FolderRepository
+}
+
+func (h FolderHandler) Create(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	type RequestBody struct {
+		Name     string `json:"name"`
+		ParentId string `json:"parentId"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// TODO: Validation
+
+	ctx := context.Background()
+
+	parent, err := getOwnedFolder(ctx, h.Folders, user, body.ParentId)
+	if err != nil {
+		return err
+	}
+
+	folder := models.Folder{
+		Id:   uuid.NewString(),
+		Name: body.Name,
+	}
+	if err := h.Folders.Create(ctx, folder, parent, user); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, folder)
+}
+
+func (h FolderHandler) GetChildren(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	folders, files, err := h.Folders.GetChildren(ctx, folder)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Folder  models.Folder   `json:"folder"`
+		Folders []models.Folder `json:"folders"`
+		Files   []models.File   `json:"files"`
+	}{folder, folders, files}
+	return c.JSON(http.StatusOK, response)
+}
+
+type folderUpdates struct {
+	NewName string `json:"newName"`
+}
+
+func (u folderUpdates) HasName() bool {
+	return u.NewName != ""
+}
+
+func (h FolderHandler) Update(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var updates folderUpdates
+	if err := c.Bind(&updates); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if updates.HasName() {
+		// TODO: Validation
+
+		if err := h.Folders.UpdateName(ctx, folder, updates.NewName); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	return c.NoContent(http.StatusBadRequest)
+}
+
+// NOTE: Empty parent id moves the folder to the root
+func (h FolderHandler) Move(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	type RequestBody struct {
+		ParentId string `json:"parentId"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	parent, err := getOwnedFolder(ctx, h.Folders, user, body.ParentId)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Folders.Move(ctx, folder, parent); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h FolderHandler) Delete(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	ctx := context.Background()
+
+	folder, err := h.Folders.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Folders.Delete(ctx, folder); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// NOTE: Empty id stands for the root, for which nil is returned
+func getOwnedFolder(ctx context.Context, folders database.FolderRepository, user models.User, id string) (*models.Folder, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	folderId, err := uuid.Parse(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	folder, err := folders.GetById(ctx, folderId)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := folders.GetOwner(ctx, folder)
+	if err != nil || owner.Username != user.Username {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Owner access required")
+	}
+
+	return &folder, nil
+}
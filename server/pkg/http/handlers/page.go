@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/labstack/echo/v4"
+)
+
+// NOTE: Listings are paginated by "limit", "after" (the cursor of the previous page)
+// and "sort" query parameters, cursor is only valid for the sort it was made for
+func getPageRequest(c echo.Context) (database.PageRequest, error) {
+	page := database.PageRequest{
+		After:  c.QueryParam("after"),
+		SortBy: c.QueryParam("sort"),
+	}
+
+	if c.QueryParam("limit") != "" {
+		limit, err := strconv.Atoi(c.QueryParam("limit"))
+		if err != nil || limit <= 0 || limit > database.MaxPageLimit {
+			return page, echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// NOTE: Owned files are filtered by "editedBy" and by the creation and update time,
+// bounds ("createdAfter", "createdBefore", "updatedAfter", "updatedBefore") are in RFC 3339
+func getFileFilter(c echo.Context) (database.FileFilter, error) {
+	filter := database.FileFilter{LastEditedBy: c.QueryParam("editedBy")}
+
+	bounds := map[string]*time.Time{
+		"createdAfter":  &filter.CreatedAfter,
+		"createdBefore": &filter.CreatedBefore,
+		"updatedAfter":  &filter.UpdatedAfter,
+		"updatedBefore": &filter.UpdatedBefore,
+	}
+	for param, bound := range bounds {
+		if c.QueryParam(param) == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, c.QueryParam(param))
+		if err != nil {
+			return filter, echo.NewHTTPError(http.StatusBadRequest, "Invalid time in "+param)
+		}
+		*bound = t
+	}
+
+	return filter, nil
+}
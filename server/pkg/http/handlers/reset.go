@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordResetTTL = time.Hour
+
+type PasswordResetHandler struct {
+	Users      database.UserRepository
+	Resets     database.PasswordResetRepository
+	Sessions   database.SessionRepository
+	Mailer     mail.Mailer
+	Transactor database.Transactor
+
+	// NOTE: Page of the client the password is set on, the token is added as the "token" query parameter
+	URL string
+}
+
+// Request mails the reset link to the user found by the username or the email. The response is
+// the same whether the user was found or not, so that it can't be used to find out who has an account.
+// Users without an email can't reset their password
+func (h PasswordResetHandler) Request(c echo.Context) error {
+	type RequestBody struct {
+		Login string `json:"login"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	login := strings.TrimSpace(body.Login)
+	logger := c.Logger()
+
+	// NOTE: The reset is created and mailed after the response is sent, otherwise
+	// it would take longer for the existing users and give them away by the timing
+	go func() {
+		if err := h.request(context.Background(), login); err != nil {
+			if cause := errors.Unwrap(err); cause != nil {
+				logger.Errorf("failed to request the password reset: %v: %+v", err, cause)
+			} else {
+				logger.Errorf("failed to request the password reset: %v", err)
+			}
+		}
+	}()
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+func (h PasswordResetHandler) request(ctx context.Context, login string) error {
+	user, err := h.findUser(ctx, login)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && user.Email == "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	reset, secret, err := models.NewPasswordReset(user.Username, passwordResetTTL)
+	if err != nil {
+		return fmt.Errorf("failed to create the password reset: %w", err)
+	}
+
+	if err := h.Resets.Create(ctx, reset); err != nil {
+		return err
+	}
+
+	if err := h.Mailer.Send(ctx, h.message(user, secret)); err != nil {
+		return fmt.Errorf("failed to mail the password reset: %w", err)
+	}
+	return nil
+}
+
+func (h PasswordResetHandler) findUser(ctx context.Context, login string) (models.User, error) {
+	if !strings.Contains(login, "@") {
+		return h.Users.GetByUsername(ctx, login)
+	}
+
+	email, err := models.NormalizeEmail(login)
+	if err != nil {
+		return models.User{}, database.NotFound("user wasn't found")
+	}
+	return h.Users.GetByEmail(ctx, email)
+}
+
+func (h PasswordResetHandler) message(user models.User, secret string) mail.Message {
+	link := h.URL + "?" + url.Values{"token": {secret}}.Encode()
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nsomeone asked to reset the password of your account. Set a new one here:\n\n%s\n\n"+
+				"The link expires in %s and can be used once. If it wasn't you, ignore this email, your password stays the same.\n",
+			user.Username, link, passwordResetTTL,
+		),
+	}
+}
+
+// Confirm sets the new password and logs the user out everywhere, the old password might be known to someone else
+func (h PasswordResetHandler) Confirm(c echo.Context) error {
+	type RequestBody struct {
+		Token             string `json:"token"`
+		NewPassword       string `json:"newPassword"`
+		NewPasswordRepeat string `json:"newPasswordRepeat"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	// TODO: Validation
+
+	if body.NewPassword == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "New password is required")
+	}
+	if body.NewPassword != body.NewPasswordRepeat {
+		return echo.NewHTTPError(http.StatusBadRequest, "New passwords aren't the same")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(body.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash the password")
+	}
+
+	ctx := context.Background()
+
+	err = h.Transactor.InTransaction(ctx, func(ctx context.Context) error {
+		user, err := h.Resets.Use(ctx, models.HashToken(body.Token))
+		if errors.Is(err, database.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Reset link is invalid or expired")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := h.Users.UpdatePassword(ctx, user, string(hashedPassword)); err != nil {
+			return err
+		}
+		return h.Sessions.DeleteAll(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
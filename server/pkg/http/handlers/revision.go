@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/diff"
+	"github.com/SergeyCherepiuk/docs/pkg/http/broadcast"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type RevisionHandler struct {
+	Files     database.FileRepository
+	Revisions database.RevisionRepository
+}
+
+func (h RevisionHandler) GetAll(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := h.Revisions.GetAll(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+func (h RevisionHandler) Get(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	revisionId, err := uuid.Parse(c.Param("revision"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid revision id")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	revision, err := h.Revisions.GetById(ctx, file, revisionId)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, revision)
+}
+
+// NOTE: Restored content is saved as a new revision made by the current user
+func (h RevisionHandler) Restore(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	revisionId, err := uuid.Parse(c.Param("revision"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid revision id")
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	revision, err := h.Revisions.GetById(ctx, file, revisionId)
+	if err != nil {
+		return err
+	}
+
+	if err := broadcast.Replace(file.Id, revision.Content, user.Username); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+const currentRevision = "current"
+
+// NOTE: Revisions that differ by more edits are shown as replaced as a whole,
+// it keeps the diff within O((N+M)*maxDiffEdits) time and O(maxDiffEdits^2) memory
+const maxDiffEdits = 2000
+
+// NOTE: Compares revision "from" with revision "to", or with
+// the current content if "to" is omitted or equals "current"
+func (h RevisionHandler) Diff(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "unified" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown diff format")
+	}
+
+	granularity := c.QueryParam("granularity")
+	if granularity == "" {
+		granularity = "line"
+	}
+	if granularity != "line" && granularity != "word" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown diff granularity")
+	}
+
+	contextLines := 3
+	if c.QueryParam("context") != "" {
+		if contextLines, err = strconv.Atoi(c.QueryParam("context")); err != nil || contextLines < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid number of context lines")
+		}
+	}
+
+	ctx := context.Background()
+
+	file, err := h.Files.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	fromId, err := uuid.Parse(c.QueryParam("from"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid revision id")
+	}
+
+	from, err := h.Revisions.GetById(ctx, file, fromId)
+	if err != nil {
+		return err
+	}
+
+	to := models.Revision{Id: currentRevision}
+	if c.QueryParam("to") != "" && c.QueryParam("to") != currentRevision {
+		toId, err := uuid.Parse(c.QueryParam("to"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid revision id")
+		}
+
+		if to, err = h.Revisions.GetById(ctx, file, toId); err != nil {
+			return err
+		}
+	} else {
+		content, err := getContent(ctx, h.Files, file)
+		if err != nil {
+			return err
+		}
+		to.Content = content.Text
+	}
+
+	if granularity == "word" {
+		edits := diff.Words(from.Content, to.Content, maxDiffEdits)
+		if format == "unified" {
+			return c.String(http.StatusOK, diff.Inline(edits))
+		}
+
+		response := struct {
+			From  string      `json:"from"`
+			To    string      `json:"to"`
+			Edits []diff.Edit `json:"edits"`
+		}{from.Id, to.Id, edits}
+		return c.JSON(http.StatusOK, response)
+	}
+
+	hunks := diff.Hunks(diff.Lines(from.Content, to.Content, maxDiffEdits), contextLines)
+	if format == "unified" {
+		return c.String(http.StatusOK, diff.Unified(from.Id, to.Id, hunks))
+	}
+
+	response := struct {
+		From  string      `json:"from"`
+		To    string      `json:"to"`
+		Hunks []diff.Hunk `json:"hunks"`
+	}{from.Id, to.Id, hunks}
+	return c.JSON(http.StatusOK, response)
+}
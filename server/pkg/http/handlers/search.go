@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/labstack/echo/v4"
+)
+
+type SearchHandler struct {
+	Search database.SearchRepository
+}
+
+// NOTE: Searches the files the user owns or has access to by the "q" query parameter,
+// "limit" caps the number of results
+func (h SearchHandler) Files(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Empty search query")
+	}
+
+	limit := 0
+	if c.QueryParam("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(c.QueryParam("limit")); err != nil || limit <= 0 || limit > database.MaxSearchLimit {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+	}
+
+	results, err := h.Search.Search(context.Background(), user, query, limit)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Query   string                `json:"query"`
+		Results []models.SearchResult `json:"results"`
+	}{query, results}
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type SessionHandler struct {
+	Sessions database.SessionRepository
+}
+
+func (h SessionHandler) GetAll(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	sessions, err := h.Sessions.GetAll(context.Background(), user)
+	if err != nil {
+		return err
+	}
+
+	current, _ := c.Get("session").(uuid.UUID)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == current.String()
+	}
+
+	response := struct {
+		Sessions []models.Session `json:"sessions"`
+	}{sessions}
+	return c.JSON(http.StatusOK, response)
+}
+
+// NOTE: Revoking the current session logs the user out, same as logout does
+func (h SessionHandler) Revoke(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid session id")
+	}
+
+	if err := h.Sessions.Delete(context.Background(), user, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// RevokeOthers logs out every device of the user but the one making the request
+func (h SessionHandler) RevokeOthers(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	current, ok := c.Get("session").(uuid.UUID)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Session wasn't found")
+	}
+
+	if err := h.Sessions.DeleteAllExcept(context.Background(), user, current); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type TokenHandler struct {
+	Tokens database.TokenRepository
+}
+
+// NOTE: The secret is only in the response of this request, it can't be recovered later
+func (h TokenHandler) Create(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	type RequestBody struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if body.Name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Token name is required")
+	}
+	if len(body.Scopes) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "At least one scope is required")
+	}
+	for _, scope := range body.Scopes {
+		if !models.IsScope(scope) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Unknown scope: "+scope)
+		}
+	}
+	if !body.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiry must be in the future")
+	}
+
+	token, secret, err := models.NewToken(user.Username, body.Name, body.Scopes, body.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Tokens.Create(context.Background(), token); err != nil {
+		return err
+	}
+
+	response := struct {
+		Token  models.Token `json:"token"`
+		Secret string       `json:"secret"`
+	}{token, secret}
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h TokenHandler) GetAll(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	tokens, err := h.Tokens.GetAll(context.Background(), user)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Tokens []models.Token `json:"tokens"`
+	}{tokens}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h TokenHandler) Revoke(c echo.Context) error {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid token id")
+	}
+
+	if err := h.Tokens.Revoke(context.Background(), user, id); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/totp"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	totpIssuer        = "Docs"
+	recoveryCodeCount = 10
+
+	// NOTE: A pending login is dropped after this many wrong codes, the password has to be entered again
+	maxPendingAttempts = 5
+
+	// NOTE: New pending logins don't give new attempts, the user is locked out of the second step
+	// after this many attempts across all of their pending logins. Attempts are forgotten once
+	// there were none for the lockout duration
+	maxUserAttempts = 10
+	lockoutDuration = 15 * time.Minute
+)
+
+var (
+	ErrLoginExpired = errors.New("login expired")
+	ErrLockedOut    = errors.New("too many attempts")
+)
+
+// PendingLogins keeps the logins that passed the password check and wait for the second factor.
+// They are kept in memory, same as the documents being edited are, and expire after the TTL.
+// It also limits the attempts of the signed in users confirming an action with the second factor
+type PendingLogins struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	logins   map[string]pendingLogin
+	attempts map[string]userAttempts
+}
+
+type pendingLogin struct {
+	username  string
+	expiresAt time.Time
+	attempts  int
+}
+
+type userAttempts struct {
+	count int
+	until time.Time
+}
+
+func (a userAttempts) locked() bool {
+	return a.count >= maxUserAttempts && a.until.After(time.Now())
+}
+
+func NewPendingLogins(ttl time.Duration) *PendingLogins {
+	return &PendingLogins{
+		ttl:      ttl,
+		logins:   make(map[string]pendingLogin),
+		attempts: make(map[string]userAttempts),
+	}
+}
+
+// Add returns the token the second step of the login is made with
+func (p *PendingLogins) Add(username string) (token string, expiresAt time.Time, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, err
+	}
+	token = base64.RawURLEncoding.EncodeToString(random)
+	expiresAt = time.Now().Add(p.ttl).In(time.UTC)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for t, login := range p.logins {
+		if !login.expiresAt.After(time.Now()) {
+			delete(p.logins, t)
+		}
+	}
+	for u, attempts := range p.attempts {
+		if !attempts.until.After(time.Now()) {
+			delete(p.attempts, u)
+		}
+	}
+	p.logins[token] = pendingLogin{username: username, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Attempt returns the username of the pending login, every call counts as an attempt
+// of both the pending login and the user until the login is completed
+func (p *PendingLogins) Attempt(token string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.logins[token]
+	if !ok || !login.expiresAt.After(time.Now()) || login.attempts >= maxPendingAttempts {
+		delete(p.logins, token)
+		return "", ErrLoginExpired
+	}
+
+	if err := p.attempt(login.username); err != nil {
+		return "", err
+	}
+
+	login.attempts++
+	p.logins[token] = login
+	return login.username, nil
+}
+
+// AttemptUser counts an attempt of the signed in user, they share the limit with the attempts of the logins
+func (p *PendingLogins) AttemptUser(username string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.attempt(username)
+}
+
+// ResetUser starts the attempts of the user over once the code is verified
+func (p *PendingLogins) ResetUser(username string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.attempts, username)
+}
+
+// NOTE: Must be called with the lock held
+func (p *PendingLogins) attempt(username string) error {
+	attempts := p.attempts[username]
+	if attempts.locked() {
+		return ErrLockedOut
+	}
+	if !attempts.until.After(time.Now()) {
+		attempts = userAttempts{}
+	}
+	p.attempts[username] = userAttempts{count: attempts.count + 1, until: time.Now().Add(lockoutDuration)}
+	return nil
+}
+
+// Complete removes the pending login once the code is verified, the attempts of the user start over
+func (p *PendingLogins) Complete(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if login, ok := p.logins[token]; ok {
+		delete(p.attempts, login.username)
+	}
+	delete(p.logins, token)
+}
+
+// checkSecondFactor accepts either the code of the authenticator app or one of the recovery codes,
+// either of them can only be used once
+func checkSecondFactor(ctx context.Context, users database.UserRepository, user models.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	var err error
+	if step, ok := totp.Match(user.TOTPSecret, code, time.Now()); ok {
+		err = users.UseTOTPStep(ctx, user, step)
+	} else {
+		err = users.UseRecoveryCode(ctx, user, totp.HashRecoveryCode(code))
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Wrong code")
+	}
+	return err
+}
+
+// NOTE: Otherwise a stolen session could be used to guess the code without a limit
+func checkLimitedSecondFactor(ctx context.Context, users database.UserRepository, pending *PendingLogins, user models.User, code string) error {
+	if err := pending.AttemptUser(user.Username); err != nil {
+		return echo.NewHTTPError(http.StatusTooManyRequests, "Too many wrong codes, try again later")
+	}
+	if err := checkSecondFactor(ctx, users, user, code); err != nil {
+		return err
+	}
+	pending.ResetUser(user.Username)
+	return nil
+}
+
+func newRecoveryCodes() (codes []string, hashes []string, err error) {
+	codes, err = totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate the recovery codes")
+	}
+
+	hashes = make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+type TwoFactorHandler struct {
+	Users   database.UserRepository
+	Pending *PendingLogins
+}
+
+// NOTE: Enrolment only stores the secret, two-factor authentication is enabled
+// once the first code of the authenticator app is confirmed
+func (h TwoFactorHandler) Enrol(c echo.Context) error {
+	user, err := getCurrentUser(c, h.Users)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate the secret")
+	}
+
+	if err := h.Users.UpdateTwoFactor(context.Background(), user, secret, false, nil); err != nil {
+		return err
+	}
+
+	response := struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}{secret, totp.URI(totpIssuer, user.Username, secret)}
+	return c.JSON(http.StatusOK, response)
+}
+
+// NOTE: Recovery codes are only in the response of this request, they can't be recovered later
+func (h TwoFactorHandler) Enable(c echo.Context) error {
+	user, err := getCurrentUser(c, h.Users)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabled {
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication wasn't enrolled")
+	}
+
+	code, err := bindCode(c)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Match(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wrong code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err := h.Users.UseTOTPStep(ctx, user, step); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Wrong code")
+		}
+		return err
+	}
+	if err := h.Users.UpdateTwoFactor(ctx, user, user.TOTPSecret, true, hashes); err != nil {
+		return err
+	}
+
+	response := struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}{codes}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h TwoFactorHandler) Disable(c echo.Context) error {
+	user, err := getEnabledUser(c, h.Users)
+	if err != nil {
+		return err
+	}
+
+	code, err := bindCode(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err := checkLimitedSecondFactor(ctx, h.Users, h.Pending, user, code); err != nil {
+		return err
+	}
+
+	if err := h.Users.UpdateTwoFactor(ctx, user, "", false, nil); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// NOTE: New recovery codes replace all the previous ones, used or not
+func (h TwoFactorHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	user, err := getEnabledUser(c, h.Users)
+	if err != nil {
+		return err
+	}
+
+	code, err := bindCode(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err := checkLimitedSecondFactor(ctx, h.Users, h.Pending, user, code); err != nil {
+		return err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return err
+	}
+
+	if err := h.Users.UpdateTwoFactor(ctx, user, user.TOTPSecret, true, hashes); err != nil {
+		return err
+	}
+
+	response := struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}{codes}
+	return c.JSON(http.StatusOK, response)
+}
+
+// NOTE: The user in the context isn't guaranteed to have the two-factor fields, it's read again
+func getCurrentUser(c echo.Context, users database.UserRepository) (models.User, error) {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return models.User{}, echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+	return users.GetByUsername(context.Background(), user.Username)
+}
+
+func getEnabledUser(c echo.Context, users database.UserRepository) (models.User, error) {
+	user, err := getCurrentUser(c, users)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !user.TOTPEnabled {
+		return models.User{}, echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication isn't enabled")
+	}
+	return user, nil
+}
+
+func bindCode(c echo.Context) (string, error) {
+	type RequestBody struct {
+		Code string `json:"code"`
+	}
+
+	var body RequestBody
+	if err := c.Bind(&body); err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+	if body.Code == "" {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Code is required")
+	}
+	return body.Code, nil
+}
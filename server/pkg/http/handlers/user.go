@@ -2,27 +2,35 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
-	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-type UserHandler struct{}
+type UserHandler struct {
+	Users   database.UserRepository
+	Pending *PendingLogins
+	Mailer  mail.Mailer
+}
 
 func (h UserHandler) GetByUsername(c echo.Context) error {
 	username := c.Param("username")
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	user, err := neo4j.UserService.GetByUsername(ctx, sess, username)
+	user, err := h.Users.GetByUsername(ctx, username)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return err
+	}
+
+	// NOTE: Email is only shown to the user themselves
+	if current, ok := c.Get("user").(models.User); !ok || current.Username != user.Username {
+		user.Email = ""
 	}
 
 	return c.JSON(http.StatusOK, user)
@@ -30,15 +38,21 @@ func (h UserHandler) GetByUsername(c echo.Context) error {
 
 type userUpdates struct {
 	NewUsername       string `json:"newUsername"`
+	NewEmail          string `json:"newEmail"`
 	OldPassword       string `json:"oldPassword"`
 	NewPassword       string `json:"newPassword"`
 	NewPasswordRepeat string `json:"newPasswordRepeat"`
+	Code              string `json:"code"`
 }
 
 func (u userUpdates) hasUsername() bool {
 	return u.NewUsername != ""
 }
 
+func (u userUpdates) hasEmail() bool {
+	return u.NewEmail != ""
+}
+
 func (u userUpdates) hasPassword() bool {
 	return u.OldPassword != "" && u.NewPassword != "" && u.NewPasswordRepeat != ""
 }
@@ -50,8 +64,6 @@ func (h UserHandler) Update(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
 	var updates userUpdates
 	if c.Bind(&updates) != nil {
@@ -61,8 +73,47 @@ func (h UserHandler) Update(c echo.Context) error {
 	if updates.hasUsername() {
 		// TODO: Validation
 
-		if err := neo4j.UserService.UpdateUsername(ctx, sess, user, updates.NewUsername); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+		if err := h.Users.UpdateUsername(ctx, user, updates.NewUsername); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusOK)
+	}
+
+	// NOTE: Email is where the password reset links go, so changing it takes the current password,
+	// the code of the second factor if it's enabled, and the current email is notified beforehand
+	if updates.hasEmail() {
+		email, err := models.NormalizeEmail(updates.NewEmail)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid email")
+		}
+
+		user, err := h.Users.GetByUsername(ctx, user.Username)
+		if err != nil {
+			return err
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(updates.OldPassword)); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Wrong password")
+		}
+
+		if user.TOTPEnabled {
+			if updates.Code == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Code is required")
+			}
+			if err := checkLimitedSecondFactor(ctx, h.Users, h.Pending, user, updates.Code); err != nil {
+				return err
+			}
+		}
+
+		if user.Email != "" && user.Email != email {
+			if err := h.Mailer.Send(ctx, emailChangedMessage(user, email)); err != nil {
+				c.Logger().Errorf("failed to mail the email change: %v", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to notify the current email")
+			}
+		}
+
+		if err := h.Users.UpdateEmail(ctx, user, email); err != nil {
+			return err
 		}
 		return c.NoContent(http.StatusOK)
 	}
@@ -70,9 +121,9 @@ func (h UserHandler) Update(c echo.Context) error {
 	if updates.hasPassword() {
 		// TODO: Validation
 
-		user, err := neo4j.UserService.GetByUsername(ctx, sess, user.Username)
+		user, err := h.Users.GetByUsername(ctx, user.Username)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+			return err
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(updates.OldPassword)); err != nil {
@@ -87,8 +138,8 @@ func (h UserHandler) Update(c echo.Context) error {
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash the password")
 		}
-		if err := neo4j.UserService.UpdatePassword(ctx, sess, user, string(hashedPassword)); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+		if err := h.Users.UpdatePassword(ctx, user, string(hashedPassword)); err != nil {
+			return err
 		}
 		return c.NoContent(http.StatusOK)
 	}
@@ -96,6 +147,18 @@ func (h UserHandler) Update(c echo.Context) error {
 	return c.NoContent(http.StatusBadRequest)
 }
 
+func emailChangedMessage(user models.User, newEmail string) mail.Message {
+	return mail.Message{
+		To:      user.Email,
+		Subject: "Your email was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nthe email of your account is being changed to %s, the password reset links will be sent there.\n\n"+
+				"If it wasn't you, reset your password and log out of the other devices right away.\n",
+			user.Username, newEmail,
+		),
+	}
+}
+
 func (h UserHandler) Delete(c echo.Context) error {
 	user, ok := c.Get("user").(models.User)
 	if !ok {
@@ -103,11 +166,9 @@ func (h UserHandler) Delete(c echo.Context) error {
 	}
 
 	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
 
-	if err := neo4j.UserService.Delete(ctx, sess, user); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, internal.ToSentence(err.Error()))
+	if err := h.Users.Delete(ctx, user); err != nil {
+		return err
 	}
 
 	return c.NoContent(http.StatusOK)
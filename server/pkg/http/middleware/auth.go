@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
@@ -14,15 +18,21 @@ const (
 	REQUIRE_NO_SESSION = 2
 )
 
-func RequireSession() echo.MiddlewareFunc {
-	return checkSession(REQUIRE_SESSION)
+type AuthMiddleware struct {
+	Sessions database.SessionRepository
+	Tokens   database.TokenRepository
+	Policy   models.SessionPolicy
 }
 
-func RequireNoSession() echo.MiddlewareFunc {
-	return checkSession(REQUIRE_NO_SESSION)
+func (m AuthMiddleware) RequireSession() echo.MiddlewareFunc {
+	return m.checkSession(REQUIRE_SESSION)
 }
 
-func checkSession(flag int) echo.MiddlewareFunc {
+func (m AuthMiddleware) RequireNoSession() echo.MiddlewareFunc {
+	return m.checkSession(REQUIRE_NO_SESSION)
+}
+
+func (m AuthMiddleware) checkSession(flag int) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		unauthorized := func(c echo.Context) error {
 			return c.NoContent(http.StatusUnauthorized)
@@ -42,27 +52,95 @@ func checkSession(flag int) echo.MiddlewareFunc {
 		}
 
 		return func(c echo.Context) error {
-			cookie, err := c.Cookie("session")
+			user, err := m.authenticate(c)
 			if err != nil {
 				return onSessionAbsent(c)
 			}
 
-			id, err := uuid.Parse(cookie.Value)
-			if err != nil {
-				return onSessionAbsent(c)
-			}
+			c.Set("user", user)
+			return onSessionPresent(c)
+		}
+	}
+}
 
-			ctx := context.Background()
-			sess := neo4j.NewSession(ctx)
-			defer sess.Close(ctx)
+var errNoCredentials = errors.New("no credentials")
 
-			user, err := neo4j.SessionService.Check(ctx, sess, id)
-			if err != nil {
-				return onSessionAbsent(c)
+// NOTE: Requests are authenticated either by the API token in the "Authorization: Bearer <token>" header
+// or by the session cookie. Token or session id of the request is stored in the context, next to the user
+func (m AuthMiddleware) authenticate(c echo.Context) (models.User, error) {
+	if header := c.Request().Header.Get(echo.HeaderAuthorization); header != "" {
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || secret == "" {
+			return models.User{}, errNoCredentials
+		}
+
+		user, token, err := m.Tokens.Check(context.Background(), models.HashToken(secret))
+		if err != nil {
+			return models.User{}, err
+		}
+
+		c.Set("token", token)
+		return user, nil
+	}
+
+	cookie, err := c.Cookie("session")
+	if err != nil {
+		return models.User{}, err
+	}
+
+	id, err := uuid.Parse(cookie.Value)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, session, err := m.Sessions.Check(context.Background(), id)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	m.refresh(c, session)
+	c.Set("session", id)
+	return user, nil
+}
+
+// NOTE: Sessions are extended at most once per refresh interval, so that not every request
+// writes to the database. Failing to extend the session doesn't fail the request, it's still valid
+func (m AuthMiddleware) refresh(c echo.Context, session models.Session) {
+	refreshed, ok := m.Policy.Refresh(session, time.Now())
+	if !ok {
+		return
+	}
+
+	if err := m.Sessions.Refresh(context.Background(), refreshed); err != nil {
+		c.Logger().Errorf("failed to refresh the session: %v", err)
+	}
+}
+
+// RequireScope lets the requests made with an API token through only if the token has the scope.
+// Requests made with the session cookie aren't limited by the scopes
+func (m AuthMiddleware) RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasScope(c, scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "Token doesn't have '"+scope+"' scope")
 			}
+			return next(c)
+		}
+	}
+}
 
-			c.Set("user", user)
-			return onSessionPresent(c)
+// RequireNoToken is for the routes that manage the account itself (e.g. the tokens),
+// they can only be used with the session cookie
+func (m AuthMiddleware) RequireNoToken(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, ok := c.Get("token").(models.Token); ok {
+			return echo.NewHTTPError(http.StatusForbidden, "API tokens can't be used here")
 		}
+		return next(c)
 	}
 }
+
+func HasScope(c echo.Context, scope string) bool {
+	token, ok := c.Get("token").(models.Token)
+	return !ok || token.HasScope(scope)
+}
@@ -4,73 +4,65 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/SergeyCherepiuk/docs/pkg/database"
 	"github.com/SergeyCherepiuk/docs/pkg/database/models"
-	"github.com/SergeyCherepiuk/docs/pkg/database/neo4j"
-	"github.com/SergeyCherepiuk/docs/pkg/http/internal"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-const ownerAccess = "O"
+type AccessMiddleware struct {
+	Access database.AccessRepository
+}
 
-func RequireAtLeastRAccess(next echo.HandlerFunc) echo.HandlerFunc {
+func (m AccessMiddleware) RequireAtLeastRAccess(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		level, err := getAccessLevel(c)
+		level, err := m.getAccessLevel(c)
 		if err != nil {
 			return err
 		}
 
-		if !isAtLeastRAccess(level) {
-			return echo.NewHTTPError(http.StatusUnauthorized, "At least 'read' access required")
+		if !models.IsAtLeastRAccess(level) {
+			return echo.NewHTTPError(http.StatusForbidden, "At least 'read' access required")
 		}
 
+		c.Set("access", level)
 		return next(c)
 	}
 }
 
-func RequireAtLeastRWAccess(next echo.HandlerFunc) echo.HandlerFunc {
+func (m AccessMiddleware) RequireAtLeastRWAccess(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		level, err := getAccessLevel(c)
+		level, err := m.getAccessLevel(c)
 		if err != nil {
 			return err
 		}
 
-		if !isAtLeastRWAccess(level) {
-			return echo.NewHTTPError(http.StatusUnauthorized, "At least 'read&write' access required")
+		if !models.IsAtLeastRWAccess(level) {
+			return echo.NewHTTPError(http.StatusForbidden, "At least 'read&write' access required")
 		}
 
+		c.Set("access", level)
 		return next(c)
 	}
 }
 
-func RequireOwnerAccess(next echo.HandlerFunc) echo.HandlerFunc {
+func (m AccessMiddleware) RequireOwnerAccess(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		level, err := getAccessLevel(c)
+		level, err := m.getAccessLevel(c)
 		if err != nil {
 			return err
 		}
 
-		if !isOwnerAccess(level) {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Owner access required")
+		if !models.IsOwnerAccess(level) {
+			return echo.NewHTTPError(http.StatusForbidden, "Owner access required")
 		}
 
+		c.Set("access", level)
 		return next(c)
 	}
 }
 
-func isAtLeastRAccess(level string) bool {
-	return level == models.RAcess || isAtLeastRWAccess(level)
-}
-
-func isAtLeastRWAccess(level string) bool {
-	return level == models.RWAccess || isOwnerAccess(level)
-}
-
-func isOwnerAccess(level string) bool {
-	return level == ownerAccess
-}
-
-func getAccessLevel(c echo.Context) (string, error) {
+func (m AccessMiddleware) getAccessLevel(c echo.Context) (string, error) {
 	user, ok := c.Get("user").(models.User)
 	if !ok {
 		return "", echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
@@ -81,24 +73,10 @@ func getAccessLevel(c echo.Context) (string, error) {
 		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid file id")
 	}
 
-	ctx := context.Background()
-	sess := neo4j.NewSession(ctx)
-	defer sess.Close(ctx)
-
-	file, err := neo4j.FileService.GetById(ctx, sess, id)
-	if err != nil {
-		return "", echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
-	}
-
-	owner, err := neo4j.FileService.GetOwner(ctx, sess, file)
-	if err == nil && owner.Username == user.Username {
-		return ownerAccess, nil
-	}
-
-	access, err := neo4j.AccessService.Get(ctx, sess, file, user)
+	level, err := m.Access.GetLevel(context.Background(), models.File{Id: id.String()}, user)
 	if err != nil {
-		return "", echo.NewHTTPError(http.StatusNotFound, internal.ToSentence(err.Error()))
+		return "", err
 	}
 
-	return access.Level, nil
+	return level, nil
 }
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (m AccessMiddleware) RequireAtLeastRFolderAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		level, err := m.getFolderAccessLevel(c)
+		if err != nil {
+			return err
+		}
+
+		if !models.IsAtLeastRAccess(level) {
+			return echo.NewHTTPError(http.StatusForbidden, "At least 'read' access required")
+		}
+
+		c.Set("access", level)
+		return next(c)
+	}
+}
+
+func (m AccessMiddleware) RequireFolderOwnerAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		level, err := m.getFolderAccessLevel(c)
+		if err != nil {
+			return err
+		}
+
+		if !models.IsOwnerAccess(level) {
+			return echo.NewHTTPError(http.StatusForbidden, "Owner access required")
+		}
+
+		c.Set("access", level)
+		return next(c)
+	}
+}
+
+func (m AccessMiddleware) getFolderAccessLevel(c echo.Context) (string, error) {
+	user, ok := c.Get("user").(models.User)
+	if !ok {
+		return "", echo.NewHTTPError(http.StatusUnauthorized, "User wasn't found")
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid folder id")
+	}
+
+	level, err := m.Access.GetLevelForFolder(context.Background(), models.Folder{Id: id.String()}, user)
+	if err != nil {
+		return "", err
+	}
+
+	return level, nil
+}
@@ -1,56 +1,196 @@
 package http
 
 import (
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/http/broadcast"
 	"github.com/SergeyCherepiuk/docs/pkg/http/handlers"
 	"github.com/SergeyCherepiuk/docs/pkg/http/middleware"
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 )
 
-type Router struct{}
+// NOTE: Time to enter the code of the authenticator app after the password
+const pendingLoginTTL = 5 * time.Minute
+
+// NOTE: Page of the client the new password is set on, the link in the password reset emails leads to it
+const defaultPasswordResetURL = "http://localhost:5173/reset-password"
+
+// NOTE: Origin of the client, both the requests and the live editing sockets are only accepted from it
+const defaultAllowedOrigin = "http://localhost:5173"
+
+// NOTE: Zero SessionPolicy is models.DefaultSessionPolicy, emails are only logged without a Mailer
+type Router struct {
+	Repositories     database.Repositories
+	SessionPolicy    models.SessionPolicy
+	Mailer           mail.Mailer
+	PasswordResetURL string
+	AllowedOrigins   []string
+}
 
 func (r Router) Build() *echo.Echo {
+	origins := r.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{defaultAllowedOrigin}
+	}
+
 	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
 	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
-		AllowOrigins:     []string{"http://localhost:5173"},
+		AllowOrigins:     origins,
 		AllowCredentials: true,
 	}))
 	e.Use(echomiddleware.Logger())
 
+	repos := r.Repositories
+	policy := r.SessionPolicy
+	if policy == (models.SessionPolicy{}) {
+		policy = models.DefaultSessionPolicy
+	}
+	mailer := r.Mailer
+	if mailer == nil {
+		mailer = mail.LogMailer{}
+	}
+	resetURL := r.PasswordResetURL
+	if resetURL == "" {
+		resetURL = defaultPasswordResetURL
+	}
+
+	broadcast.AllowedOrigins = origins
+	broadcast.Store = broadcast.RepositoryStore{
+		Files:      repos.Files,
+		Revisions:  repos.Revisions,
+		Transactor: repos.Transactor,
+	}
+
+	// NOTE: Shared by the handlers, so the second factor has the same attempts wherever it's checked
+	pending := handlers.NewPendingLogins(pendingLoginTTL)
+
+	var (
+		authMiddleware   = middleware.AuthMiddleware{Sessions: repos.Sessions, Tokens: repos.Tokens, Policy: policy}
+		accessMiddleware = middleware.AccessMiddleware{Access: repos.Access}
+	)
+
+	var (
+		authHandler = handlers.AuthHandler{
+			Users:      repos.Users,
+			Sessions:   repos.Sessions,
+			Policy:     policy,
+			Pending:    pending,
+			Transactor: repos.Transactor,
+		}
+		userHandler      = handlers.UserHandler{Users: repos.Users, Pending: pending, Mailer: mailer}
+		twoFactorHandler = handlers.TwoFactorHandler{Users: repos.Users, Pending: pending}
+		resetHandler     = handlers.PasswordResetHandler{
+			Users:      repos.Users,
+			Resets:     repos.Resets,
+			Sessions:   repos.Sessions,
+			Mailer:     mailer,
+			Transactor: repos.Transactor,
+			URL:        resetURL,
+		}
+		fileHandler = handlers.FileHandler{
+			Users:      repos.Users,
+			Files:      repos.Files,
+			Folders:    repos.Folders,
+			Transactor: repos.Transactor,
+		}
+		accessHandler = handlers.AccessHandler{
+			Users:   repos.Users,
+			Files:   repos.Files,
+			Folders: repos.Folders,
+			Access:  repos.Access,
+		}
+		revisionHandler = handlers.RevisionHandler{
+			Files:     repos.Files,
+			Revisions: repos.Revisions,
+		}
+		folderHandler  = handlers.FolderHandler{Folders: repos.Folders}
+		searchHandler  = handlers.SearchHandler{Search: repos.Search}
+		tokenHandler   = handlers.TokenHandler{Tokens: repos.Tokens}
+		sessionHandler = handlers.SessionHandler{Sessions: repos.Sessions}
+	)
+
+	// NOTE: Scopes only limit the requests made with API tokens
 	var (
-		authHandler   = handlers.AuthHandler{}
-		userHandler   = handlers.UserHandler{}
-		fileHandler   = handlers.FileHandler{}
-		accessHandler = handlers.AccessHandler{}
+		read   = authMiddleware.RequireScope(models.ScopeFilesRead)
+		write  = authMiddleware.RequireScope(models.ScopeFilesWrite)
+		manage = authMiddleware.RequireScope(models.ScopeAccessManage)
 	)
 
 	v1 := e.Group("/api/v1")
 
 	auth := v1.Group("/auth")
-	auth.Use(middleware.RequireNoSession())
+	auth.Use(authMiddleware.RequireNoSession())
 	auth.POST("/signup", authHandler.SignUp)
 	auth.POST("/login", authHandler.Login)
+	auth.POST("/login/verify", authHandler.VerifyLogin)
+	auth.POST("/password-reset", resetHandler.Request)
+	auth.POST("/password-reset/confirm", resetHandler.Confirm)
 
-	v1.Use(middleware.RequireSession())
+	v1.Use(authMiddleware.RequireSession())
 
-	v1.POST("/auth/logout", authHandler.LogOut)
+	v1.POST("/auth/logout", authHandler.LogOut, authMiddleware.RequireNoToken)
 
-	user := v1.Group("/user")
+	user := v1.Group("/user", authMiddleware.RequireNoToken)
 	user.GET("/:username", userHandler.GetByUsername)
 	user.PUT("", userHandler.Update)
 	user.DELETE("", userHandler.Delete)
 
+	twoFactor := user.Group("/2fa")
+	twoFactor.POST("", twoFactorHandler.Enrol)
+	twoFactor.POST("/enable", twoFactorHandler.Enable)
+	twoFactor.DELETE("", twoFactorHandler.Disable)
+	twoFactor.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+
+	token := v1.Group("/tokens", authMiddleware.RequireNoToken)
+	token.POST("", tokenHandler.Create)
+	token.GET("", tokenHandler.GetAll)
+	token.DELETE("/:id", tokenHandler.Revoke)
+
+	session := v1.Group("/sessions", authMiddleware.RequireNoToken)
+	session.GET("", sessionHandler.GetAll)
+	session.DELETE("", sessionHandler.RevokeOthers)
+	session.DELETE("/:id", sessionHandler.Revoke)
+
 	file := v1.Group("/files")
-	file.POST("", fileHandler.Create)
-	file.GET("/:id", fileHandler.Get, middleware.RequireAtLeastRAccess)
-	file.GET("", fileHandler.GetAll)
-	file.PUT("/:id", fileHandler.Update, middleware.RequireAtLeastRWAccess)
-	file.DELETE("/:id", fileHandler.Delete, middleware.RequireOwnerAccess)
+	file.POST("", fileHandler.Create, write)
+	file.GET("/:id", fileHandler.Get, read, accessMiddleware.RequireAtLeastRAccess)
+	file.GET("", fileHandler.GetAll, read)
+	file.PUT("/:id", fileHandler.Update, write, accessMiddleware.RequireAtLeastRWAccess)
+	file.DELETE("/:id", fileHandler.Delete, write, accessMiddleware.RequireOwnerAccess)
+	file.GET("/:id/live", broadcast.Connect, read, accessMiddleware.RequireAtLeastRAccess)
+	file.GET("/:id/content", fileHandler.GetContent, read, accessMiddleware.RequireAtLeastRAccess)
+	file.PUT("/:id/content", fileHandler.UpdateContent, write, accessMiddleware.RequireAtLeastRWAccess)
+	file.PUT("/:id/move", fileHandler.Move, write, accessMiddleware.RequireOwnerAccess)
+
+	revision := file.Group("/:id/revisions")
+	revision.GET("", revisionHandler.GetAll, read, accessMiddleware.RequireAtLeastRAccess)
+	revision.GET("/:revision", revisionHandler.Get, read, accessMiddleware.RequireAtLeastRAccess)
+	revision.POST("/:revision/restore", revisionHandler.Restore, write, accessMiddleware.RequireAtLeastRWAccess)
+	file.GET("/:id/diff", revisionHandler.Diff, read, accessMiddleware.RequireAtLeastRAccess)
 
 	access := file.Group("/access")
-	access.POST("/:id", accessHandler.Grant, middleware.RequireOwnerAccess)
-	access.GET("/:id", accessHandler.GetAccesses, middleware.RequireAtLeastRAccess)
-	access.DELETE("/:id/:username", accessHandler.Revoke, middleware.RequireOwnerAccess)
+	access.POST("/:id", accessHandler.Grant, manage, accessMiddleware.RequireOwnerAccess)
+	access.GET("/:id", accessHandler.GetAccesses, read, accessMiddleware.RequireAtLeastRAccess)
+	access.DELETE("/:id/:username", accessHandler.Revoke, manage, accessMiddleware.RequireOwnerAccess)
+
+	v1.GET("/search", searchHandler.Files, read)
+
+	folder := v1.Group("/folders")
+	folder.POST("", folderHandler.Create, write)
+	folder.GET("/:id/children", folderHandler.GetChildren, read, accessMiddleware.RequireAtLeastRFolderAccess)
+	folder.PUT("/:id", folderHandler.Update, write, accessMiddleware.RequireFolderOwnerAccess)
+	folder.PUT("/:id/move", folderHandler.Move, write, accessMiddleware.RequireFolderOwnerAccess)
+	folder.DELETE("/:id", folderHandler.Delete, write, accessMiddleware.RequireFolderOwnerAccess)
+
+	folderAccess := folder.Group("/access")
+	folderAccess.POST("/:id", accessHandler.GrantForFolder, manage, accessMiddleware.RequireFolderOwnerAccess)
+	folderAccess.GET("/:id", accessHandler.GetAccessesForFolder, read, accessMiddleware.RequireAtLeastRFolderAccess)
+	folderAccess.DELETE("/:id/:username", accessHandler.RevokeForFolder, manage, accessMiddleware.RequireFolderOwnerAccess)
 
 	return e
 }
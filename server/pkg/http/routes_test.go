@@ -0,0 +1,456 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/memory"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	dochttp "github.com/SergeyCherepiuk/docs/pkg/http"
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
+	"github.com/SergeyCherepiuk/docs/pkg/totp"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestTokenScopes(t *testing.T) {
+	repos := memory.Repositories()
+	e := dochttp.Router{Repositories: repos}.Build()
+
+	ctx := context.Background()
+	user := models.User{Username: "ci", Password: "password"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	token, secret, err := models.NewToken(user.Username, "ci", []string{models.ScopeFilesRead}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Tokens.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		method, path  string
+		authorization string
+		status        int
+	}{
+		{"read with the scope", http.MethodGet, "/api/v1/files", "Bearer " + secret, http.StatusOK},
+		{"write without the scope", http.MethodPost, "/api/v1/files", "Bearer " + secret, http.StatusForbidden},
+		{"tokens can't manage tokens", http.MethodGet, "/api/v1/tokens", "Bearer " + secret, http.StatusForbidden},
+		{"unknown token", http.MethodGet, "/api/v1/files", "Bearer docs_unknown", http.StatusUnauthorized},
+		{"not a bearer token", http.MethodGet, "/api/v1/files", "Basic " + secret, http.StatusUnauthorized},
+		{"no credentials", http.MethodGet, "/api/v1/files", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(`{"name": "notes"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// NOTE: Access of the receiver can't be read for bob, as if the database was down
+type failingAccess struct {
+	database.AccessRepository
+}
+
+func (a failingAccess) Get(ctx context.Context, file models.File, user models.User) (models.Access, error) {
+	if user.Username == "bob" {
+		return models.Access{}, database.Internal(errors.New("connection refused"), "failed to get the access")
+	}
+	return a.AccessRepository.Get(ctx, file, user)
+}
+
+// NOTE: Only a missing access is granted anew, other errors mustn't be taken for it
+func TestGrantReturnsAccessErrors(t *testing.T) {
+	repos := memory.Repositories()
+	access := repos.Access
+	repos.Access = failingAccess{access}
+	e := dochttp.Router{Repositories: repos}.Build()
+
+	ctx := context.Background()
+	alice, bob := models.User{Username: "alice", Password: "password"}, models.User{Username: "bob", Password: "password"}
+	for _, user := range []models.User{alice, bob} {
+		if err := repos.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := models.File{Id: uuid.NewString(), Name: "notes"}
+	if err := repos.Files.Create(ctx, file, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	token, secret, err := models.NewToken(alice.Username, "ci", []string{models.ScopeAccessManage}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Tokens.Create(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files/access/"+file.Id, strings.NewReader(`{"receiver": "bob", "level": "R"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d: %s", http.StatusInternalServerError, rec.Code, rec.Body.String())
+	}
+	if _, err := access.Get(ctx, file, bob); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("expected no access to be granted, got %v", err)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	repos := memory.Repositories()
+	policy := models.SessionPolicy{IdleTimeout: time.Hour, AbsoluteTimeout: 3 * time.Hour, RefreshInterval: time.Minute}
+	e := dochttp.Router{Repositories: repos, SessionPolicy: policy}.Build()
+
+	ctx := context.Background()
+	user := models.User{Username: "ci", Password: "password"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(session models.Session) models.Session {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: session.Id})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		sessions, err := repos.Sessions.GetAll(ctx, user)
+		if err != nil || len(sessions) != 1 {
+			t.Fatalf("expected a single session, got %+v (%v)", sessions, err)
+		}
+		return sessions[0]
+	}
+
+	// NOTE: Seen less than the refresh interval ago, so the session isn't written
+	recent := policy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, recent); err != nil {
+		t.Fatal(err)
+	}
+	if got := request(recent); !got.ExpiresAt.Equal(recent.ExpiresAt) {
+		t.Errorf("expected expiry to stay %v, got %v", recent.ExpiresAt, got.ExpiresAt)
+	}
+	repos.Sessions.DeleteAll(ctx, user)
+
+	idle := policy.NewSession(user.Username)
+	idle.LastSeenAt = idle.LastSeenAt.Add(-30 * time.Minute)
+	idle.ExpiresAt = idle.ExpiresAt.Add(-30 * time.Minute)
+	if err := repos.Sessions.Create(ctx, idle); err != nil {
+		t.Fatal(err)
+	}
+	if got := request(idle); !got.ExpiresAt.After(idle.ExpiresAt) {
+		t.Errorf("expected expiry to be extended past %v, got %v", idle.ExpiresAt, got.ExpiresAt)
+	}
+	repos.Sessions.DeleteAll(ctx, user)
+
+	old := policy.NewSession(user.Username)
+	old.CreatedAt = old.CreatedAt.Add(-150 * time.Minute)
+	old.LastSeenAt = old.LastSeenAt.Add(-10 * time.Minute)
+	if err := repos.Sessions.Create(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := request(old); !got.ExpiresAt.Equal(old.CreatedAt.Add(policy.AbsoluteTimeout)) {
+		t.Errorf("expected expiry to be capped at %v, got %v", old.CreatedAt.Add(policy.AbsoluteTimeout), got.ExpiresAt)
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	repos := memory.Repositories()
+	e := dochttp.Router{Repositories: repos}.Build()
+
+	send := func(method, path, body, session string, response any) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if response != nil {
+			json.Unmarshal(rec.Body.Bytes(), response)
+		}
+		return rec.Code
+	}
+
+	var session models.Session
+	if code := send(http.MethodPost, "/api/v1/auth/signup", `{"username": "ci", "password": "password"}`, "", &session); code != http.StatusOK {
+		t.Fatalf("failed to sign up: %d", code)
+	}
+
+	var enrolment struct{ Secret string }
+	if code := send(http.MethodPost, "/api/v1/user/2fa", "", session.Id, &enrolment); code != http.StatusOK || enrolment.Secret == "" {
+		t.Fatalf("failed to enrol: %d", code)
+	}
+	if code := send(http.MethodPost, "/api/v1/user/2fa/enable", `{"code": "000000"}`, session.Id, nil); code != http.StatusBadRequest {
+		t.Errorf("expected wrong code not to enable two-factor, got %d", code)
+	}
+
+	totpCode, _ := totp.Code(enrolment.Secret, time.Now())
+	var enabled struct{ RecoveryCodes []string }
+	if code := send(http.MethodPost, "/api/v1/user/2fa/enable", `{"code": "`+totpCode+`"}`, session.Id, &enabled); code != http.StatusOK || len(enabled.RecoveryCodes) == 0 {
+		t.Fatalf("failed to enable two-factor: %d", code)
+	}
+
+	login := func() string {
+		var pending struct {
+			TwoFactorRequired bool
+			PendingToken      string
+		}
+		if code := send(http.MethodPost, "/api/v1/auth/login", `{"username": "ci", "password": "password"}`, "", &pending); code != http.StatusOK || !pending.TwoFactorRequired {
+			t.Fatalf("expected login to require the second factor, got %d %+v", code, pending)
+		}
+		return pending.PendingToken
+	}
+
+	token := login()
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", `{"pendingToken": "`+token+`", "code": "000000"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected wrong code to be rejected, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", `{"pendingToken": "`+token+`", "code": "`+totpCode+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the code that enabled two-factor not to be accepted again, got %d", code)
+	}
+
+	// NOTE: The code of the next period is accepted too, it's the only one left unused
+	nextCode, _ := totp.Code(enrolment.Secret, time.Now().Add(totp.Period))
+	token = login()
+	var verified models.Session
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", `{"pendingToken": "`+token+`", "code": "`+nextCode+`"}`, "", &verified); code != http.StatusOK || verified.Id == "" {
+		t.Fatalf("expected the code to log in, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", `{"pendingToken": "`+token+`", "code": "`+nextCode+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the pending login to be used once, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", `{"pendingToken": "`+login()+`", "code": "`+nextCode+`"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the code not to be replayed, got %d", code)
+	}
+
+	recovery := `{"pendingToken": "%s", "code": "` + enabled.RecoveryCodes[0] + `"}`
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", fmt.Sprintf(recovery, login()), "", nil); code != http.StatusOK {
+		t.Errorf("expected the recovery code to log in, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", fmt.Sprintf(recovery, login()), "", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the recovery code to be used once, got %d", code)
+	}
+
+	// NOTE: Logging in again doesn't give new attempts
+	wrong := `{"pendingToken": "%s", "code": "000000"}`
+	for i := 0; i < 9; i++ {
+		if code := send(http.MethodPost, "/api/v1/auth/login/verify", fmt.Sprintf(wrong, login()), "", nil); code != http.StatusUnauthorized {
+			t.Fatalf("expected wrong code to be rejected, got %d", code)
+		}
+	}
+	if code := send(http.MethodPost, "/api/v1/auth/login/verify", fmt.Sprintf(recovery, login()), "", nil); code != http.StatusTooManyRequests {
+		t.Errorf("expected the user to be locked out, got %d", code)
+	}
+}
+
+// NOTE: Signed in user confirms disabling and new recovery codes with the second factor,
+// wrong codes there count against the same limit as the ones of the login
+func TestTwoFactorEndpointsAreLimited(t *testing.T) {
+	e := dochttp.Router{Repositories: memory.Repositories()}.Build()
+
+	send := func(method, path, body, session string, response any) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if response != nil {
+			json.Unmarshal(rec.Body.Bytes(), response)
+		}
+		return rec.Code
+	}
+
+	var session models.Session
+	if code := send(http.MethodPost, "/api/v1/auth/signup", `{"username": "ci", "password": "password"}`, "", &session); code != http.StatusOK {
+		t.Fatalf("failed to sign up: %d", code)
+	}
+
+	var enrolment struct{ Secret string }
+	if code := send(http.MethodPost, "/api/v1/user/2fa", "", session.Id, &enrolment); code != http.StatusOK {
+		t.Fatalf("failed to enrol: %d", code)
+	}
+	totpCode, _ := totp.Code(enrolment.Secret, time.Now())
+	var enabled struct{ RecoveryCodes []string }
+	if code := send(http.MethodPost, "/api/v1/user/2fa/enable", `{"code": "`+totpCode+`"}`, session.Id, &enabled); code != http.StatusOK {
+		t.Fatalf("failed to enable two-factor: %d", code)
+	}
+
+	for i := 0; i < 10; i++ {
+		if code := send(http.MethodPost, "/api/v1/user/2fa/recovery-codes", `{"code": "000000"}`, session.Id, nil); code != http.StatusUnauthorized {
+			t.Fatalf("expected wrong code to be rejected, got %d", code)
+		}
+	}
+
+	recovery := `{"code": "` + enabled.RecoveryCodes[0] + `"}`
+	if code := send(http.MethodPost, "/api/v1/user/2fa/recovery-codes", recovery, session.Id, nil); code != http.StatusTooManyRequests {
+		t.Errorf("expected new recovery codes to be locked out, got %d", code)
+	}
+	if code := send(http.MethodDelete, "/api/v1/user/2fa", recovery, session.Id, nil); code != http.StatusTooManyRequests {
+		t.Errorf("expected disabling to be locked out, got %d", code)
+	}
+}
+
+// NOTE: Resets are mailed after the response, the messages are received from the channel
+type outbox chan mail.Message
+
+func (o outbox) Send(ctx context.Context, message mail.Message) error {
+	o <- message
+	return nil
+}
+
+func TestPasswordReset(t *testing.T) {
+	repos := memory.Repositories()
+	mailer := make(outbox, 1)
+	e := dochttp.Router{Repositories: repos, Mailer: mailer, PasswordResetURL: "http://client/reset"}.Build()
+
+	send := func(path, body, session string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		}
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	ctx := context.Background()
+	user := models.User{Username: "ci", Password: "password", Email: "ci@example.com"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	session := models.DefaultSessionPolicy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := send("/api/v1/auth/password-reset", `{"login": "nobody"}`, ""); code != http.StatusAccepted {
+		t.Errorf("expected unknown user to be accepted silently, got %d", code)
+	}
+	if code := send("/api/v1/auth/password-reset", `{"login": "CI@example.com"}`, ""); code != http.StatusAccepted {
+		t.Fatalf("expected the reset to be accepted, got %d", code)
+	}
+
+	var message mail.Message
+	select {
+	case message = <-mailer:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the reset to be mailed")
+	}
+	select {
+	case message := <-mailer:
+		t.Errorf("expected a single email, got another one to %s", message.To)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_, token, found := strings.Cut(message.Body, "http://client/reset?token=")
+	token, _, _ = strings.Cut(token, "\n")
+	if message.To != user.Email || !found || token == "" {
+		t.Fatalf("expected the reset link to be mailed to %s, got %+v", user.Email, message)
+	}
+
+	if code := send("/api/v1/auth/password-reset/confirm", `{"token": "`+token+`", "newPassword": "new", "newPasswordRepeat": "other"}`, ""); code != http.StatusBadRequest {
+		t.Errorf("expected different passwords to be rejected, got %d", code)
+	}
+	if code := send("/api/v1/auth/password-reset/confirm", `{"token": "`+token+`", "newPassword": "new", "newPasswordRepeat": "new"}`, ""); code != http.StatusOK {
+		t.Fatalf("expected the password to be reset, got %d", code)
+	}
+	if code := send("/api/v1/auth/password-reset/confirm", `{"token": "`+token+`", "newPassword": "again", "newPasswordRepeat": "again"}`, ""); code != http.StatusBadRequest {
+		t.Errorf("expected the token to be used once, got %d", code)
+	}
+
+	if _, _, err := repos.Sessions.Check(ctx, uuid.MustParse(session.Id)); err == nil {
+		t.Errorf("expected the sessions to be revoked")
+	}
+	if code := send("/api/v1/auth/login", `{"username": "ci", "password": "new"}`, ""); code != http.StatusOK {
+		t.Errorf("expected the new password to log in, got %d", code)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	repos := memory.Repositories()
+	mailer := make(outbox, 1)
+	e := dochttp.Router{Repositories: repos, Mailer: mailer}.Build()
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	ctx := context.Background()
+	user := models.User{Username: "ci", Password: string(hashed), Email: "ci@example.com"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	session := models.DefaultSessionPolicy.NewSession(user.Username)
+	if err := repos.Sessions.Create(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/user", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(&http.Cookie{Name: "session", Value: session.Id})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := update(`{"newEmail": "attacker@example.com"}`); code != http.StatusBadRequest {
+		t.Errorf("expected the email not to change without the password, got %d", code)
+	}
+	if code := update(`{"newEmail": "attacker@example.com", "oldPassword": "wrong"}`); code != http.StatusBadRequest {
+		t.Errorf("expected the email not to change with a wrong password, got %d", code)
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, user.Username); got.Email != user.Email {
+		t.Fatalf("expected the email to stay %s, got %s", user.Email, got.Email)
+	}
+
+	if code := update(`{"newEmail": "new@example.com", "oldPassword": "password"}`); code != http.StatusOK {
+		t.Fatalf("expected the email to change, got %d", code)
+	}
+	select {
+	case message := <-mailer:
+		if message.To != user.Email || !strings.Contains(message.Body, "new@example.com") {
+			t.Errorf("expected the current email to be notified, got %+v", message)
+		}
+	default:
+		t.Errorf("expected the current email to be notified")
+	}
+	if got, _ := repos.Users.GetByUsername(ctx, user.Username); got.Email != "new@example.com" {
+		t.Errorf("expected the email to be new@example.com, got %s", got.Email)
+	}
+}
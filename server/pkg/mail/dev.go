@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogMailer writes the messages to the log instead of sending them, for local development
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func (m LogMailer) Send(ctx context.Context, message Message) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	logger.Printf("mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileMailer writes every message to a file of its own in the directory, for local development.
+// Files are named after the time and the recipient, e.g. "20240102T150405.000000000-jane@example.com.eml"
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(ctx context.Context, message Message) error {
+	data, err := format(m.From, message)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), sanitize(message.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
+
+// NOTE: Only the characters that are safe in file names on any system are kept
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '@', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
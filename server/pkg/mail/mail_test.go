@@ -0,0 +1,43 @@
+package mail_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SergeyCherepiuk/docs/pkg/mail"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := mail.FileMailer{Dir: dir, From: "docs@example.com"}
+
+	message := mail.Message{To: "jane@example.com", Subject: "Reset your password", Body: "Hi,\nthe link"}
+	if err := mailer.Send(context.Background(), message); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*-jane@example.com.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected a single message file, got %v", files)
+	}
+
+	data, _ := os.ReadFile(files[0])
+	for _, expected := range []string{"From: docs@example.com\r\n", "To: jane@example.com\r\n", "Subject: Reset your password\r\n", "\r\n\r\nHi,\r\nthe link"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected the message to contain %q, got %q", expected, data)
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	mailer := mail.FileMailer{Dir: t.TempDir(), From: "docs@example.com"}
+
+	message := mail.Message{To: "jane@example.com\r\nBcc: eve@example.com", Subject: "Hi"}
+	if err := mailer.Send(context.Background(), message); !errors.Is(err, mail.ErrInvalidHeader) {
+		t.Errorf("expected line breaks in the headers to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer sends the messages through the SMTP server, STARTTLS is used if the server supports it.
+// Messages are sent without authentication if there is no username
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NOTE: net/smtp doesn't take a context, the message is sent even if it's cancelled
+func (m SMTPMailer) Send(ctx context.Context, message Message) error {
+	data, err := format(m.From, message)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.From, []string{message.To}, data)
+}
@@ -0,0 +1,52 @@
+package ot
+
+import "fmt"
+
+// Document is a server-side copy of the text, which orders concurrent
+// operations. It's not safe for concurrent use.
+type Document struct {
+	content string
+	history []Operation
+}
+
+func NewDocument(content string) *Document {
+	return &Document{content: content}
+}
+
+func (d *Document) Content() string {
+	return d.content
+}
+
+// Revision is the number of operations applied to the document
+func (d *Document) Revision() int {
+	return len(d.history)
+}
+
+// Apply takes an operation created against the given revision, transforms
+// it against every operation applied since then and applies the result.
+// Transformed operation is returned to be sent to the other clients.
+func (d *Document) Apply(revision int, operation Operation) (Operation, error) {
+	if revision < 0 || revision > d.Revision() {
+		return nil, fmt.Errorf("invalid revision: %d", revision)
+	}
+
+	if err := operation.Validate(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for _, concurrent := range d.history[revision:] {
+		if operation, _, err = Transform(operation, concurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := operation.Apply(d.content)
+	if err != nil {
+		return nil, err
+	}
+
+	d.content = content
+	d.history = append(d.history, operation)
+	return operation, nil
+}
@@ -0,0 +1,183 @@
+package ot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// NOTE: Counts come from the clients, anything above MaxCount can't be a length of a document
+const MaxCount = math.MaxInt32
+
+// NOTE: Lengths and positions are counted in runes (unicode code points)
+type Component struct {
+	Retain int    `json:"retain,omitempty"`
+	Insert string `json:"insert,omitempty"`
+	Delete int    `json:"delete,omitempty"`
+}
+
+func (c *Component) UnmarshalJSON(data []byte) error {
+	type component Component
+	var decoded component
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Retain < 0 || decoded.Retain > MaxCount || decoded.Delete < 0 || decoded.Delete > MaxCount {
+		return fmt.Errorf("invalid operation component: count is out of range")
+	}
+	*c = Component(decoded)
+	return nil
+}
+
+func (c Component) IsRetain() bool {
+	return c.Retain > 0
+}
+
+func (c Component) IsInsert() bool {
+	return c.Insert != ""
+}
+
+func (c Component) IsDelete() bool {
+	return c.Delete > 0
+}
+
+func (c Component) isValid() bool {
+	set := 0
+	if c.Retain != 0 {
+		set++
+	}
+	if c.Insert != "" {
+		set++
+	}
+	if c.Delete != 0 {
+		set++
+	}
+	return set == 1 && c.Retain >= 0 && c.Retain <= MaxCount && c.Delete >= 0 && c.Delete <= MaxCount
+}
+
+type Operation []Component
+
+func (o Operation) Retain(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+
+	if last := len(o) - 1; last >= 0 && o[last].IsRetain() {
+		o[last].Retain += n
+		return o
+	}
+	return append(o, Component{Retain: n})
+}
+
+// NOTE: Insert is always placed before an adjacent delete,
+// so equivalent operations have the same representation
+func (o Operation) Insert(s string) Operation {
+	if s == "" {
+		return o
+	}
+
+	last := len(o) - 1
+	if last >= 0 && o[last].IsInsert() {
+		o[last].Insert += s
+		return o
+	}
+
+	if last >= 0 && o[last].IsDelete() {
+		if last > 0 && o[last-1].IsInsert() {
+			o[last-1].Insert += s
+			return o
+		}
+		o = append(o, o[last])
+		o[last] = Component{Insert: s}
+		return o
+	}
+
+	return append(o, Component{Insert: s})
+}
+
+func (o Operation) Delete(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+
+	if last := len(o) - 1; last >= 0 && o[last].IsDelete() {
+		o[last].Delete += n
+		return o
+	}
+	return append(o, Component{Delete: n})
+}
+
+// Length of the document the operation can be applied to.
+// NOTE: Lengths saturate at math.MaxInt instead of overflowing
+func (o Operation) BaseLength() int {
+	length := 0
+	for _, c := range o {
+		length = add(length, add(c.Retain, c.Delete))
+	}
+	return length
+}
+
+// Length of the document after the operation is applied
+func (o Operation) TargetLength() int {
+	length := 0
+	for _, c := range o {
+		length = add(length, add(c.Retain, utf8.RuneCountInString(c.Insert)))
+	}
+	return length
+}
+
+// NOTE: Counts are never negative once the operation is validated
+func add(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func (o Operation) Validate() error {
+	for _, c := range o {
+		if !c.isValid() {
+			return fmt.Errorf("invalid operation component")
+		}
+	}
+	return nil
+}
+
+func (o Operation) Apply(document string) (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	runes := []rune(document)
+	errLength := fmt.Errorf("operation's base length doesn't match document's length")
+	if len(runes) != o.BaseLength() {
+		return "", errLength
+	}
+
+	// NOTE: Every step is checked against the document, so the slicing can't go out of its bounds
+	result := make([]rune, 0, o.TargetLength())
+	index := 0
+	for _, c := range o {
+		switch {
+		case c.IsRetain():
+			if c.Retain > len(runes)-index {
+				return "", errLength
+			}
+			result = append(result, runes[index:index+c.Retain]...)
+			index += c.Retain
+		case c.IsInsert():
+			result = append(result, []rune(c.Insert)...)
+		case c.IsDelete():
+			if c.Delete > len(runes)-index {
+				return "", errLength
+			}
+			index += c.Delete
+		}
+	}
+	if index != len(runes) {
+		return "", errLength
+	}
+
+	return string(result), nil
+}
@@ -0,0 +1,215 @@
+package ot_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/SergeyCherepiuk/docs/pkg/ot"
+)
+
+const alphabet = "abcdefgh ąęł\n"
+
+func randomString(r *rand.Rand, max int) string {
+	runes := []rune(alphabet)
+	s := make([]rune, r.Intn(max+1))
+	for i := range s {
+		s[i] = runes[r.Intn(len(runes))]
+	}
+	return string(s)
+}
+
+func randomOperation(r *rand.Rand, document string) ot.Operation {
+	var operation ot.Operation
+	left := len([]rune(document))
+	for left > 0 {
+		n := 1 + r.Intn(left)
+		switch r.Intn(3) {
+		case 0:
+			operation = operation.Retain(n)
+			left -= n
+		case 1:
+			operation = operation.Delete(n)
+			left -= n
+		case 2:
+			operation = operation.Insert(randomString(r, 5))
+		}
+	}
+	if r.Intn(2) == 0 {
+		operation = operation.Insert(randomString(r, 5))
+	}
+	return operation
+}
+
+var config = &quick.Config{MaxCount: 2000}
+
+func TestApplyProducesTargetLength(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		document := randomString(r, 20)
+		operation := randomOperation(r, document)
+
+		result, err := operation.Apply(document)
+		return err == nil && len([]rune(result)) == operation.TargetLength()
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransformConverges(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		document := randomString(r, 20)
+		a, b := randomOperation(r, document), randomOperation(r, document)
+
+		aPrime, bPrime, err := ot.Transform(a, b)
+		if err != nil {
+			return false
+		}
+
+		ab, err1 := apply(document, a, bPrime)
+		ba, err2 := apply(document, b, aPrime)
+		return err1 == nil && err2 == nil && ab == ba
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// NOTE: The counts of the crafted operations overflow when summed,
+// they must be rejected instead of slicing past the document
+func TestApplyRejectsOverflowingCounts(t *testing.T) {
+	operations := []ot.Operation{
+		{{Retain: 3}, {Retain: math.MaxInt}},
+		{{Retain: math.MaxInt}, {Retain: 4}},
+		{{Retain: math.MaxInt}, {Delete: math.MaxInt}, {Retain: 5}},
+		{{Retain: ot.MaxCount}, {Retain: ot.MaxCount}, {Delete: 3}},
+	}
+
+	for _, operation := range operations {
+		if _, err := operation.Apply("abc"); err == nil {
+			t.Errorf("expected %+v to be rejected", operation)
+		}
+		if _, err := ot.NewDocument("abc").Apply(0, operation); err == nil {
+			t.Errorf("expected %+v to be rejected by the document", operation)
+		}
+	}
+
+	var operation ot.Operation
+	if err := json.Unmarshal([]byte(`[{"retain":3},{"retain":9223372036854775807}]`), &operation); err == nil {
+		t.Errorf("expected huge count not to be decoded")
+	}
+	if err := json.Unmarshal([]byte(`[{"delete":-1}]`), &operation); err == nil {
+		t.Errorf("expected negative count not to be decoded")
+	}
+}
+
+func TestTransformRejectsDifferentBaseLengths(t *testing.T) {
+	a := ot.Operation{}.Retain(3)
+	b := ot.Operation{}.Retain(4)
+
+	if _, _, err := ot.Transform(a, b); err == nil {
+		t.Errorf("expected an error, actual: nil")
+	}
+}
+
+type client struct {
+	content string
+	pending ot.Operation
+}
+
+// NOTE: Several clients edit the same revision concurrently, the server
+// applies their operations in a random order and then every client
+// catches up the way a real client would: transforming its pending
+// operation against everything it receives before the acknowledgement.
+func TestDocumentConverges(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		document := ot.NewDocument(randomString(r, 10))
+
+		for round := 0; round < 5; round++ {
+			base, revision := document.Content(), document.Revision()
+
+			clients := make([]client, 1+r.Intn(4))
+			for i := range clients {
+				operation := randomOperation(r, base)
+				content, err := operation.Apply(base)
+				if err != nil {
+					return false
+				}
+				clients[i] = client{content: content, pending: operation}
+			}
+
+			type applied struct {
+				author    int
+				operation ot.Operation
+			}
+			var history []applied
+			for _, i := range r.Perm(len(clients)) {
+				operation, err := document.Apply(revision, clients[i].pending)
+				if err != nil {
+					return false
+				}
+				history = append(history, applied{i, operation})
+			}
+
+			for i := range clients {
+				c := &clients[i]
+				for _, h := range history {
+					if h.author == i {
+						c.pending = nil
+						continue
+					}
+
+					received := h.operation
+					if c.pending != nil {
+						var err error
+						if c.pending, received, err = ot.Transform(c.pending, received); err != nil {
+							return false
+						}
+					}
+
+					content, err := received.Apply(c.content)
+					if err != nil {
+						return false
+					}
+					c.content = content
+				}
+
+				if c.content != document.Content() {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDocumentRejectsFutureRevision(t *testing.T) {
+	document := ot.NewDocument("abc")
+	operation := ot.Operation{}.Retain(3).Insert("d")
+
+	if _, err := document.Apply(1, operation); err == nil {
+		t.Errorf("expected an error, actual: nil")
+	}
+}
+
+func apply(document string, operations ...ot.Operation) (string, error) {
+	var err error
+	for _, operation := range operations {
+		if document, err = operation.Apply(document); err != nil {
+			return "", err
+		}
+	}
+	return document, nil
+}
@@ -0,0 +1,95 @@
+package ot
+
+import "fmt"
+
+type cursor struct {
+	operation Operation
+	index     int
+	current   *Component
+}
+
+func newCursor(operation Operation) *cursor {
+	c := &cursor{operation: operation}
+	c.next()
+	return c
+}
+
+func (c *cursor) next() {
+	if c.index >= len(c.operation) {
+		c.current = nil
+		return
+	}
+
+	component := c.operation[c.index]
+	c.current = &component
+	c.index++
+}
+
+// NOTE: Consumes n runes of the current retain or delete component
+func (c *cursor) consume(n int) {
+	switch {
+	case c.current.IsRetain():
+		c.current.Retain -= n
+		if c.current.Retain == 0 {
+			c.next()
+		}
+	case c.current.IsDelete():
+		c.current.Delete -= n
+		if c.current.Delete == 0 {
+			c.next()
+		}
+	}
+}
+
+func (c *cursor) length() int {
+	return c.current.Retain + c.current.Delete
+}
+
+// Transform takes two operations a and b that were applied to the same
+// document and produces a' and b', such that apply(apply(doc, a), b')
+// equals apply(apply(doc, b), a'). Inserts of a win ties at the same position.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, fmt.Errorf("operations have different base lengths")
+	}
+
+	var aPrime, bPrime Operation
+	ca, cb := newCursor(a), newCursor(b)
+
+	for ca.current != nil || cb.current != nil {
+		if ca.current != nil && ca.current.IsInsert() {
+			aPrime = aPrime.Insert(ca.current.Insert)
+			bPrime = bPrime.Retain(len([]rune(ca.current.Insert)))
+			ca.next()
+			continue
+		}
+
+		if cb.current != nil && cb.current.IsInsert() {
+			aPrime = aPrime.Retain(len([]rune(cb.current.Insert)))
+			bPrime = bPrime.Insert(cb.current.Insert)
+			cb.next()
+			continue
+		}
+
+		if ca.current == nil || cb.current == nil {
+			return nil, nil, fmt.Errorf("operations have different base lengths")
+		}
+
+		n := min(ca.length(), cb.length())
+		switch {
+		case ca.current.IsRetain() && cb.current.IsRetain():
+			aPrime = aPrime.Retain(n)
+			bPrime = bPrime.Retain(n)
+		case ca.current.IsDelete() && cb.current.IsRetain():
+			aPrime = aPrime.Delete(n)
+		case ca.current.IsRetain() && cb.current.IsDelete():
+			bPrime = bPrime.Delete(n)
+		}
+		// NOTE: When both delete the same range, nothing is left to do
+
+		ca.consume(n)
+		cb.consume(n)
+	}
+
+	return aPrime, bPrime, nil
+}
@@ -0,0 +1,109 @@
+package search
+
+import (
+	"context"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/google/uuid"
+)
+
+// IndexedFiles is a database.FileRepository that keeps the index up to date
+// whenever a file is created, renamed, its content is saved or it's deleted.
+// The index is updated as soon as the file is, even inside a transaction
+type IndexedFiles struct {
+	database.FileRepository
+	Index Indexer
+}
+
+func (f IndexedFiles) Create(ctx context.Context, file models.File, owner models.User) error {
+	if err := f.FileRepository.Create(ctx, file, owner); err != nil {
+		return err
+	}
+	return f.index(ctx, file.Id)
+}
+
+func (f IndexedFiles) UpdateName(ctx context.Context, file models.File, name string, editor models.User) error {
+	if err := f.FileRepository.UpdateName(ctx, file, name, editor); err != nil {
+		return err
+	}
+	return f.index(ctx, file.Id)
+}
+
+func (f IndexedFiles) UpdateContent(ctx context.Context, file models.File, content models.Content, editor models.User) error {
+	if err := f.FileRepository.UpdateContent(ctx, file, content, editor); err != nil {
+		return err
+	}
+	return f.index(ctx, file.Id)
+}
+
+func (f IndexedFiles) Delete(ctx context.Context, file models.File) error {
+	if err := f.FileRepository.Delete(ctx, file); err != nil {
+		return err
+	}
+	return f.remove(ctx, file.Id)
+}
+
+func (f IndexedFiles) DeleteAllForOwner(ctx context.Context, owner models.User) error {
+	ids := []string{}
+	page := database.PageRequest{Limit: database.MaxPageLimit}
+	for {
+		files, err := f.FileRepository.GetAllForOwner(ctx, owner, database.FileFilter{}, page)
+		if err != nil {
+			return err
+		}
+		for _, file := range files.Items {
+			ids = append(ids, file.Id)
+		}
+
+		if files.Next == "" {
+			break
+		}
+		page.After = files.Next
+	}
+
+	if err := f.FileRepository.DeleteAllForOwner(ctx, owner); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := f.remove(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NOTE: The file is read back, so that the index gets the name and the content as they were stored
+func (f IndexedFiles) index(ctx context.Context, id string) error {
+	file, err := f.FileRepository.GetById(ctx, parseId(id))
+	if err != nil {
+		return err
+	}
+
+	content, err := f.FileRepository.GetContent(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Index.Index(ctx, Document{Id: file.Id, Name: file.Name, Content: content.Text}); err != nil {
+		return database.Internal(err, "failed to index the file")
+	}
+	return nil
+}
+
+func (f IndexedFiles) remove(ctx context.Context, id string) error {
+	if err := f.Index.Remove(ctx, id); err != nil {
+		return database.Internal(err, "failed to remove the file from the search index")
+	}
+	return nil
+}
+
+// NOTE: Ids that aren't valid uuids can't be found either, uuid.Nil is looked up instead
+func parseId(id string) uuid.UUID {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil
+	}
+	return parsed
+}
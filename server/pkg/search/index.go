@@ -0,0 +1,287 @@
+package search
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NOTE: Words of the name count this many times, so that matches in the name rank higher
+const nameBoost = 3
+
+// NOTE: BM25 parameters, saturation of the term frequency and normalization by the document length
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// InvertedIndex is an embedded Indexer, it keeps the index in memory
+// and can save it to a file and load it back
+type InvertedIndex struct {
+	mu sync.RWMutex
+
+	// NOTE: Documents by id, with the number of occurrences of every term
+	docs map[string]indexedDoc
+	// NOTE: Postings, ids of the documents containing the term
+	postings map[string]map[string]struct{}
+	// NOTE: Words as they were written, with the terms they were stemmed to,
+	// prefix queries are matched against them. Words are only dropped on rebuild
+	words map[string]string
+
+	totalLength int
+	changed     bool
+	// NOTE: Time of the last save (or of the one the index was loaded from), zero for a new index
+	savedAt time.Time
+}
+
+type indexedDoc struct {
+	Terms  map[string]int
+	Length int
+}
+
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		docs:     make(map[string]indexedDoc),
+		postings: make(map[string]map[string]struct{}),
+		words:    make(map[string]string),
+	}
+}
+
+func (i *InvertedIndex) Index(ctx context.Context, doc Document) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(doc.Id)
+
+	indexed := indexedDoc{Terms: make(map[string]int)}
+	add := func(text string, weight int) {
+		for _, word := range Tokenize(text) {
+			term := Stem(word)
+			i.words[word] = term
+			indexed.Terms[term] += weight
+			indexed.Length += weight
+		}
+	}
+	add(doc.Name, nameBoost)
+	add(doc.Content, 1)
+
+	i.docs[doc.Id] = indexed
+	i.totalLength += indexed.Length
+	for term := range indexed.Terms {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[string]struct{})
+		}
+		i.postings[term][doc.Id] = struct{}{}
+	}
+
+	i.changed = true
+	return nil
+}
+
+func (i *InvertedIndex) Remove(ctx context.Context, id string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(id)
+	i.changed = true
+	return nil
+}
+
+func (i *InvertedIndex) remove(id string) {
+	doc, ok := i.docs[id]
+	if !ok {
+		return
+	}
+
+	for term := range doc.Terms {
+		delete(i.postings[term], id)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+		}
+	}
+	i.totalLength -= doc.Length
+	delete(i.docs, id)
+}
+
+func (i *InvertedIndex) Reset(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.docs = make(map[string]indexedDoc)
+	i.postings = make(map[string]map[string]struct{})
+	i.words = make(map[string]string)
+	i.totalLength = 0
+	i.changed = true
+	return nil
+}
+
+// Search ranks the documents containing any of the terms with BM25
+func (i *InvertedIndex) Search(ctx context.Context, query Query) ([]Hit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if len(i.docs) == 0 {
+		return []Hit{}, nil
+	}
+
+	terms := make(map[string]bool)
+	for _, term := range query.Terms {
+		terms[term] = true
+	}
+	if len(query.Prefixes) > 0 {
+		for word, term := range i.words {
+			for _, prefix := range query.Prefixes {
+				if strings.HasPrefix(word, prefix) {
+					terms[term] = true
+				}
+			}
+		}
+	}
+
+	n := float64(len(i.docs))
+	avgLength := float64(i.totalLength) / n
+
+	scores := make(map[string]float64)
+	for term := range terms {
+		postings := i.postings[term]
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for id := range postings {
+			doc := i.docs[id]
+			tf := float64(doc.Terms[term])
+			scores[id] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.Length)/avgLength))
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Id: id, Score: score})
+	}
+	sort.Slice(hits, func(x, y int) bool {
+		if hits[x].Score != hits[y].Score {
+			return hits[x].Score > hits[y].Score
+		}
+		return hits[x].Id < hits[y].Id
+	})
+
+	return hits, nil
+}
+
+// snapshot is what's saved to the file, postings are rebuilt from the documents on load.
+// Files saved before SavedAt was added have it zero, so they are always outdated
+type snapshot struct {
+	Docs    map[string]indexedDoc
+	Words   map[string]string
+	SavedAt time.Time
+}
+
+func loadInvertedIndex(path string) (*InvertedIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var s snapshot
+	if err := gob.NewDecoder(file).Decode(&s); err != nil {
+		return nil, err
+	}
+
+	index := NewInvertedIndex()
+	index.words = s.Words
+	index.savedAt = s.SavedAt
+	for id, doc := range s.Docs {
+		index.docs[id] = doc
+		index.totalLength += doc.Length
+		for term := range doc.Terms {
+			if index.postings[term] == nil {
+				index.postings[term] = make(map[string]struct{})
+			}
+			index.postings[term][id] = struct{}{}
+		}
+	}
+
+	return index, nil
+}
+
+// Save writes the index to a temporary file first, which then replaces
+// the previous one, so that a failed save leaves the previous one intact
+func (i *InvertedIndex) Save(path string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	savedAt := time.Now()
+	if err := gob.NewEncoder(tmp).Encode(snapshot{Docs: i.docs, Words: i.words, SavedAt: savedAt}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	i.changed = false
+	i.savedAt = savedAt
+	return nil
+}
+
+// SavedAt is the time of the last save, changes made to the files after it aren't in the saved index
+func (i *InvertedIndex) SavedAt() time.Time {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.savedAt
+}
+
+// AutoSave saves the index every interval if it has changed, until the context is done
+func (i *InvertedIndex) AutoSave(ctx context.Context, path string, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.mu.RLock()
+			changed := i.changed
+			i.mu.RUnlock()
+
+			if changed {
+				if err := i.Save(path); err != nil {
+					onError(err)
+				}
+			}
+		}
+	}
+}
+
+// OpenInvertedIndex loads the index saved to the file. Missing file gives
+// an empty index, loaded is false then and the index needs to be rebuilt
+func OpenInvertedIndex(path string) (index *InvertedIndex, loaded bool, err error) {
+	index, err = loadInvertedIndex(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewInvertedIndex(), false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return index, true, nil
+}
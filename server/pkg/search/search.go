@@ -0,0 +1,184 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+)
+
+// Document is what's indexed for a file
+type Document struct {
+	Id      string
+	Name    string
+	Content string
+}
+
+// Hit is a document matching the query, the higher the score the more relevant it is
+type Hit struct {
+	Id    string
+	Score float64
+}
+
+// Indexer keeps the files searchable by the words of their names and contents.
+// It knows nothing about the accesses, the hits are filtered by the Service
+type Indexer interface {
+	Index(ctx context.Context, doc Document) error
+	Remove(ctx context.Context, id string) error
+	Search(ctx context.Context, query Query) ([]Hit, error)
+	Reset(ctx context.Context) error
+}
+
+// NOTE: Every hit costs an access check, so only this many of the best hits are checked.
+// A user who can read few of the many matching files may get fewer results than there are
+const defaultMaxCandidates = 10 * database.MaxSearchLimit
+
+// Service is a database.SearchRepository backed by an Indexer.
+// Zero MaxCandidates is defaultMaxCandidates
+type Service struct {
+	Index         Indexer
+	Files         database.FileRepository
+	Access        database.AccessRepository
+	MaxCandidates int
+}
+
+// NOTE: Hits are checked by the access level the same way the access middleware does it,
+// the user needs at least 'read' access. Files deleted without the index knowing
+// (e.g. with their folder or their owner) are dropped from the index here
+func (s Service) Search(ctx context.Context, user models.User, query string, limit int) ([]models.SearchResult, error) {
+	q := ParseQuery(query)
+	if q.Empty() {
+		return []models.SearchResult{}, nil
+	}
+
+	hits, err := s.Index.Search(ctx, q)
+	if err != nil {
+		return nil, database.Internal(err, "failed to search the files")
+	}
+
+	maxCandidates := s.MaxCandidates
+	if maxCandidates <= 0 {
+		maxCandidates = defaultMaxCandidates
+	}
+	hits = hits[:min(len(hits), maxCandidates)]
+
+	limit = database.SearchLimit(limit)
+	results := []models.SearchResult{}
+	for _, hit := range hits {
+		if len(results) == limit {
+			break
+		}
+
+		file := models.File{Id: hit.Id}
+
+		level, err := s.Access.GetLevel(ctx, file, user)
+		if errors.Is(err, database.ErrNotFound) {
+			if err := s.Index.Remove(ctx, hit.Id); err != nil {
+				return nil, database.Internal(err, "failed to remove the file from the search index")
+			}
+			continue
+		}
+		if errors.Is(err, database.ErrForbidden) || (err == nil && !models.IsAtLeastRAccess(level)) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := s.result(ctx, file, q, hit.Score)
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s Service) result(ctx context.Context, file models.File, q Query, score float64) (models.SearchResult, error) {
+	file, err := s.Files.GetById(ctx, parseId(file.Id))
+	if err != nil {
+		return models.SearchResult{}, err
+	}
+
+	owner, err := s.Files.GetOwner(ctx, file)
+	if err != nil {
+		return models.SearchResult{}, err
+	}
+
+	content, err := s.Files.GetContent(ctx, file)
+	if err != nil {
+		return models.SearchResult{}, err
+	}
+
+	return models.SearchResult{
+		File:     file,
+		Owner:    owner.Username,
+		Score:    score,
+		Snippets: database.Snippets(file.Name, content.Text, matchedWords(q, file.Name, content.Text)),
+	}, nil
+}
+
+// matchedWords finds the words of the name and the content the query matches,
+// those are highlighted in the snippets (e.g. "running" for the term "run")
+func matchedWords(q Query, texts ...string) []string {
+	words := []string{}
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, word := range Tokenize(text) {
+			if !seen[word] && q.Matches(word) {
+				words = append(words, word)
+			}
+			seen[word] = true
+		}
+	}
+	return words
+}
+
+// Outdated tells whether any file was updated after the time, e.g. after the index was saved.
+// Files are updated without the index while the server runs with another search engine or crashes before saving it
+func Outdated(ctx context.Context, since time.Time, files database.FileRepository) (bool, error) {
+	latest, err := files.GetAll(ctx, database.PageRequest{Limit: 1, SortBy: database.SortByUpdatedAt})
+	if err != nil {
+		return false, err
+	}
+	return len(latest.Items) > 0 && latest.Items[0].UpdatedAt.After(since), nil
+}
+
+// Rebuild indexes every file from scratch, it returns the number of indexed files
+func Rebuild(ctx context.Context, index Indexer, files database.FileRepository) (int, error) {
+	if err := index.Reset(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	page := database.PageRequest{Limit: database.MaxPageLimit}
+	for {
+		all, err := files.GetAll(ctx, page)
+		if err != nil {
+			return count, err
+		}
+
+		for _, file := range all.Items {
+			content, err := files.GetContent(ctx, file)
+			if err != nil {
+				return count, err
+			}
+
+			if err := index.Index(ctx, Document{Id: file.Id, Name: file.Name, Content: content.Text}); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if all.Next == "" {
+			return count, nil
+		}
+		page.After = all.Next
+	}
+}
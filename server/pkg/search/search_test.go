@@ -0,0 +1,207 @@
+package search_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/database"
+	"github.com/SergeyCherepiuk/docs/pkg/database/databasetest"
+	"github.com/SergeyCherepiuk/docs/pkg/database/memory"
+	"github.com/SergeyCherepiuk/docs/pkg/database/models"
+	"github.com/SergeyCherepiuk/docs/pkg/search"
+	"github.com/google/uuid"
+)
+
+func TestStem(t *testing.T) {
+	words := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"connection":     "connect",
+		"connected":      "connect",
+		"running":        "run",
+		"adjustment":     "adjust",
+		"controll":       "control",
+		"go":             "go",
+		"v2":             "v2",
+	}
+
+	for word, want := range words {
+		if got := search.Stem(word); got != want {
+			t.Errorf("expected %q to be stemmed to %q, got %q", word, want, got)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	got := search.ParseQuery("The Running budg* runs the*")
+	want := search.Query{Terms: []string{"run"}, Prefixes: []string{"budg", "the"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if !search.ParseQuery("of the, and").Empty() {
+		t.Errorf("expected a query of stop words to be empty")
+	}
+}
+
+func TestInvertedIndex(t *testing.T) {
+	ctx := context.Background()
+	index := search.NewInvertedIndex()
+
+	docs := []search.Document{
+		{Id: "a", Name: "Budget", Content: "Quarterly budget, connected to the forecast"},
+		{Id: "b", Name: "Notes", Content: "The budget was discussed"},
+		{Id: "c", Name: "Recipes", Content: "Connecting flavours"},
+	}
+	for _, doc := range docs {
+		if err := index.Index(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(query string) []string {
+		hits, err := index.Search(ctx, search.ParseQuery(query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, hit := range hits {
+			ids = append(ids, hit.Id)
+		}
+		return ids
+	}
+
+	if got, want := ids("budget"), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the name match to rank first %v, got %v", want, got)
+	}
+	if got, want := ids("connections"), []string{"c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected stemmed matches %v, got %v", want, got)
+	}
+	if got, want := ids("fore*"), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected prefix matches %v, got %v", want, got)
+	}
+
+	if err := index.Index(ctx, search.Document{Id: "b", Name: "Notes", Content: "Nothing here"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Remove(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids("budget connect"), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected reindexed and removed documents not to match, got %v", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "search.idx")
+	if err := index.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok, err := search.OpenInvertedIndex(path)
+	if err != nil || !ok {
+		t.Fatalf("failed to load the index: %v", err)
+	}
+	if !loaded.SavedAt().Equal(index.SavedAt()) || index.SavedAt().IsZero() {
+		t.Errorf("expected loaded index to be saved at %v, got %v", index.SavedAt(), loaded.SavedAt())
+	}
+	hits, _ := loaded.Search(ctx, search.ParseQuery("budget"))
+	if want, _ := index.Search(ctx, search.ParseQuery("budget")); !reflect.DeepEqual(hits, want) {
+		t.Errorf("expected loaded index to give %+v, got %+v", want, hits)
+	}
+
+	if _, ok, err := search.OpenInvertedIndex(filepath.Join(t.TempDir(), "missing.idx")); ok || err != nil {
+		t.Errorf("expected missing index to be empty, got %v", err)
+	}
+}
+
+func TestOutdated(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.Repositories()
+
+	owner := models.User{Username: "ci", Password: "password"}
+	if err := repos.Users.Create(ctx, owner); err != nil {
+		t.Fatal(err)
+	}
+	if outdated, err := search.Outdated(ctx, time.Time{}, repos.Files); outdated || err != nil {
+		t.Errorf("expected index without files not to be outdated, got %v (%v)", outdated, err)
+	}
+
+	file := models.File{Id: uuid.NewString(), Name: "notes"}
+	if err := repos.Files.Create(ctx, file, owner); err != nil {
+		t.Fatal(err)
+	}
+	savedAt := time.Now()
+	if outdated, err := search.Outdated(ctx, savedAt, repos.Files); outdated || err != nil {
+		t.Errorf("expected index saved after the update not to be outdated, got %v (%v)", outdated, err)
+	}
+
+	if err := repos.Files.UpdateName(ctx, file, "budget", owner); err != nil {
+		t.Fatal(err)
+	}
+	if outdated, err := search.Outdated(ctx, savedAt, repos.Files); !outdated || err != nil {
+		t.Errorf("expected index saved before the update to be outdated, got %v (%v)", outdated, err)
+	}
+}
+
+// NOTE: The whole conformance suite runs with the index in place of the backend's search,
+// which covers the access rules and keeping the index up to date
+func TestConformance(t *testing.T) {
+	repos := memory.Repositories()
+	index := search.NewInvertedIndex()
+
+	repos.Files = search.IndexedFiles{FileRepository: repos.Files, Index: index}
+	repos.Search = search.Service{Index: index, Files: repos.Files, Access: repos.Access}
+	databasetest.Run(t, repos)
+
+	count, err := search.Rebuild(context.Background(), index, repos.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, _ := repos.Files.GetAll(context.Background(), database.PageRequest{Limit: database.MaxPageLimit})
+	if count != len(all.Items) {
+		t.Errorf("expected %d files to be indexed, got %d", len(all.Items), count)
+	}
+}
+
+type countingAccess struct {
+	database.AccessRepository
+	checks int
+}
+
+func (a *countingAccess) GetLevel(ctx context.Context, file models.File, user models.User) (string, error) {
+	a.checks++
+	return a.AccessRepository.GetLevel(ctx, file, user)
+}
+
+func TestSearchChecksBoundedCandidates(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.Repositories()
+	index := search.NewInvertedIndex()
+
+	for i := 0; i < 10; i++ {
+		index.Index(ctx, search.Document{Id: uuid.NewString(), Name: "budget"})
+	}
+
+	access := &countingAccess{AccessRepository: repos.Access}
+	service := search.Service{Index: index, Files: repos.Files, Access: access, MaxCandidates: 3}
+	if results, err := service.Search(ctx, models.User{Username: "john"}, "budget", 5); err != nil || len(results) != 0 {
+		t.Fatalf("expected no results, got %+v (%v)", results, err)
+	}
+
+	if access.checks != 3 {
+		t.Errorf("expected 3 access checks, got %d", access.checks)
+	}
+	if hits, _ := index.Search(ctx, search.ParseQuery("budget")); len(hits) != 7 {
+		t.Errorf("expected the unchecked hits to stay in the index, got %d", len(hits))
+	}
+}
@@ -0,0 +1,224 @@
+package search
+
+import "strings"
+
+// Stem reduces an English word to its stem with the Porter algorithm, so that
+// "connected", "connecting" and "connection" are all indexed as "connect".
+// The word is expected to be lowercase, words of up to two letters are kept as they are
+func Stem(word string) string {
+	if len(word) <= 2 || !isASCIILetters(word) {
+		return word
+	}
+
+	s := &stemmer{b: []byte(word)}
+	s.step1a()
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+func isASCIILetters(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+type stemmer struct {
+	b []byte
+}
+
+// consonant tells whether the letter at i is a consonant, "y" is one
+// unless it follows another consonant
+func (s *stemmer) consonant(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.consonant(i-1)
+	default:
+		return true
+	}
+}
+
+// measure counts vowel-consonant sequences in the first n letters
+func (s *stemmer) measure(n int) int {
+	m, i := 0, 0
+	for i < n && s.consonant(i) {
+		i++
+	}
+	for i < n {
+		for i < n && !s.consonant(i) {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		for i < n && s.consonant(i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+func (s *stemmer) hasVowel(n int) bool {
+	for i := 0; i < n; i++ {
+		if !s.consonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stemmer) doubleConsonant(n int) bool {
+	return n >= 2 && s.b[n-1] == s.b[n-2] && s.consonant(n-1)
+}
+
+// cvc tells whether the first n letters end with consonant-vowel-consonant,
+// where the last consonant isn't "w", "x" or "y"
+func (s *stemmer) cvc(n int) bool {
+	if n < 3 || !s.consonant(n-1) || s.consonant(n-2) || !s.consonant(n-3) {
+		return false
+	}
+	c := s.b[n-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+func (s *stemmer) ends(suffix string) bool {
+	return strings.HasSuffix(string(s.b), suffix)
+}
+
+// replace swaps the suffix for the replacement, if the measure of the rest is above min
+func (s *stemmer) replace(suffix, replacement string, min int) bool {
+	if !s.ends(suffix) {
+		return false
+	}
+	n := len(s.b) - len(suffix)
+	if s.measure(n) > min {
+		s.b = append(s.b[:n], replacement...)
+	}
+	return true
+}
+
+func (s *stemmer) step1a() {
+	switch {
+	case s.ends("sses"), s.ends("ies"):
+		s.b = s.b[:len(s.b)-2]
+	case s.ends("ss"):
+	case s.ends("s"):
+		s.b = s.b[:len(s.b)-1]
+	}
+}
+
+func (s *stemmer) step1b() {
+	if s.ends("eed") {
+		if s.measure(len(s.b)-3) > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+		return
+	}
+
+	var n int
+	switch {
+	case s.ends("ed") && s.hasVowel(len(s.b)-2):
+		n = len(s.b) - 2
+	case s.ends("ing") && s.hasVowel(len(s.b)-3):
+		n = len(s.b) - 3
+	default:
+		return
+	}
+	s.b = s.b[:n]
+
+	switch {
+	case s.ends("at"), s.ends("bl"), s.ends("iz"):
+		s.b = append(s.b, 'e')
+	case s.doubleConsonant(n):
+		if c := s.b[n-1]; c != 'l' && c != 's' && c != 'z' {
+			s.b = s.b[:n-1]
+		}
+	case s.measure(n) == 1 && s.cvc(n):
+		s.b = append(s.b, 'e')
+	}
+}
+
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.hasVowel(len(s.b)-1) {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func (s *stemmer) step2() {
+	for _, suffix := range step2Suffixes {
+		if s.replace(suffix[0], suffix[1], 0) {
+			return
+		}
+	}
+}
+
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func (s *stemmer) step3() {
+	for _, suffix := range step3Suffixes {
+		if s.replace(suffix[0], suffix[1], 0) {
+			return
+		}
+	}
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (s *stemmer) step4() {
+	// NOTE: The longest matching suffix wins, e.g. "ement" over "ment" and "ent"
+	longest := ""
+	for _, suffix := range step4Suffixes {
+		if s.ends(suffix) && len(suffix) > len(longest) {
+			longest = suffix
+		}
+	}
+	if longest == "" {
+		return
+	}
+
+	n := len(s.b) - len(longest)
+	if longest == "ion" && (n == 0 || (s.b[n-1] != 's' && s.b[n-1] != 't')) {
+		return
+	}
+	if s.measure(n) > 1 {
+		s.b = s.b[:n]
+	}
+}
+
+func (s *stemmer) step5() {
+	if s.ends("e") {
+		n := len(s.b) - 1
+		if m := s.measure(n); m > 1 || (m == 1 && !s.cvc(n)) {
+			s.b = s.b[:n]
+		}
+	}
+
+	if n := len(s.b); s.measure(n) > 1 && s.doubleConsonant(n) && s.b[n-1] == 'l' {
+		s.b = s.b[:n-1]
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NOTE: Too common to tell the documents apart, they are neither indexed nor searched for
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"were": true, "with": true,
+}
+
+// Tokenize splits the text into lowercase words, stop words are dropped
+func Tokenize(text string) []string {
+	tokens := []string{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		if !stopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Query is a parsed search query. Terms are stemmed, prefixes are kept as they were typed
+type Query struct {
+	Terms    []string
+	Prefixes []string
+}
+
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Prefixes) == 0
+}
+
+// ParseQuery splits the query into terms, a word ending with "*" (e.g. "budg*") matches
+// every word starting with it. Repeated terms are dropped
+func ParseQuery(query string) Query {
+	var (
+		q    Query
+		seen = make(map[string]bool)
+	)
+
+	for _, field := range strings.Fields(query) {
+		words := strings.FieldsFunc(strings.ToLower(field), isSeparator)
+		if len(words) == 0 {
+			continue
+		}
+
+		// NOTE: Prefix can be a stop word, "the*" is still looking for "theory"
+		if strings.HasSuffix(field, "*") {
+			prefix := words[len(words)-1]
+			if !seen["*"+prefix] {
+				seen["*"+prefix] = true
+				q.Prefixes = append(q.Prefixes, prefix)
+			}
+			words = words[:len(words)-1]
+		}
+
+		for _, token := range words {
+			if stopWords[token] {
+				continue
+			}
+
+			term := Stem(token)
+			if !seen[term] {
+				seen[term] = true
+				q.Terms = append(q.Terms, term)
+			}
+		}
+	}
+
+	return q
+}
+
+// Matches tells whether the word of a document is matched by the query
+func (q Query) Matches(word string) bool {
+	stem := Stem(word)
+	for _, term := range q.Terms {
+		if stem == term {
+			return true
+		}
+	}
+	for _, prefix := range q.Prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
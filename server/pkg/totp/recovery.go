@@ -0,0 +1,47 @@
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+)
+
+const (
+	recoveryCodeSize     = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// GenerateRecoveryCodes returns n random one-time codes, e.g. "k7wqp-3xn2m", each can be used
+// once instead of the code of the authenticator app. Ambiguous letters and digits are left out,
+// every character is equally likely (a random byte modulo the alphabet size would favour the first ones)
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	size := big.NewInt(int64(len(recoveryCodeAlphabet)))
+
+	codes := make([]string, n)
+	for i := range codes {
+		var code strings.Builder
+		for j := 0; j < recoveryCodeSize; j++ {
+			if j == recoveryCodeSize/2 {
+				code.WriteByte('-')
+			}
+
+			index, err := rand.Int(rand.Reader, size)
+			if err != nil {
+				return nil, err
+			}
+			code.WriteByte(recoveryCodeAlphabet[index.Int64()])
+		}
+		codes[i] = code.String()
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hex encoded SHA-256 of the code, only the hashes are stored.
+// Case, spaces and dashes don't matter, so that "K7WQP 3XN2M" is the same code as "k7wqp-3xn2m"
+func HashRecoveryCode(code string) string {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as authenticator apps
+// generate them: HMAC-SHA1, 6 digits, 30 seconds period
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+
+	// NOTE: Codes of the previous and the next period are accepted too,
+	// so that the clock drift and the time to type the code in are tolerated
+	skew = 1
+
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded secret, the one shared with the authenticator app
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI returns the otpauth:// URI authenticator apps enrol with, usually shown as a QR code
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period.Seconds()))},
+	}
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Code returns the code of the period the time falls into
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step(t)), nil
+}
+
+// Validate tells whether the code is the one of the period the time falls into, or of the adjacent ones
+func Validate(secret, candidate string, t time.Time) bool {
+	_, ok := Match(secret, candidate, t)
+	return ok
+}
+
+// Match is Validate that also returns the time step (the number of the period) the code belongs to.
+// A code is only meant to be accepted once (RFC 6238, section 5.2), so the step of the accepted code
+// is to be stored and the codes of the same or earlier steps rejected
+func Match(secret, candidate string, t time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(candidate) != Digits {
+		return 0, false
+	}
+
+	current := step(t)
+	matched, valid := uint64(0), false
+	for i := -skew; i <= skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(code(key, current+uint64(i))), []byte(candidate)) == 1 {
+			matched, valid = current+uint64(i), true
+		}
+	}
+	return int64(matched), valid
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret: %w", err)
+	}
+	return key, nil
+}
+
+func step(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Period.Seconds()))
+}
+
+// NOTE: Dynamic truncation of the HMAC, as described in RFC 4226
+func code(key []byte, counter uint64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
@@ -0,0 +1,127 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SergeyCherepiuk/docs/pkg/totp"
+)
+
+// NOTE: Test vectors of RFC 6238 (SHA1), the last 6 of their 8 digits
+func TestCode(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, test := range tests {
+		code, err := totp.Code(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("expected code %s at %d, got %s", test.code, test.unix, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, offset := range []time.Duration{-totp.Period, 0, totp.Period} {
+		code, _ := totp.Code(secret, now.Add(offset))
+		if !totp.Validate(secret, code, now) {
+			t.Errorf("expected code of %v to be valid", offset)
+		}
+	}
+
+	code, _ := totp.Code(secret, now.Add(-3*totp.Period))
+	if totp.Validate(secret, code, now) {
+		t.Errorf("expected code of an old period to be rejected")
+	}
+	if totp.Validate(secret, "12345", now) || totp.Validate("not base32!", "123456", now) {
+		t.Errorf("expected malformed input to be rejected")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111111, 0) // step 37037037
+
+	for i, offset := range []time.Duration{-totp.Period, 0, totp.Period} {
+		code, _ := totp.Code(secret, now.Add(offset))
+		if step, ok := totp.Match(secret, code, now); !ok || step != 37037036+int64(i) {
+			t.Errorf("expected code of %v to match step %d, got %d", offset, 37037036+i, step)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.URI("Docs", "jane doe", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/Docs:jane%20doe?") || !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=Docs") {
+		t.Errorf("unexpected uri %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("unexpected recovery code %q", code)
+		}
+		seen[code] = true
+	}
+
+	if totp.HashRecoveryCode(codes[0]) != totp.HashRecoveryCode(strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))) {
+		t.Errorf("expected the hash to ignore case, spaces and dashes")
+	}
+}
+
+// NOTE: With a byte modulo the 31 characters, the first 9 of them would come up 12.5% more often
+// (about 80 times per character here), the difference of the averages is well within 40 otherwise
+func TestRecoveryCodesAreUniform(t *testing.T) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	codes, err := totp.GenerateRecoveryCodes(2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[rune]int)
+	for _, code := range codes {
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			counts[r]++
+		}
+	}
+
+	var first, rest int
+	for i, r := range alphabet {
+		if i < 9 {
+			first += counts[r]
+		} else {
+			rest += counts[r]
+		}
+	}
+	if difference := float64(first)/9 - float64(rest)/22; difference > 40 {
+		t.Errorf("expected the characters to be equally likely, the first ones come up %.0f times more", difference)
+	}
+}